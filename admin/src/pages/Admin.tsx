@@ -123,7 +123,7 @@ function Admin() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setUsers(response.data.data)
+				setUsers(response.data.data.items)
 			}
 		} catch (error) {
 			console.error('获取用户失败')
@@ -136,7 +136,7 @@ function Admin() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setRooms(response.data.data)
+				setRooms(response.data.data.items)
 			}
 		} catch (error) {
 			console.error('获取直播间失败')
@@ -149,7 +149,7 @@ function Admin() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setGifts(response.data.data)
+				setGifts(response.data.data.items)
 			}
 		} catch (error) {
 			console.error('获取礼物失败')
@@ -162,7 +162,7 @@ function Admin() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setWords(response.data.data)
+				setWords(response.data.data.items)
 			}
 		} catch (error) {
 			console.error('获取敏感词失败')
@@ -175,7 +175,7 @@ function Admin() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setReports(response.data.data)
+				setReports(response.data.data.items)
 			}
 		} catch (error) {
 			console.error('获取举报失败')
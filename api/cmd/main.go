@@ -2,10 +2,15 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/huya_live/api/internal/config"
+	"github.com/huya_live/api/internal/handlers"
+	"github.com/huya_live/api/internal/outbox"
 	"github.com/huya_live/api/internal/repository"
 	"github.com/huya_live/api/internal/routes"
+	"github.com/huya_live/api/internal/scheduler"
+	"github.com/huya_live/api/pkg/centrifugo"
 	"github.com/huya_live/api/pkg/redis"
 )
 
@@ -15,6 +20,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if err := cfg.Centrifugo.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
 
 	// 初始化Redis
 	if err := redis.Init(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB); err != nil {
@@ -26,8 +34,30 @@ func main() {
 		log.Fatalf("Failed to init database: %v", err)
 	}
 
+	centrifugoClient := centrifugo.NewClient(centrifugo.Config{
+		URL:    cfg.Centrifugo.URL,
+		APIKey: cfg.Centrifugo.APIKey,
+		Secret: cfg.Centrifugo.Secret,
+	})
+
+	// 启动定时任务
+	jobs := scheduler.New()
+	jobs.Register("schedule_transitions", "schedule", time.Minute, handlers.AdvanceSchedules)
+	jobs.Register("danmu_retention", "cleanup", 24*time.Hour, handlers.PurgeDanmuHistory)
+	jobs.Register("outbox_cleanup", "cleanup", 24*time.Hour, outbox.Cleanup)
+	jobs.Register("vip_renewals", "billing", time.Hour, handlers.RenewVIPSubscriptions)
+	jobs.Register("channel_sub_renewals", "billing", time.Hour, handlers.RenewChannelSubscriptions)
+	jobs.Register("room_peak_online", "stats", time.Minute, handlers.PeakOnlineJob(centrifugoClient))
+	jobs.Start()
+
+	// 敏感词过滤器
+	handlers.StartContentFilterRefresh()
+
+	// 启动事件投递
+	outbox.NewWorker(centrifugoClient).Start()
+
 	// 初始化Gin路由
-	r := routes.SetupRouter()
+	r := routes.SetupRouter(cfg, centrifugoClient)
 
 	// 启动服务
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
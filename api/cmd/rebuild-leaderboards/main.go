@@ -0,0 +1,35 @@
+// Command rebuild-leaderboards recomputes the Redis leaderboards from the
+// gift and super chat history in Postgres, e.g. after Redis lost its data.
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/huya_live/api/internal/config"
+	"github.com/huya_live/api/internal/leaderboard"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/redis"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if err := redis.Init(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB); err != nil {
+		log.Fatalf("Failed to init Redis: %v", err)
+	}
+
+	if err := repository.Connect(cfg.Database); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	result, err := leaderboard.Rebuild(context.Background(), repository.DB, time.Now())
+	if err != nil {
+		log.Fatalf("Failed to rebuild leaderboards: %v", err)
+	}
+	log.Println(result)
+}
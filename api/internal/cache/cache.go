@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/huya_live/api/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Groups of cached responses that are invalidated together.
+const (
+	GroupRooms      = "rooms"
+	GroupCategories = "categories"
+)
+
+const (
+	// lockTTL bounds how long one instance may hold the fill lock of a key.
+	lockTTL = 5 * time.Second
+	// waitTimeout is how long other instances wait for that fill before
+	// loading the value themselves.
+	waitTimeout  = time.Second
+	waitInterval = 25 * time.Millisecond
+)
+
+// metrics counts, per group, cache hits, misses, callers that shared a
+// concurrent load or waited for another instance's, and Redis errors.
+var metrics = expvar.NewMap("cache")
+
+// Fetch returns the JSON encoding of the value cached under key in group,
+// calling load and caching its result for ttl on a miss. Concurrent misses
+// for a key share one load per process, and across instances the first to
+// take the fill lock loads while the rest wait briefly for its result. If
+// Redis is unavailable, load is called directly.
+func Fetch(ctx context.Context, group, key string, ttl time.Duration, load func() (interface{}, error)) (json.RawMessage, error) {
+	client := redis.GetClient()
+
+	gen, err := generation(ctx, client, group)
+	if err != nil {
+		metrics.Add(group+"_error", 1)
+		return encode(load)
+	}
+	full := "cache:" + group + ":" + gen + ":" + key
+
+	if b, err := client.Get(ctx, full).Bytes(); err == nil {
+		metrics.Add(group+"_hit", 1)
+		return b, nil
+	} else if !errors.Is(err, goredis.Nil) {
+		metrics.Add(group+"_error", 1)
+		return encode(load)
+	}
+
+	metrics.Add(group+"_miss", 1)
+	// The fill outlives this caller if it gives up, since others share it.
+	b, shared, err := flight.do(full, func() ([]byte, error) {
+		return fill(context.WithoutCancel(ctx), client, group, full, ttl, load)
+	})
+	if shared {
+		metrics.Add(group+"_shared", 1)
+	}
+	return b, err
+}
+
+// Invalidate drops every cached value in the given groups by moving them to
+// a new generation; the old keys expire on their own. A load that started
+// before the call can only write to the old generation, so it never brings
+// stale data back.
+func Invalidate(ctx context.Context, groups ...string) error {
+	client := redis.GetClient()
+	pipe := client.Pipeline()
+	for _, group := range groups {
+		pipe.Incr(ctx, "cache:"+group+":gen")
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func generation(ctx context.Context, client *goredis.Client, group string) (string, error) {
+	gen, err := client.Get(ctx, "cache:"+group+":gen").Result()
+	if errors.Is(err, goredis.Nil) {
+		return "0", nil
+	}
+	return gen, err
+}
+
+func fill(ctx context.Context, client *goredis.Client, group, full string, ttl time.Duration, load func() (interface{}, error)) ([]byte, error) {
+	lock := full + ":lock"
+	locked, err := client.SetNX(ctx, lock, 1, lockTTL).Result()
+	if err == nil && !locked {
+		deadline := time.Now().Add(waitTimeout)
+		for time.Now().Before(deadline) {
+			time.Sleep(waitInterval)
+			if b, err := client.Get(ctx, full).Bytes(); err == nil {
+				metrics.Add(group+"_waited", 1)
+				return b, nil
+			}
+		}
+	}
+
+	b, err := encode(load)
+	if err != nil {
+		if locked {
+			client.Del(ctx, lock)
+		}
+		return nil, err
+	}
+	client.Set(ctx, full, []byte(b), ttl)
+	if locked {
+		client.Del(ctx, lock)
+	}
+	return b, nil
+}
+
+func encode(load func() (interface{}, error)) (json.RawMessage, error) {
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	val []byte
+	err error
+}
+
+// flight collapses concurrent fills of the same key into one.
+var flight = &flightGroup{calls: make(map[string]*call)}
+
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn once for concurrent callers with the same key. shared reports
+// whether this caller received another caller's result.
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, bool, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, true, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	return c.val, false, c.err
+}
@@ -7,15 +7,17 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	Centrifugo CentrifugoConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port   string
+	Mode   string
+	WebURL string
 }
 
 type DatabaseConfig struct {
@@ -39,6 +41,33 @@ type JWTConfig struct {
 	RefreshTTL int
 }
 
+type CentrifugoConfig struct {
+	URL         string
+	APIKey      string
+	Secret      string
+	WSURL       string
+	TokenTTL    int
+	ProxySecret string
+}
+
+// Validate rejects a configuration without the Centrifugo credentials. They
+// have no defaults: a well-known token secret would let anyone mint
+// connection tokens, a default API key would let anyone publish, and
+// without the proxy secret anyone could call the proxy endpoints as any
+// user.
+func (c *CentrifugoConfig) Validate() error {
+	if c.APIKey == "" {
+		return fmt.Errorf("CENTRIFUGO_API_KEY is required")
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("CENTRIFUGO_SECRET is required")
+	}
+	if c.ProxySecret == "" {
+		return fmt.Errorf("CENTRIFUGO_PROXY_SECRET is required")
+	}
+	return nil
+}
+
 func (d *DatabaseConfig) DSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
@@ -53,6 +82,10 @@ func Load() (*Config, error) {
 	if mode == "" {
 		mode = "debug"
 	}
+	webURL := os.Getenv("WEB_URL")
+	if webURL == "" {
+		webURL = "http://localhost:5173"
+	}
 
 	host := os.Getenv("DB_HOST")
 	if host == "" {
@@ -102,10 +135,28 @@ func Load() (*Config, error) {
 		refreshTTL = 604800
 	}
 
+	centrifugoURL := os.Getenv("CENTRIFUGO_URL")
+	if centrifugoURL == "" {
+		centrifugoURL = "http://localhost:8000"
+	}
+	centrifugoAPIKey := os.Getenv("CENTRIFUGO_API_KEY")
+	centrifugoSecret := os.Getenv("CENTRIFUGO_SECRET")
+	centrifugoWSURL := os.Getenv("CENTRIFUGO_WS_URL")
+	if centrifugoWSURL == "" {
+		centrifugoWSURL = "ws://localhost:8000/connection/websocket"
+	}
+	centrifugoTokenTTL, _ := strconv.Atoi(os.Getenv("CENTRIFUGO_TOKEN_TTL"))
+	if centrifugoTokenTTL == 0 {
+		centrifugoTokenTTL = 3600
+	}
+
+	centrifugoProxySecret := os.Getenv("CENTRIFUGO_PROXY_SECRET")
+
 	return &Config{
 		Server: ServerConfig{
-			Port: port,
-			Mode: mode,
+			Port:   port,
+			Mode:   mode,
+			WebURL: webURL,
 		},
 		Database: DatabaseConfig{
 			Host:     host,
@@ -125,5 +176,13 @@ func Load() (*Config, error) {
 			AccessTTL:  accessTTL,
 			RefreshTTL: refreshTTL,
 		},
+		Centrifugo: CentrifugoConfig{
+			URL:         centrifugoURL,
+			APIKey:      centrifugoAPIKey,
+			Secret:      centrifugoSecret,
+			WSURL:       centrifugoWSURL,
+			TokenTTL:    centrifugoTokenTTL,
+			ProxySecret: centrifugoProxySecret,
+		},
 	}, nil
 }
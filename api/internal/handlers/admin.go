@@ -1,18 +1,25 @@
 package handlers
 
 import (
+	"expvar"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
-type AdminHandler struct{}
+type AdminHandler struct {
+	centrifugoClient *centrifugo.Client
+}
 
-func NewAdminHandler() *AdminHandler {
-	return &AdminHandler{}
+func NewAdminHandler(centrifugoClient *centrifugo.Client) *AdminHandler {
+	return &AdminHandler{centrifugoClient: centrifugoClient}
 }
 
 func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
@@ -38,13 +45,34 @@ func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
 	repository.DB.Raw("SELECT COUNT(*) FROM streamers").Scan(&stats.TotalStreamers)
 	repository.DB.Raw("SELECT COUNT(*) FROM live_rooms").Scan(&stats.TotalRooms)
 	repository.DB.Raw("SELECT COUNT(*) FROM live_rooms WHERE status = 'live'").Scan(&stats.LiveRooms)
-	repository.DB.Raw("SELECT COALESCE(SUM(coin_amount), 0) FROM gift_transactions").Scan(&stats.TotalRevenue)
+	repository.DB.Raw(`
+		SELECT COALESCE((SELECT SUM(coin_amount) FROM gift_transactions), 0)
+		     + COALESCE((SELECT SUM(coin_amount) FROM super_chats), 0)
+	`).Scan(&stats.TotalRevenue)
 	repository.DB.Raw("SELECT COUNT(*) FROM user_reports WHERE status = 'pending'").Scan(&stats.PendingReports)
 	repository.DB.Raw("SELECT COUNT(*) FROM users WHERE created_at >= ?", today).Scan(&stats.NewUsersToday)
 
 	response.Success(c, stats)
 }
 
+var adminUserPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"created_at":   "created_at",
+		"level":        "level",
+		"coin_balance": "coin_balance",
+		"username":     "username",
+	},
+	DefaultSort: "-created_at",
+	Key:         "id",
+	Filters: map[string][]string{
+		"status": {"active", "banned"},
+		"q":      nil,
+	},
+}
+
 func (h *AdminHandler) GetUserList(c *gin.Context) {
 	role := c.GetString("user_role")
 	if role != "admin" {
@@ -52,6 +80,20 @@ func (h *AdminHandler) GetUserList(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), adminUserPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Table("users")
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if q := page.Filter("q"); q != "" {
+		query = query.Where("username ILIKE ? OR nickname ILIKE ?", likePattern(q), likePattern(q))
+	}
+
 	var users []struct {
 		ID          string `json:"id"`
 		Username    string `json:"username"`
@@ -61,15 +103,14 @@ func (h *AdminHandler) GetUserList(c *gin.Context) {
 		Status      string `json:"status"`
 		CreatedAt   string `json:"created_at"`
 	}
+	total, err := page.Find(query.Select("id, username, nickname, level, coin_balance, status, created_at"), &users)
+	if err != nil {
+		response.Fail(c, "查询失败")
+		return
+	}
+	n, next := page.Next(len(users), nil)
 
-	repository.DB.Raw(`
-		SELECT id, username, nickname, level, coin_balance, status, created_at
-		FROM users
-		ORDER BY created_at DESC
-		LIMIT 50
-	`).Scan(&users)
-
-	response.Success(c, users)
+	response.Paged(c, users[:n], next, total)
 }
 
 func (h *AdminHandler) BanUser(c *gin.Context) {
@@ -92,6 +133,8 @@ func (h *AdminHandler) BanUser(c *gin.Context) {
 		return
 	}
 
+	h.centrifugoClient.Disconnect(userID)
+
 	response.Success(c, gin.H{"message": "用户已封禁"})
 }
 
@@ -112,6 +155,23 @@ func (h *AdminHandler) UnbanUser(c *gin.Context) {
 	response.Success(c, gin.H{"message": "已解封用户"})
 }
 
+var adminRoomPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"created_at":  "r.created_at",
+		"peak_online": "r.peak_online",
+		"total_views": "r.total_views",
+	},
+	DefaultSort: "-created_at",
+	Key:         "r.id",
+	Filters: map[string][]string{
+		"status":   {"live", "ended", "banned"},
+		"category": nil,
+	},
+}
+
 func (h *AdminHandler) GetRoomList(c *gin.Context) {
 	role := c.GetString("user_role")
 	if role != "admin" {
@@ -119,6 +179,20 @@ func (h *AdminHandler) GetRoomList(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), adminRoomPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Table("live_rooms r").Joins("JOIN users u ON u.id = r.streamer_id")
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("r.status = ?", status)
+	}
+	if category := page.Filter("category"); category != "" {
+		query = query.Where("r.category IN ?", categoryFilter(category))
+	}
+
 	var rooms []struct {
 		ID         string `json:"id"`
 		Title      string `json:"title"`
@@ -129,17 +203,14 @@ func (h *AdminHandler) GetRoomList(c *gin.Context) {
 		TotalViews int    `json:"total_views"`
 		CreatedAt  string `json:"created_at"`
 	}
+	total, err := page.Find(query.Select("r.id, r.title, r.streamer_id, u.nickname AS streamer, r.status, r.peak_online, r.total_views, r.created_at"), &rooms)
+	if err != nil {
+		response.Fail(c, "查询失败")
+		return
+	}
+	n, next := page.Next(len(rooms), nil)
 
-	repository.DB.Raw(`
-		SELECT r.id, r.title, r.streamer_id, u.nickname as streamer, 
-		       r.status, r.peak_online, r.total_views, r.created_at
-		FROM live_rooms r
-		JOIN users u ON u.id = r.streamer_id
-		ORDER BY r.created_at DESC
-		LIMIT 50
-	`).Scan(&rooms)
-
-	response.Success(c, rooms)
+	response.Paged(c, rooms[:n], next, total)
 }
 
 func (h *AdminHandler) BanRoom(c *gin.Context) {
@@ -158,10 +229,28 @@ func (h *AdminHandler) BanRoom(c *gin.Context) {
 		response.Fail(c, "操作失败")
 		return
 	}
+	invalidateRoomCaches()
 
 	response.Success(c, gin.H{"message": "直播间已封禁", "reason": reason})
 }
 
+var adminGiftPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"sort_order": "sort_order",
+		"coin_price": "coin_price",
+		"created_at": "created_at",
+	},
+	DefaultSort: "sort_order",
+	Key:         "id",
+	Filters: map[string][]string{
+		"category":  nil,
+		"is_active": {"true", "false"},
+	},
+}
+
 func (h *AdminHandler) GetGiftList(c *gin.Context) {
 	role := c.GetString("user_role")
 	if role != "admin" {
@@ -169,10 +258,29 @@ func (h *AdminHandler) GetGiftList(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), adminGiftPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.Gift{})
+	if category := page.Filter("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if active := page.Filter("is_active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
 	var gifts []models.Gift
-	repository.DB.Order("sort_order").Find(&gifts)
+	total, err := page.Find(query, &gifts)
+	if err != nil {
+		response.Fail(c, "查询失败")
+		return
+	}
+	n, next := page.Next(len(gifts), nil)
 
-	response.Success(c, gifts)
+	response.Paged(c, gifts[:n], next, total)
 }
 
 func (h *AdminHandler) CreateGift(c *gin.Context) {
@@ -238,6 +346,23 @@ func (h *AdminHandler) DeleteGift(c *gin.Context) {
 	response.Success(c, gin.H{"message": "删除成功"})
 }
 
+var adminSensitiveWordPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"word":       "word",
+	},
+	DefaultSort: "-created_at",
+	Key:         "id",
+	Filters: map[string][]string{
+		"type":     nil,
+		"severity": {"low", "medium", "high"},
+		"search":   nil,
+	},
+}
+
 func (h *AdminHandler) GetSensitiveWords(c *gin.Context) {
 	role := c.GetString("user_role")
 	if role != "admin" {
@@ -245,10 +370,32 @@ func (h *AdminHandler) GetSensitiveWords(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), adminSensitiveWordPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.SensitiveWord{})
+	if t := page.Filter("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+	if severity := page.Filter("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if search := page.Filter("search"); search != "" {
+		query = query.Where("word ILIKE ?", likePattern(search))
+	}
+
 	var words []models.SensitiveWord
-	repository.DB.Find(&words)
+	total, err := page.Find(query, &words)
+	if err != nil {
+		response.Fail(c, "查询失败")
+		return
+	}
+	n, next := page.Next(len(words), nil)
 
-	response.Success(c, words)
+	response.Paged(c, words[:n], next, total)
 }
 
 func (h *AdminHandler) AddSensitiveWord(c *gin.Context) {
@@ -259,17 +406,88 @@ func (h *AdminHandler) AddSensitiveWord(c *gin.Context) {
 	}
 
 	var word models.SensitiveWord
-	if err := c.ShouldBindJSON(&word); err != nil {
+	if err := c.ShouldBindJSON(&word); err != nil || word.Word == "" {
 		response.BadRequest(c, "参数错误")
 		return
 	}
+	if word.Type == "" {
+		word.Type = "blacklist"
+	}
+	if word.Severity == "" {
+		word.Severity = "medium"
+	}
+	if !isValidSensitiveWordType(word.Type) || !isValidSensitiveWordSeverity(word.Severity) {
+		response.BadRequest(c, "类型须为 blacklist/replace，级别须为 low/medium/high")
+		return
+	}
+	word.IsActive = true
 
 	if err := repository.DB.Create(&word).Error; err != nil {
 		response.Fail(c, "添加失败")
 		return
 	}
+	invalidateContentFilter()
 
-	response.Success(c, gin.H{"message": "添加成功"})
+	response.Success(c, gin.H{"message": "添加成功", "data": word})
+}
+
+func (h *AdminHandler) UpdateSensitiveWord(c *gin.Context) {
+	role := c.GetString("user_role")
+	if role != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	wordID := c.Param("id")
+
+	var req struct {
+		Word     string `json:"word"`
+		Type     string `json:"type"`
+		Severity string `json:"severity"`
+		IsActive *bool  `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Word != "" {
+		updates["word"] = req.Word
+	}
+	if req.Type != "" {
+		if !isValidSensitiveWordType(req.Type) {
+			response.BadRequest(c, "类型须为 blacklist/replace")
+			return
+		}
+		updates["type"] = req.Type
+	}
+	if req.Severity != "" {
+		if !isValidSensitiveWordSeverity(req.Severity) {
+			response.BadRequest(c, "级别须为 low/medium/high")
+			return
+		}
+		updates["severity"] = req.Severity
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	if err := repository.DB.Model(&models.SensitiveWord{}).Where("id = ?", wordID).Updates(updates).Error; err != nil {
+		response.Fail(c, "更新失败")
+		return
+	}
+	invalidateContentFilter()
+
+	response.Success(c, gin.H{"message": "更新成功"})
+}
+
+func isValidSensitiveWordType(t string) bool {
+	return t == "blacklist" || t == "replace"
+}
+
+func isValidSensitiveWordSeverity(s string) bool {
+	return s == "low" || s == "medium" || s == "high"
 }
 
 func (h *AdminHandler) DeleteSensitiveWord(c *gin.Context) {
@@ -285,6 +503,7 @@ func (h *AdminHandler) DeleteSensitiveWord(c *gin.Context) {
 		response.Fail(c, "删除失败")
 		return
 	}
+	invalidateContentFilter()
 
 	response.Success(c, gin.H{"message": "删除成功"})
 }
@@ -322,6 +541,111 @@ func (h *AdminHandler) UpdateSystemConfig(c *gin.Context) {
 		response.Fail(c, "更新失败")
 		return
 	}
+	invalidateSystemConfig()
 
 	response.Success(c, gin.H{"message": "更新成功"})
 }
+
+// GetMetrics exposes the process's expvar counters, including the outbox
+// queue depth and delivery counts and the per-group cache hit, miss and
+// error counts.
+func (h *AdminHandler) GetMetrics(c *gin.Context) {
+	role := c.GetString("user_role")
+	if role != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+var deadOutboxPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"id": "id"},
+	DefaultSort:  "-id",
+	Key:          "id",
+}
+
+func (h *AdminHandler) GetDeadOutboxEvents(c *gin.Context) {
+	role := c.GetString("user_role")
+	if role != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), deadOutboxPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.OutboxEvent{}).Where("status = ?", outbox.StatusDead)
+
+	var events []models.OutboxEvent
+	total, err := page.Find(query, &events)
+	if err != nil {
+		response.Fail(c, "查询失败")
+		return
+	}
+	n, next := page.Next(len(events), nil)
+
+	response.Paged(c, events[:n], next, total)
+}
+
+func (h *AdminHandler) RetryOutboxEvent(c *gin.Context) {
+	role := c.GetString("user_role")
+	if role != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+
+	ok, err := outbox.Retry(id)
+	if err != nil {
+		response.Fail(c, "重试失败")
+		return
+	}
+	if !ok {
+		response.BadRequest(c, "事件不存在或未进入死信")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "已重新加入队列"})
+}
+
+func (h *AdminHandler) CreateAnnouncement(c *gin.Context) {
+	role := c.GetString("user_role")
+	if role != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	var req struct {
+		Title   string `json:"title" binding:"required,max=100"`
+		Content string `json:"content" binding:"max=500"`
+		Link    string `json:"link" binding:"max=255"`
+		Scope   string `json:"scope" binding:"omitempty,oneof=global live_rooms all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = announceAll
+	}
+
+	if err := publishAnnouncement(req.Scope, req.Title, req.Content, req.Link); err != nil {
+		response.Fail(c, "发布失败")
+		return
+	}
+	outbox.Wake()
+
+	response.Success(c, gin.H{"message": "公告已发布"})
+}
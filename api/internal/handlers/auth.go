@@ -4,7 +4,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/filter"
 	"github.com/huya_live/api/pkg/jwt"
+	"github.com/huya_live/api/pkg/pinyin"
 	"github.com/huya_live/api/pkg/redis"
 	"github.com/huya_live/api/pkg/response"
 	"golang.org/x/crypto/bcrypt"
@@ -34,6 +36,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if len(filterContent(req.Username, filter.SeverityLow).Matches) > 0 ||
+		len(filterContent(req.Nickname, filter.SeverityLow).Matches) > 0 {
+		response.BadRequest(c, "username or nickname contains sensitive words")
+		return
+	}
+
 	var existingUser models.User
 	if err := repository.DB.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
 		response.BadRequest(c, "username already exists")
@@ -47,15 +55,16 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	user := models.User{
-		Username:     req.Username,
-		PasswordHash: string(passwordHash),
-		Nickname:     req.Nickname,
-		Phone:        req.Phone,
-		Email:        req.Email,
-		Level:        1,
-		Exp:          0,
-		CoinBalance:  0,
-		Status:       "active",
+		Username:       req.Username,
+		PasswordHash:   string(passwordHash),
+		Nickname:       req.Nickname,
+		NicknamePinyin: pinyin.Initials(req.Nickname),
+		Phone:          req.Phone,
+		Email:          req.Email,
+		Level:          1,
+		Exp:            0,
+		CoinBalance:    0,
+		Status:         "active",
 	}
 
 	if err := repository.DB.Create(&user).Error; err != nil {
@@ -162,6 +171,9 @@ type ProfileResponse struct {
 	CoinBalance int    `json:"coin_balance"`
 	Status      string `json:"status"`
 	CreatedAt   string `json:"created_at"`
+
+	HideFromRankings bool      `json:"hide_from_rankings"`
+	Noble            NobleTier `json:"noble"`
 }
 
 func (h *AuthHandler) GetProfile(c *gin.Context) {
@@ -172,6 +184,8 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
+	noble, _, _ := earnedNobleTier(c.Request.Context(), userID)
+
 	response.Success(c, ProfileResponse{
 		ID:          user.ID.String(),
 		Username:    user.Username,
@@ -182,14 +196,18 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		CoinBalance: user.CoinBalance,
 		Status:      user.Status,
 		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
+
+		HideFromRankings: user.HideFromRankings,
+		Noble:            noble,
 	})
 }
 
 func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 	var req struct {
-		Nickname  string `json:"nickname"`
-		AvatarURL string `json:"avatar_url"`
+		Nickname         string `json:"nickname"`
+		AvatarURL        string `json:"avatar_url"`
+		HideFromRankings *bool  `json:"hide_from_rankings"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, "invalid request")
@@ -198,11 +216,19 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 
 	updates := map[string]interface{}{}
 	if req.Nickname != "" {
+		if len(filterContent(req.Nickname, filter.SeverityLow).Matches) > 0 {
+			response.BadRequest(c, "nickname contains sensitive words")
+			return
+		}
 		updates["nickname"] = req.Nickname
+		updates["nickname_pinyin"] = pinyin.Initials(req.Nickname)
 	}
 	if req.AvatarURL != "" {
 		updates["avatar_url"] = req.AvatarURL
 	}
+	if req.HideFromRankings != nil {
+		updates["hide_from_rankings"] = *req.HideFromRankings
+	}
 
 	if err := repository.DB.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
 		response.Fail(c, "failed to update profile")
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"gorm.io/gorm"
+)
+
+const (
+	announceGlobal    = "global"
+	announceLiveRooms = "live_rooms"
+	announceAll       = "all"
+)
+
+// liveRoomChannels returns the public channel of every live room and running
+// relay.
+func liveRoomChannels(db *gorm.DB) []string {
+	var roomIDs, relayIDs []string
+	db.Model(&models.LiveRoom{}).Where("status = ?", "live").Pluck("id", &roomIDs)
+	db.Model(&models.RelayStream{}).Where("status = ?", "running").Pluck("id", &relayIDs)
+
+	channels := make([]string, 0, len(roomIDs)+len(relayIDs))
+	for _, id := range append(roomIDs, relayIDs...) {
+		channels = append(channels, centrifugo.GetChannels(id)[0])
+	}
+	return channels
+}
+
+// announceChannels resolves an announcement scope to its channels.
+func announceChannels(db *gorm.DB, scope string) []string {
+	switch scope {
+	case announceGlobal:
+		return []string{centrifugo.GlobalChannel}
+	case announceLiveRooms:
+		return liveRoomChannels(db)
+	default:
+		return append([]string{centrifugo.GlobalChannel}, liveRoomChannels(db)...)
+	}
+}
+
+// queueGiftBanner announces a gift worth at least gift_banner_threshold coins
+// to the global channel and every live room, as part of the gift's
+// transaction.
+func queueGiftBanner(tx *gorm.DB, roomID string, sender models.User, streamerName string, gift models.Gift, count, totalValue int) error {
+	threshold := SystemConfigInt("gift_banner_threshold", 10000)
+	if threshold <= 0 || totalValue < threshold {
+		return nil
+	}
+
+	banner := centrifugo.BannerMessage{
+		Type:      "gift_banner",
+		Timestamp: time.Now().UnixMilli(),
+	}
+	banner.Data.RoomID = roomID
+	banner.Data.SenderName = displayName(sender)
+	banner.Data.StreamerName = streamerName
+	banner.Data.GiftName = gift.Name
+	banner.Data.GiftIcon = gift.IconURL
+	banner.Data.Count = count
+	banner.Data.TotalValue = totalValue
+
+	return outbox.Broadcast(tx, announceChannels(tx, announceAll), banner)
+}
+
+// publishAnnouncement queues an admin announcement for the given scope.
+func publishAnnouncement(scope, title, content, link string) error {
+	return repository.DB.Transaction(func(tx *gorm.DB) error {
+		return outbox.Broadcast(tx, announceChannels(tx, scope), map[string]interface{}{
+			"type":       "announcement",
+			"title":      title,
+			"content":    content,
+			"link":       link,
+			"created_at": time.Now().Format(time.RFC3339),
+		})
+	})
+}
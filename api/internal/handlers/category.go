@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/internal/cache"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/response"
+)
+
+const categoryCacheTTL = 30 * time.Second
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type categorySnapshot struct {
+	categories []models.Category
+	byKey      map[string]models.Category
+	roomCounts map[string]int
+	relayCount map[string]int
+}
+
+// categoryCache holds the category list and live counts per category. Both
+// are read on every room listing, so they are rebuilt at most every
+// categoryCacheTTL or when an admin edits a category.
+var categoryCache = struct {
+	sync.RWMutex
+	snapshot *categorySnapshot
+	loadedAt time.Time
+}{}
+
+func loadCategories() *categorySnapshot {
+	categoryCache.RLock()
+	if categoryCache.snapshot != nil && time.Since(categoryCache.loadedAt) < categoryCacheTTL {
+		snapshot := categoryCache.snapshot
+		categoryCache.RUnlock()
+		return snapshot
+	}
+	categoryCache.RUnlock()
+
+	snapshot := &categorySnapshot{
+		byKey:      make(map[string]models.Category),
+		roomCounts: make(map[string]int),
+		relayCount: make(map[string]int),
+	}
+	repository.DB.Order("sort_order ASC, id ASC").Find(&snapshot.categories)
+	for _, cat := range snapshot.categories {
+		snapshot.byKey[cat.Name] = cat
+		snapshot.byKey[cat.Slug] = cat
+	}
+
+	var rows []struct {
+		Category string
+		Count    int
+	}
+	repository.DB.Model(&models.LiveRoom{}).Select("category, COUNT(*) AS count").
+		Where("status = ?", "live").Group("category").Scan(&rows)
+	for _, r := range rows {
+		snapshot.roomCounts[r.Category] = r.Count
+	}
+	rows = nil
+	repository.DB.Model(&models.RelayStream{}).Select("category, COUNT(*) AS count").
+		Where("status = ?", "running").Group("category").Scan(&rows)
+	for _, r := range rows {
+		snapshot.relayCount[r.Category] = r.Count
+	}
+
+	categoryCache.Lock()
+	categoryCache.snapshot = snapshot
+	categoryCache.loadedAt = time.Now()
+	categoryCache.Unlock()
+	return snapshot
+}
+
+// invalidateCategories drops this instance's snapshot and the category
+// listings cached in Redis for every instance.
+func invalidateCategories() {
+	categoryCache.Lock()
+	categoryCache.snapshot = nil
+	categoryCache.Unlock()
+	if err := cache.Invalidate(context.Background(), cache.GroupCategories); err != nil {
+		log.Printf("invalidate category cache: %v", err)
+	}
+}
+
+// resolveCategory maps a category name or slug from a request to the name
+// stored on rooms, relays and schedules. An empty value means uncategorized.
+// It reads the database rather than the snapshot, which another instance's
+// admin edit can leave stale for up to categoryCacheTTL.
+func resolveCategory(value string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	var cat models.Category
+	if err := repository.DB.Where("(name = ? OR slug = ?) AND is_active = ?", value, value, true).
+		First(&cat).Error; err != nil {
+		return "", false
+	}
+	return cat.Name, true
+}
+
+// categoryFilter resolves a ?category= filter to the names to match: the
+// category itself and, for a parent, its children. Unknown values are
+// matched literally.
+func categoryFilter(value string) []string {
+	snapshot := loadCategories()
+	cat, ok := snapshot.byKey[value]
+	if !ok {
+		return []string{value}
+	}
+	names := []string{cat.Name}
+	for _, child := range snapshot.categories {
+		if child.ParentID != nil && *child.ParentID == cat.ID {
+			names = append(names, child.Name)
+		}
+	}
+	return names
+}
+
+type CategoryResponse struct {
+	ID        int                `json:"id"`
+	ParentID  *int               `json:"parent_id"`
+	Name      string             `json:"name"`
+	Slug      string             `json:"slug"`
+	Icon      string             `json:"icon"`
+	SortOrder int                `json:"sort_order"`
+	Rooms     int                `json:"rooms"`
+	Relays    int                `json:"relays"`
+	Count     int                `json:"count"`
+	Children  []CategoryResponse `json:"children,omitempty"`
+}
+
+type CategoryHandler struct{}
+
+func NewCategoryHandler() *CategoryHandler {
+	return &CategoryHandler{}
+}
+
+// ListCategories returns active categories with live counts. By default the
+// result is a tree whose parent counts include their children; ?flat=1
+// returns every category at the top level.
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	flat := c.Query("flat") == "1" || c.Query("flat") == "true"
+	key := "tree"
+	if flat {
+		key = "flat"
+	}
+
+	data, err := cache.Fetch(c.Request.Context(), cache.GroupCategories, key, categoryCacheTTL, func() (interface{}, error) {
+		return listCategories(flat), nil
+	})
+	if err != nil {
+		response.Fail(c, "failed to load categories")
+		return
+	}
+	response.Success(c, data)
+}
+
+// listCategories returns the active categories with their live counts, as a
+// tree with counts rolled up into the roots or as a flat list.
+func listCategories(flat bool) []CategoryResponse {
+	snapshot := loadCategories()
+
+	byParent := make(map[int][]CategoryResponse)
+	var roots []CategoryResponse
+	for _, cat := range snapshot.categories {
+		if !cat.IsActive {
+			continue
+		}
+		item := CategoryResponse{
+			ID:        cat.ID,
+			ParentID:  cat.ParentID,
+			Name:      cat.Name,
+			Slug:      cat.Slug,
+			Icon:      cat.IconURL,
+			SortOrder: cat.SortOrder,
+			Rooms:     snapshot.roomCounts[cat.Name],
+			Relays:    snapshot.relayCount[cat.Name],
+		}
+		item.Count = item.Rooms + item.Relays
+		if cat.ParentID == nil || flat {
+			roots = append(roots, item)
+		} else {
+			byParent[*cat.ParentID] = append(byParent[*cat.ParentID], item)
+		}
+	}
+
+	if !flat {
+		for i := range roots {
+			children := byParent[roots[i].ID]
+			for _, child := range children {
+				roots[i].Rooms += child.Rooms
+				roots[i].Relays += child.Relays
+				roots[i].Count += child.Count
+			}
+			roots[i].Children = children
+		}
+	}
+
+	if roots == nil {
+		roots = []CategoryResponse{}
+	}
+	return roots
+}
+
+func (h *CategoryHandler) AdminListCategories(c *gin.Context) {
+	if c.GetString("user_role") != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	var categories []models.Category
+	repository.DB.Order("sort_order ASC, id ASC").Find(&categories)
+	response.Success(c, categories)
+}
+
+type CategoryRequest struct {
+	ParentID  *int   `json:"parent_id"`
+	Name      string `json:"name" binding:"required,max=50"`
+	Slug      string `json:"slug" binding:"required,max=50"`
+	IconURL   string `json:"icon_url"`
+	SortOrder int    `json:"sort_order"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+// validateParent allows only top-level categories as parents, which keeps
+// the hierarchy two levels deep.
+func validateParent(parentID *int, selfID int) string {
+	if parentID == nil {
+		return ""
+	}
+	if *parentID == selfID {
+		return "分类不能作为自己的父分类"
+	}
+	var parent models.Category
+	if err := repository.DB.First(&parent, *parentID).Error; err != nil {
+		return "父分类不存在"
+	}
+	if parent.ParentID != nil {
+		return "分类最多只能有两级"
+	}
+	if selfID != 0 {
+		var children int64
+		repository.DB.Model(&models.Category{}).Where("parent_id = ?", selfID).Count(&children)
+		if children > 0 {
+			return "包含子分类的分类不能再设置父分类"
+		}
+	}
+	return ""
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	if c.GetString("user_role") != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+	if !categorySlugPattern.MatchString(req.Slug) {
+		response.BadRequest(c, "slug 只能包含小写字母、数字和连字符")
+		return
+	}
+	if msg := validateParent(req.ParentID, 0); msg != "" {
+		response.BadRequest(c, msg)
+		return
+	}
+
+	category := models.Category{
+		ParentID:  req.ParentID,
+		Name:      req.Name,
+		Slug:      req.Slug,
+		IconURL:   req.IconURL,
+		SortOrder: req.SortOrder,
+		IsActive:  req.IsActive == nil || *req.IsActive,
+	}
+	if err := repository.DB.Create(&category).Error; err != nil {
+		response.BadRequest(c, "分类名称或 slug 已存在")
+		return
+	}
+	// is_active has a database default, so an explicit false needs its own update.
+	if !category.IsActive {
+		repository.DB.Model(&category).Update("is_active", false)
+	}
+	invalidateCategories()
+
+	response.Success(c, category)
+}
+
+// UpdateCategory edits a category. Renaming also renames the category on
+// existing rooms, relays and schedules, since they store the name.
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	if c.GetString("user_role") != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+
+	var category models.Category
+	if err := repository.DB.First(&category, id).Error; err != nil {
+		response.BadRequest(c, "分类不存在")
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误")
+		return
+	}
+	if !categorySlugPattern.MatchString(req.Slug) {
+		response.BadRequest(c, "slug 只能包含小写字母、数字和连字符")
+		return
+	}
+	if msg := validateParent(req.ParentID, category.ID); msg != "" {
+		response.BadRequest(c, msg)
+		return
+	}
+
+	oldName := category.Name
+	category.ParentID = req.ParentID
+	category.Name = req.Name
+	category.Slug = req.Slug
+	category.IconURL = req.IconURL
+	category.SortOrder = req.SortOrder
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+
+	tx := repository.DB.Begin()
+	if err := tx.Save(&category).Error; err != nil {
+		tx.Rollback()
+		response.BadRequest(c, "分类名称或 slug 已存在")
+		return
+	}
+	if oldName != category.Name {
+		for _, model := range []interface{}{&models.LiveRoom{}, &models.RelayStream{}, &models.LiveSchedule{}} {
+			if err := tx.Model(model).Where("category = ?", oldName).Update("category", category.Name).Error; err != nil {
+				tx.Rollback()
+				response.Fail(c, "更新失败")
+				return
+			}
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		response.Fail(c, "更新失败")
+		return
+	}
+	if oldName != category.Name {
+		invalidateRoomCaches()
+	} else {
+		invalidateCategories()
+	}
+
+	response.Success(c, category)
+}
+
+// DeleteCategory removes a category without children. Rooms keep the old
+// name as free text; deactivating is the gentler option.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	if c.GetString("user_role") != "admin" {
+		response.Forbidden(c, "需要管理员权限")
+		return
+	}
+
+	var children int64
+	repository.DB.Model(&models.Category{}).Where("parent_id = ?", c.Param("id")).Count(&children)
+	if children > 0 {
+		response.BadRequest(c, "请先删除或移动子分类")
+		return
+	}
+
+	result := repository.DB.Delete(&models.Category{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		response.Fail(c, "删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "分类不存在")
+		return
+	}
+	invalidateCategories()
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
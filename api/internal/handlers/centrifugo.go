@@ -1,25 +1,127 @@
 package handlers
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/response"
 )
 
-type CentrifugoHandler struct{}
+type CentrifugoHandler struct {
+	centrifugoClient *centrifugo.Client
+	wsURL            string
+	tokenTTL         time.Duration
+}
 
-func NewCentrifugoHandler() *CentrifugoHandler {
-	return &CentrifugoHandler{}
+func NewCentrifugoHandler(centrifugoClient *centrifugo.Client, wsURL string, tokenTTL int) *CentrifugoHandler {
+	return &CentrifugoHandler{
+		centrifugoClient: centrifugoClient,
+		wsURL:            wsURL,
+		tokenTTL:         time.Duration(tokenTTL) * time.Second,
+	}
 }
 
 type CentrifugoTokenResponse struct {
-	Token string `json:"token"`
-	URL   string `json:"url"`
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// ConnectionInfo is attached to every connection so that presence and
+// publications carry the user's display data.
+type ConnectionInfo struct {
+	Nickname string   `json:"nickname"`
+	Level    int      `json:"level"`
+	Avatar   string   `json:"avatar"`
+	Badges   []string `json:"badges"`
+	Noble    int      `json:"noble"`
 }
 
 func (h *CentrifugoHandler) GetToken(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	c.JSON(200, gin.H{"code": 0, "message": "success", "data": gin.H{
-		"token": "centrifugo_token_" + userID,
-		"url":   "ws://localhost:8000/connection/websocket",
-	}})
+	var user models.User
+	if err := repository.DB.First(&user, "id = ?", userID).Error; err != nil {
+		response.BadRequest(c, "user not found")
+		return
+	}
+
+	expiresAt := time.Now().Add(h.tokenTTL).Unix()
+	token := h.centrifugoClient.GenerateConnectionToken(userID, expiresAt, connectionInfo(user, c.GetString("user_role")))
+
+	response.Success(c, CentrifugoTokenResponse{
+		Token:     token,
+		URL:       h.wsURL,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// GetSubscriptionToken issues tokens for protected channels: a user's own
+// personal channel, and the streamer channel of rooms the caller owns or
+// moderates.
+func (h *CentrifugoHandler) GetSubscriptionToken(c *gin.Context) {
+	userID := c.GetString("user_id")
+	channel := c.Query("channel")
+	if channel == "" {
+		response.BadRequest(c, "channel is required")
+		return
+	}
+
+	if !canSubscribePrivate(userID, c.GetString("user_role"), channel) {
+		response.Forbidden(c, "permission denied")
+		return
+	}
+
+	expiresAt := time.Now().Add(h.tokenTTL).Unix()
+	response.Success(c, gin.H{
+		"token":      h.centrifugoClient.GenerateSubscriptionToken(userID, channel, expiresAt),
+		"channel":    channel,
+		"expires_at": expiresAt,
+	})
+}
+
+func canSubscribePrivate(userID, siteRole, channel string) bool {
+	if channel == centrifugo.UserChannel(userID) {
+		return true
+	}
+
+	if roomID, ok := strings.CutPrefix(channel, "streamer:"); ok {
+		streamerID, ok := roomStreamerID(roomID)
+		if !ok {
+			return false
+		}
+		uid, err := uuid.Parse(userID)
+		if err != nil {
+			return false
+		}
+		return roomRoleOf(streamerID, uid, siteRole) >= roomRoleModerator
+	}
+
+	return false
+}
+
+func connectionInfo(user models.User, siteRole string) ConnectionInfo {
+	badges := []string{}
+	if siteRole == "admin" {
+		badges = append(badges, "admin")
+	}
+	var count int64
+	repository.DB.Model(&models.Streamer{}).Where("user_id = ?", user.ID).Count(&count)
+	if count > 0 {
+		badges = append(badges, "streamer")
+	}
+
+	return ConnectionInfo{
+		Nickname: displayName(user),
+		Level:    user.Level,
+		Avatar:   user.AvatarURL,
+		Badges:   badges,
+		Noble:    effectiveNobleTier(context.Background(), user.ID.String()),
+	}
 }
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/jwt"
+)
+
+// CentrifugoProxyHandler implements Centrifugo's HTTP proxy protocol so that
+// clients can publish danmu over the WebSocket while the backend still does
+// authentication, validation, filtering, rate limiting and mute checks.
+// Replies are always HTTP 200; failures are reported in the body.
+type CentrifugoProxyHandler struct {
+	jwtManager   *jwt.Manager
+	danmuHandler *DanmuHandler
+	proxySecret  string
+}
+
+func NewCentrifugoProxyHandler(jwtManager *jwt.Manager, danmuHandler *DanmuHandler, proxySecret string) *CentrifugoProxyHandler {
+	return &CentrifugoProxyHandler{
+		jwtManager:   jwtManager,
+		danmuHandler: danmuHandler,
+		proxySecret:  proxySecret,
+	}
+}
+
+type proxyConnectRequest struct {
+	Client    string          `json:"client"`
+	Transport string          `json:"transport"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type proxyChannelRequest struct {
+	Client  string          `json:"client"`
+	User    string          `json:"user"`
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type proxyRPCRequest struct {
+	Client string          `json:"client"`
+	User   string          `json:"user"`
+	Method string          `json:"method"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// VerifySecret rejects proxy calls that do not carry the shared secret
+// configured as a static header on the Centrifugo side. The proxies trust
+// the user in the request body, so without a configured secret every call
+// is rejected.
+func (h *CentrifugoProxyHandler) VerifySecret(c *gin.Context) {
+	given := c.GetHeader("X-Centrifugo-Proxy-Secret")
+	if h.proxySecret == "" || subtle.ConstantTimeCompare([]byte(given), []byte(h.proxySecret)) != 1 {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	c.Next()
+}
+
+// Connect authenticates a connection with the app's access token, taken
+// from the forwarded Authorization header or from the connect data.
+func (h *CentrifugoProxyHandler) Connect(c *gin.Context) {
+	var req proxyConnectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		proxyError(c, http.StatusBadRequest, "bad request", false)
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" && len(req.Data) > 0 {
+		var data struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(req.Data, &data)
+		token = data.Token
+	}
+	if token == "" {
+		proxyError(c, http.StatusUnauthorized, "unauthorized", false)
+		return
+	}
+
+	claims, err := h.jwtManager.ValidateToken(token)
+	if err != nil {
+		proxyError(c, http.StatusUnauthorized, "unauthorized", false)
+		return
+	}
+
+	var user models.User
+	if err := repository.DB.First(&user, "id = ?", claims.UserID).Error; err != nil || user.Status != "active" {
+		proxyError(c, http.StatusForbidden, "account unavailable", false)
+		return
+	}
+
+	proxyResult(c, gin.H{
+		"user": user.ID.String(),
+		"info": connectionInfo(user, claims.Role),
+	})
+}
+
+// Subscribe allows the global channel and public room channels unless the
+// user is banned from the room, and applies the same ownership rules as subscription tokens to
+// private channels.
+func (h *CentrifugoProxyHandler) Subscribe(c *gin.Context) {
+	var req proxyChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		proxyError(c, http.StatusBadRequest, "bad request", false)
+		return
+	}
+
+	if roomID, ok := publicRoomChannel(req.Channel); ok {
+		if streamerID, ok := roomStreamerID(roomID); ok {
+			if userID, err := uuid.Parse(req.User); err == nil {
+				if penalty := activeRoomPenalty(streamerID, userID, penaltyBan, penaltyKick); penalty != nil {
+					proxyError(c, http.StatusForbidden, penaltyMessage(penalty), false)
+					return
+				}
+			}
+		}
+		if req.User != "" {
+			go announceEntrance(h.danmuHandler.centrifugoClient, roomID, req.User)
+		}
+		proxyResult(c, gin.H{})
+		return
+	}
+
+	if req.Channel == centrifugo.GlobalChannel {
+		proxyResult(c, gin.H{})
+		return
+	}
+
+	if req.User != "" && canSubscribePrivate(req.User, "", req.Channel) {
+		proxyResult(c, gin.H{})
+		return
+	}
+
+	proxyError(c, http.StatusForbidden, "permission denied", false)
+}
+
+// Publish accepts danmu sent directly to a room channel. The stored and
+// enriched message replaces what the client sent.
+func (h *CentrifugoProxyHandler) Publish(c *gin.Context) {
+	var req proxyChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		proxyError(c, http.StatusBadRequest, "bad request", false)
+		return
+	}
+
+	roomID, ok := publicRoomChannel(req.Channel)
+	if !ok || req.User == "" {
+		proxyError(c, http.StatusForbidden, "permission denied", false)
+		return
+	}
+
+	var danmuReq SendDanmuRequest
+	if err := json.Unmarshal(req.Data, &danmuReq); err != nil {
+		proxyError(c, http.StatusBadRequest, "invalid danmu", false)
+		return
+	}
+	danmuReq.RoomID = roomID
+
+	danmuMsg, record, release, derr := h.danmuHandler.prepareDanmu(c.Request.Context(), req.User, danmuReq)
+	if derr != nil {
+		proxyError(c, derr.Status, derr.Message, derr.Status == http.StatusTooManyRequests)
+		return
+	}
+
+	// Centrifugo publishes as soon as this returns, so a danmu that cannot be
+	// stored is rejected rather than delivered without history.
+	if err := repository.DB.Create(record).Error; err != nil {
+		release()
+		proxyError(c, http.StatusInternalServerError, "failed to send danmu", true)
+		return
+	}
+
+	h.danmuHandler.afterDanmu(roomID, danmuMsg)
+
+	proxyResult(c, gin.H{"data": danmuMsg})
+}
+
+func (h *CentrifugoProxyHandler) RPC(c *gin.Context) {
+	var req proxyRPCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		proxyError(c, http.StatusBadRequest, "bad request", false)
+		return
+	}
+
+	switch req.Method {
+	case "danmu.history":
+		var params struct {
+			RoomID   string `json:"room_id"`
+			Before   int64  `json:"before"`
+			BeforeID string `json:"before_id"`
+			Limit    int    `json:"limit"`
+		}
+		json.Unmarshal(req.Data, &params)
+
+		roomID, err := uuid.Parse(params.RoomID)
+		if err != nil {
+			proxyError(c, http.StatusBadRequest, "invalid room id", false)
+			return
+		}
+		if params.Limit <= 0 || params.Limit > danmuHistoryMaxLimit {
+			params.Limit = danmuHistoryDefaultLimit
+		}
+
+		before := danmuCursor{Ms: params.Before}
+		if params.BeforeID != "" {
+			if before.ID, err = uuid.Parse(params.BeforeID); err != nil {
+				proxyError(c, http.StatusBadRequest, "invalid before_id", false)
+				return
+			}
+		}
+
+		records, hasMore := latestDanmu(roomID, before, params.Limit)
+		items := danmuHistoryItems(records)
+		result := gin.H{
+			"items":    items,
+			"has_more": hasMore,
+		}
+		if hasMore && len(items) > 0 {
+			result["next_before"] = items[0].Timestamp
+			result["next_before_id"] = items[0].ID
+		}
+		proxyResult(c, gin.H{"data": result})
+	case "superchat.tiers":
+		proxyResult(c, gin.H{"data": superChatTiers})
+	default:
+		proxyError(c, http.StatusNotFound, "method not found", false)
+	}
+}
+
+// publicRoomChannel returns the room ID of a public room channel
+// ("room_<id>"), as opposed to its private "streamer:<id>" channel.
+func publicRoomChannel(channel string) (string, bool) {
+	if !strings.HasPrefix(channel, "room_") || strings.Contains(channel, ":") {
+		return "", false
+	}
+	return strings.TrimPrefix(channel, "room_"), true
+}
+
+func proxyResult(c *gin.Context, result interface{}) {
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+func proxyError(c *gin.Context, code int, message string, temporary bool) {
+	c.JSON(http.StatusOK, gin.H{"error": gin.H{
+		"code":      code,
+		"message":   message,
+		"temporary": temporary,
+	}})
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
+	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	channelSubActive  = "active"
+	channelSubExpired = "expired"
+
+	channelSubPeriod         = 30 * 24 * time.Hour
+	channelSubReminderBefore = 3 * 24 * time.Hour
+)
+
+type ChannelSubTier struct {
+	Tier         int    `json:"tier"`
+	Name         string `json:"name"`
+	MonthlyPrice int    `json:"monthly_price"`
+}
+
+var channelSubTiers = []ChannelSubTier{
+	{Tier: 1, Name: "Tier 1", MonthlyPrice: 100},
+	{Tier: 2, Name: "Tier 2", MonthlyPrice: 250},
+	{Tier: 3, Name: "Tier 3", MonthlyPrice: 600},
+}
+
+func channelSubTierByNumber(tier int) (ChannelSubTier, bool) {
+	for _, t := range channelSubTiers {
+		if t.Tier == tier {
+			return t, true
+		}
+	}
+	return ChannelSubTier{}, false
+}
+
+// activeChannelSub returns the user's current subscription to the streamer's
+// channel, or nil.
+func activeChannelSub(streamerID, userID uuid.UUID) *models.ChannelSubscription {
+	var sub models.ChannelSubscription
+	if err := repository.DB.Where("streamer_id = ? AND user_id = ? AND status = ? AND expires_at > ?",
+		streamerID, userID, channelSubActive, time.Now()).First(&sub).Error; err != nil {
+		return nil
+	}
+	return &sub
+}
+
+// recordSubRevenue books a subscription payment on the streamer's
+// revenue-share ledger and credits the streamer's share.
+func recordSubRevenue(tx *gorm.DB, sub *models.ChannelSubscription, amount int) error {
+	share := SystemConfigInt("channel_sub_streamer_share", 50)
+	if share < 0 || share > 100 {
+		share = 50
+	}
+	streamerShare := amount * share / 100
+
+	if err := tx.Create(&models.StreamerRevenue{
+		StreamerID:    sub.StreamerID,
+		UserID:        sub.UserID,
+		Source:        "subscription",
+		SourceID:      sub.ID.String(),
+		GrossAmount:   amount,
+		StreamerShare: streamerShare,
+		PlatformShare: amount - streamerShare,
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Streamer{}).
+		Where("user_id = ?", sub.StreamerID).
+		Update("total_revenue", gorm.Expr("total_revenue + ?", streamerShare)).Error
+}
+
+type ChannelSubHandler struct{}
+
+func NewChannelSubHandler() *ChannelSubHandler {
+	return &ChannelSubHandler{}
+}
+
+func (h *ChannelSubHandler) GetTiers(c *gin.Context) {
+	response.Success(c, channelSubTiers)
+}
+
+type SubscribeChannelRequest struct {
+	Tier      int   `json:"tier" binding:"required"`
+	AutoRenew *bool `json:"auto_renew"`
+}
+
+// Subscribe pays for a month of a streamer's channel. Resubscribing at the
+// same tier while active extends the current period; a higher tier upgrades
+// immediately, keeping the prorated value of the time left (see
+// upgradeExpiry). Lower tiers have to wait for expiry.
+func (h *ChannelSubHandler) Subscribe(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	streamerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid streamer id")
+		return
+	}
+	if streamerID.String() == userID {
+		response.BadRequest(c, "cannot subscribe to your own channel")
+		return
+	}
+
+	var req SubscribeChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	tier, ok := channelSubTierByNumber(req.Tier)
+	if !ok {
+		response.BadRequest(c, "unknown subscription tier")
+		return
+	}
+
+	var streamer models.Streamer
+	if err := repository.DB.First(&streamer, "user_id = ?", streamerID).Error; err != nil {
+		response.BadRequest(c, "streamer not found")
+		return
+	}
+
+	var sub models.ChannelSubscription
+	var user models.User
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		found := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&sub, "streamer_id = ? AND user_id = ?", streamerID, user.ID).Error == nil
+		active := found && sub.Status == channelSubActive && sub.ExpiresAt.After(now)
+
+		switch {
+		case active && sub.Tier > tier.Tier:
+			return errSubscriptionDowngrade
+		case active && sub.Tier == tier.Tier:
+			sub.ExpiresAt = sub.ExpiresAt.Add(channelSubPeriod)
+		case active:
+			current, _ := channelSubTierByNumber(sub.Tier)
+			sub.ExpiresAt = upgradeExpiry(now, sub.ExpiresAt, current.MonthlyPrice, tier.MonthlyPrice, channelSubPeriod)
+		default:
+			if !found {
+				sub.ID = uuid.New()
+				sub.StreamerID = streamerID
+				sub.UserID = user.ID
+				sub.AutoRenew = true
+			}
+			sub.StartedAt = now
+			sub.ExpiresAt = now.Add(channelSubPeriod)
+		}
+		sub.Tier = tier.Tier
+		sub.Status = channelSubActive
+		sub.Months++
+		sub.RemindedAt = nil
+		if req.AutoRenew != nil {
+			sub.AutoRenew = *req.AutoRenew
+		}
+
+		if _, err := debitCoins(tx, &user, tier.MonthlyPrice, "subscription", "Channel subscription to "+streamerID.String()); err != nil {
+			return err
+		}
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		return recordSubRevenue(tx, &sub, tier.MonthlyPrice)
+	})
+	switch err {
+	case nil:
+	case errInsufficientCoins:
+		response.BadRequest(c, "insufficient coins")
+		return
+	case errSubscriptionDowngrade:
+		response.BadRequest(c, "cannot downgrade an active subscription; turn off auto-renew and subscribe after it expires")
+		return
+	default:
+		response.Fail(c, "failed to subscribe")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"subscription":      sub,
+		"tier":              tier,
+		"remaining_balance": user.CoinBalance,
+	})
+}
+
+func (h *ChannelSubHandler) SetAutoRenew(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req struct {
+		AutoRenew *bool `json:"auto_renew" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "auto_renew is required")
+		return
+	}
+
+	result := repository.DB.Model(&models.ChannelSubscription{}).
+		Where("streamer_id = ? AND user_id = ? AND status = ?", c.Param("id"), userID, channelSubActive).
+		Updates(map[string]interface{}{"auto_renew": *req.AutoRenew, "reminded_at": nil})
+	if result.Error != nil {
+		response.Fail(c, "failed to update subscription")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "no active subscription")
+		return
+	}
+
+	response.Success(c, gin.H{"auto_renew": *req.AutoRenew})
+}
+
+var mySubscriptionPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"expires_at": "expires_at"},
+	DefaultSort:  "expires_at",
+	Key:          "id",
+}
+
+var subscriberPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"months":     "months",
+		"started_at": "started_at",
+	},
+	DefaultSort: "-months",
+	Key:         "id",
+	Filters:     map[string][]string{"tier": {"1", "2", "3"}},
+}
+
+var revenuePage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
+	Filters:      map[string][]string{"source": nil},
+}
+
+func (h *ChannelSubHandler) ListMySubscriptions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	page, err := pagination.Parse(c.Request.URL.Query(), mySubscriptionPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	var subs []models.ChannelSubscription
+	query := repository.DB.Model(&models.ChannelSubscription{}).Where("user_id = ? AND status = ?", userID, channelSubActive)
+	total, err := page.Find(query, &subs)
+	if err != nil {
+		response.Fail(c, "failed to load subscriptions")
+		return
+	}
+	n, next := page.Next(len(subs), func(i int) (interface{}, interface{}) {
+		return subs[i].ExpiresAt, subs[i].ID
+	})
+
+	response.Paged(c, subs[:n], next, total)
+}
+
+// ListSubscribers shows a streamer their active subscribers.
+func (h *ChannelSubHandler) ListSubscribers(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	page, err := pagination.Parse(c.Request.URL.Query(), subscriberPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.ChannelSubscription{}).Where("streamer_id = ? AND status = ?", userID, channelSubActive)
+	if tier := page.Filter("tier"); tier != "" {
+		query = query.Where("tier = ?", tier)
+	}
+
+	var subs []models.ChannelSubscription
+	total, err := page.Find(query, &subs)
+	if err != nil {
+		response.Fail(c, "failed to load subscribers")
+		return
+	}
+	n, next := page.Next(len(subs), func(i int) (interface{}, interface{}) {
+		if page.Sort == "started_at" {
+			return subs[i].StartedAt, subs[i].ID
+		}
+		return subs[i].Months, subs[i].ID
+	})
+
+	response.Paged(c, subs[:n], next, total)
+}
+
+// RevenueTotals sums a streamer's whole revenue-share ledger.
+type RevenueTotals struct {
+	Gross    int64 `json:"gross"`
+	Streamer int64 `json:"streamer"`
+	Platform int64 `json:"platform"`
+}
+
+// RevenuePage is the revenue page envelope plus lifetime totals.
+type RevenuePage struct {
+	response.Page
+	Totals RevenueTotals `json:"totals"`
+}
+
+// ListRevenue returns the streamer's revenue-share ledger, newest first,
+// with lifetime totals alongside the page envelope.
+func (h *ChannelSubHandler) ListRevenue(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	page, err := pagination.Parse(c.Request.URL.Query(), revenuePage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.StreamerRevenue{}).Where("streamer_id = ?", userID)
+	if source := page.Filter("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var entries []models.StreamerRevenue
+	total, err := page.Find(query, &entries)
+	if err != nil {
+		response.Fail(c, "failed to load revenue")
+		return
+	}
+	n, next := page.Next(len(entries), func(i int) (interface{}, interface{}) {
+		return entries[i].CreatedAt, entries[i].ID
+	})
+
+	var totals RevenueTotals
+	repository.DB.Model(&models.StreamerRevenue{}).
+		Select("COALESCE(SUM(gross_amount), 0) AS gross, COALESCE(SUM(streamer_share), 0) AS streamer, COALESCE(SUM(platform_share), 0) AS platform").
+		Where("streamer_id = ?", userID).
+		Scan(&totals)
+
+	response.Success(c, RevenuePage{
+		Page:   response.Page{Items: entries[:n], NextCursor: next, Total: total},
+		Totals: totals,
+	})
+}
+
+// RenewChannelSubscriptions is the scheduled billing run for channel
+// subscriptions; it mirrors RenewVIPSubscriptions.
+func RenewChannelSubscriptions() (string, error) {
+	now := time.Now()
+
+	var expiring []models.ChannelSubscription
+	if err := repository.DB.Where("status = ? AND reminded_at IS NULL AND expires_at > ? AND expires_at <= ?",
+		channelSubActive, now, now.Add(channelSubReminderBefore)).Find(&expiring).Error; err != nil {
+		return "", err
+	}
+	reminded := 0
+	for _, sub := range expiring {
+		if remindChannelSub(sub, now) {
+			reminded++
+		}
+	}
+
+	var due []models.ChannelSubscription
+	if err := repository.DB.Where("status = ? AND expires_at <= ?", channelSubActive, now).Find(&due).Error; err != nil {
+		return "", err
+	}
+	renewed, expired := 0, 0
+	for _, sub := range due {
+		ok, err := renewChannelSub(sub.ID, now)
+		if err != nil {
+			log.Printf("channel subscription renewal %s failed: %v", sub.ID, err)
+			continue
+		}
+		if ok {
+			renewed++
+		} else {
+			expired++
+		}
+	}
+
+	if reminded+renewed+expired > 0 {
+		outbox.Wake()
+	}
+	return fmt.Sprintf("reminded %d, renewed %d, expired %d", reminded, renewed, expired), nil
+}
+
+func remindChannelSub(sub models.ChannelSubscription, now time.Time) bool {
+	tier, _ := channelSubTierByNumber(sub.Tier)
+
+	var user models.User
+	if err := repository.DB.Select("id", "coin_balance").First(&user, "id = ?", sub.UserID).Error; err != nil {
+		return false
+	}
+
+	streamerName := getNicknameByID(sub.StreamerID)
+	content := fmt.Sprintf("你对 %s 的订阅将于 %s 到期", streamerName, sub.ExpiresAt.Format("2006-01-02 15:04"))
+	switch {
+	case !sub.AutoRenew:
+		content += "，续订即可保留订阅特权"
+	case user.CoinBalance < tier.MonthlyPrice:
+		content += fmt.Sprintf("，自动续订需要 %d 金币，当前余额不足", tier.MonthlyPrice)
+	default:
+		return false
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := notifyUser(tx, sub.UserID, "sub_expiring", "订阅即将到期", content, "/streamer/"+sub.StreamerID.String()); err != nil {
+			return err
+		}
+		return tx.Model(&models.ChannelSubscription{}).Where("id = ?", sub.ID).Update("reminded_at", now).Error
+	})
+	return err == nil
+}
+
+func renewChannelSub(id uuid.UUID, now time.Time) (bool, error) {
+	renewed := false
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		var sub models.ChannelSubscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ? AND expires_at <= ?", id, channelSubActive, now).
+			First(&sub).Error; err != nil {
+			return nil
+		}
+		tier, _ := channelSubTierByNumber(sub.Tier)
+		link := "/streamer/" + sub.StreamerID.String()
+		streamerName := getNicknameByID(sub.StreamerID)
+
+		if sub.AutoRenew {
+			user := models.User{ID: sub.UserID}
+			_, err := debitCoins(tx, &user, tier.MonthlyPrice, "subscription", "Channel subscription renewal to "+sub.StreamerID.String())
+			if err == nil {
+				sub.ExpiresAt = sub.ExpiresAt.Add(channelSubPeriod)
+				if sub.ExpiresAt.Before(now) {
+					sub.ExpiresAt = now.Add(channelSubPeriod)
+				}
+				sub.Months++
+				sub.RemindedAt = nil
+				renewed = true
+				if err := tx.Save(&sub).Error; err != nil {
+					return err
+				}
+				if err := recordSubRevenue(tx, &sub, tier.MonthlyPrice); err != nil {
+					return err
+				}
+				return notifyUser(tx, sub.UserID, "sub_renewed", "订阅已续订",
+					fmt.Sprintf("已扣除 %d 金币，对 %s 的订阅有效期至 %s", tier.MonthlyPrice, streamerName, sub.ExpiresAt.Format("2006-01-02")), link)
+			}
+			if err != errInsufficientCoins {
+				return err
+			}
+		}
+
+		if err := tx.Model(&sub).Update("status", channelSubExpired).Error; err != nil {
+			return err
+		}
+		if sub.AutoRenew {
+			return notifyUser(tx, sub.UserID, "sub_renew_failed", "订阅续订失败",
+				fmt.Sprintf("金币余额不足 %d，对 %s 的订阅已到期", tier.MonthlyPrice, streamerName), link)
+		}
+		return notifyUser(tx, sub.UserID, "sub_expired", "订阅已到期", "你对 "+streamerName+" 的订阅已到期", link)
+	})
+	return renewed, err
+}
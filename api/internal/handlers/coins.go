@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/leaderboard"
+	"github.com/huya_live/api/internal/models"
+	"gorm.io/gorm"
+)
+
+var errInsufficientCoins = errors.New("insufficient coins")
+
+// debitCoins takes amount from the user's balance inside tx and writes the
+// ledger entry. The balance check is part of the update so concurrent spends
+// cannot drive it negative.
+func debitCoins(tx *gorm.DB, user *models.User, amount int, txType, description string) (*models.CoinTransaction, error) {
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND coin_balance >= ?", user.ID, amount).
+		Update("coin_balance", gorm.Expr("coin_balance - ?", amount))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInsufficientCoins
+	}
+
+	if err := tx.Model(&models.User{}).Select("coin_balance").Where("id = ?", user.ID).Scan(&user.CoinBalance).Error; err != nil {
+		return nil, err
+	}
+
+	coinTx := models.CoinTransaction{
+		UserID:       user.ID,
+		Amount:       -amount,
+		BalanceAfter: user.CoinBalance,
+		Type:         txType,
+		Description:  description,
+	}
+	if err := tx.Create(&coinTx).Error; err != nil {
+		return nil, err
+	}
+	return &coinTx, nil
+}
+
+// loyaltyPointsFor applies the sender's level bonus, and their VIP bonus on
+// top, to a spend.
+func loyaltyPointsFor(tx *gorm.DB, user models.User, amount int) (int64, float64) {
+	var levelConfig models.LevelConfig
+	tx.First(&levelConfig, "level = ?", user.Level)
+	bonusMultiplier := levelConfig.BonusMultiplier
+	if bonusMultiplier == 0 {
+		bonusMultiplier = 1.0
+	}
+	bonusMultiplier = vipBonus(user.ID.String(), bonusMultiplier)
+	return int64(float64(amount) * bonusMultiplier), bonusMultiplier
+}
+
+// creditStreamer books a paid action in a streamer's room: it adds to the
+// streamer's revenue and to the sender's loyalty with that streamer.
+func creditStreamer(tx *gorm.DB, streamer *models.Streamer, senderID uuid.UUID, amount int, loyaltyPoints int64) error {
+	if err := tx.Model(&models.Streamer{}).
+		Where("user_id = ?", streamer.UserID).
+		Update("total_revenue", gorm.Expr("total_revenue + ?", amount)).Error; err != nil {
+		return err
+	}
+	streamer.TotalRevenue += int64(amount)
+
+	return tx.Model(&models.FanRelation{}).
+		Where("user_id = ? AND streamer_id = ?", senderID, streamer.UserID).
+		Updates(map[string]interface{}{
+			"loyalty_points":    gorm.Expr("loyalty_points + ?", loyaltyPoints),
+			"total_gift_amount": gorm.Expr("total_gift_amount + ?", amount),
+			"last_gift_at":      ptrTimeNow(),
+		}).Error
+}
+
+// recordLeaderboards counts a committed gift or super chat on the Redis
+// leaderboards. Failures are only logged; rebuild-leaderboards restores the
+// boards from Postgres.
+func recordLeaderboards(roomID, streamerID, senderID uuid.UUID, amount int, at time.Time) {
+	if err := leaderboard.Record(context.Background(), roomID.String(), streamerID.String(), senderID.String(), int64(amount), at); err != nil {
+		log.Printf("failed to update leaderboards for room %s: %v", roomID, err)
+	}
+}
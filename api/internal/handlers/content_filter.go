@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/filter"
+	"github.com/huya_live/api/pkg/redis"
+)
+
+const (
+	sensitiveWordsVersionKey = "sensitive_words:version"
+	sensitiveWordsCheckEvery = 10 * time.Second
+)
+
+// contentFilter is read on every message without locking beyond the
+// filter's own swap; the version bookkeeping is only touched by refreshes.
+var contentFilter = struct {
+	filter *filter.Filter
+	init   sync.Once
+
+	mu      sync.Mutex
+	loaded  bool
+	version string
+}{filter: filter.New()}
+
+// filterContent checks text against the active sensitive words. Blacklist
+// words at or above blockAt block the text; everything else that matched is
+// masked in the returned Result.Text.
+func filterContent(text string, blockAt filter.Severity) filter.Result {
+	contentFilter.init.Do(func() { refreshContentFilter(false) })
+	return contentFilter.filter.Check(text, blockAt)
+}
+
+// StartContentFilterRefresh loads the word list and then checks every
+// sensitiveWordsCheckEvery whether another instance bumped the version key
+// after an admin change, rebuilding in the background when it did. A failed
+// load is retried on the next check rather than on the next message.
+func StartContentFilterRefresh() {
+	contentFilter.init.Do(func() { refreshContentFilter(false) })
+	go func() {
+		ticker := time.NewTicker(sensitiveWordsCheckEvery)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshContentFilter(false)
+		}
+	}()
+}
+
+// refreshContentFilter rebuilds the filter when the shared version changed,
+// the last load failed, or force is set.
+func refreshContentFilter(force bool) {
+	contentFilter.mu.Lock()
+	defer contentFilter.mu.Unlock()
+
+	version, _ := redis.Get(context.Background(), sensitiveWordsVersionKey)
+	if !force && contentFilter.loaded && version == contentFilter.version {
+		return
+	}
+
+	if err := rebuildContentFilter(); err != nil {
+		log.Printf("failed to load sensitive words: %v", err)
+		contentFilter.loaded = false
+		return
+	}
+	contentFilter.version = version
+	contentFilter.loaded = true
+}
+
+func rebuildContentFilter() error {
+	var words []models.SensitiveWord
+	if err := repository.DB.Where("is_active = ?", true).Find(&words).Error; err != nil {
+		return err
+	}
+
+	entries := make([]filter.Word, 0, len(words))
+	for _, w := range words {
+		entries = append(entries, filter.Word{
+			Text:     w.Word,
+			Mask:     w.Type == "replace",
+			Severity: filter.ParseSeverity(w.Severity),
+		})
+	}
+	contentFilter.filter.Build(entries)
+	return nil
+}
+
+// invalidateContentFilter is called after admins change the word list. It
+// rebuilds locally right away and bumps the shared version so that other
+// instances pick the change up on their next check.
+func invalidateContentFilter() {
+	redis.Incr(context.Background(), sensitiveWordsVersionKey)
+	refreshContentFilter(true)
+}
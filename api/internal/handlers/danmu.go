@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"context"
+	"log"
+	"net/http"
 	"strings"
 	"time"
 
@@ -9,19 +12,34 @@ import (
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
 	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/filter"
 	"github.com/huya_live/api/pkg/response"
 )
 
-type DanmuHandler struct{}
+type DanmuHandler struct {
+	centrifugoClient *centrifugo.Client
+}
 
-func NewDanmuHandler() *DanmuHandler {
-	return &DanmuHandler{}
+func NewDanmuHandler(centrifugoClient *centrifugo.Client) *DanmuHandler {
+	return &DanmuHandler{centrifugoClient: centrifugoClient}
 }
 
 type SendDanmuRequest struct {
-	RoomID  string `json:"room_id" binding:"required"`
-	Content string `json:"content" binding:"required,min=1,max=100"`
-	Color   string `json:"color"`
+	RoomID   string `json:"room_id" binding:"required"`
+	Content  string `json:"content" binding:"required,min=1,max=100"`
+	Color    string `json:"color"`
+	Mode     string `json:"mode"`
+	FontSize int    `json:"font_size"`
+}
+
+const maxDanmuLength = 100
+
+// danmuError carries the HTTP status a rejected danmu maps to, so that the
+// REST endpoint and the Centrifugo publish proxy report it the same way.
+type danmuError struct {
+	Status  int
+	Message string
+	Limit   *danmuLimit
 }
 
 func (h *DanmuHandler) SendDanmu(c *gin.Context) {
@@ -32,6 +50,50 @@ func (h *DanmuHandler) SendDanmu(c *gin.Context) {
 		return
 	}
 
+	danmuMsg, record, release, derr := h.prepareDanmu(c.Request.Context(), userID, req)
+	if derr != nil {
+		switch derr.Status {
+		case http.StatusForbidden:
+			response.Forbidden(c, derr.Message)
+		case http.StatusTooManyRequests:
+			response.TooManyRequests(c, derr.Limit.Message, derr.Limit.Reason, derr.Limit.RetryAfter)
+		case http.StatusInternalServerError:
+			response.Fail(c, derr.Message)
+		default:
+			response.BadRequest(c, derr.Message)
+		}
+		return
+	}
+
+	channel := centrifugo.GetChannels(req.RoomID)[0]
+	if err := h.centrifugoClient.Publish(channel, danmuMsg); err != nil {
+		release()
+		response.Fail(c, "failed to send danmu: "+err.Error())
+		return
+	}
+
+	// Only delivered danmu go into history. The room already has this one,
+	// so a failed insert is logged rather than reported to the sender.
+	if err := repository.DB.Create(record).Error; err != nil {
+		log.Printf("failed to store danmu %s: %v", record.ID, err)
+	}
+	h.afterDanmu(req.RoomID, danmuMsg)
+
+	response.Success(c, gin.H{
+		"message_id": danmuMsg.Data.ID,
+		"content":    danmuMsg.Data.Content,
+		"timestamp":  danmuMsg.Timestamp,
+		"emotes":     danmuMsg.Data.Emotes,
+		"mentions":   danmuMsg.Data.Mentions,
+	})
+}
+
+// prepareDanmu runs every check a danmu must pass (room state, filtering,
+// mutes, style, and last the rate limits) and builds the message with its history record.
+// The caller delivers the message to the room channel and stores the record
+// once delivery succeeded; if delivery fails it calls release so the sender
+// can retry without hitting the slow-mode or duplicate window.
+func (h *DanmuHandler) prepareDanmu(ctx context.Context, userID string, req SendDanmuRequest) (*centrifugo.DanmuMessage, *models.DanmuRecord, func(), *danmuError) {
 	var room models.LiveRoom
 	var relay models.RelayStream
 	roomFound := false
@@ -45,34 +107,46 @@ func (h *DanmuHandler) SendDanmu(c *gin.Context) {
 	}
 
 	if !roomFound {
-		response.BadRequest(c, "room not found or not live")
-		return
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: "room not found or not live"}
 	}
 
 	content := strings.TrimSpace(req.Content)
 	if content == "" {
-		response.BadRequest(c, "content cannot be empty")
-		return
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: "content cannot be empty"}
+	}
+	if len([]rune(content)) > maxDanmuLength {
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: "content too long"}
 	}
 
-	sensitiveWords := []models.SensitiveWord{}
-	repository.DB.Where("is_active = ?", true).Find(&sensitiveWords)
-	for _, word := range sensitiveWords {
-		if strings.Contains(strings.ToLower(content), strings.ToLower(word.Word)) {
-			response.BadRequest(c, "content contains sensitive words")
-			return
-		}
+	filtered := filterContent(content, filter.SeverityMedium)
+	if filtered.Blocked {
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: "content contains sensitive words"}
 	}
+	content = filtered.Text
 
 	var user models.User
 	if err := repository.DB.First(&user, "id = ?", userID).Error; err != nil {
-		response.BadRequest(c, "user not found")
-		return
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: "user not found"}
 	}
 
-	danmuColor := req.Color
-	if danmuColor == "" {
-		danmuColor = "#FFFFFF"
+	isStreamer := room.ID != uuid.Nil && room.StreamerID == user.ID
+	if room.ID != uuid.Nil && !isStreamer {
+		if penalty := activeRoomPenalty(room.StreamerID, user.ID, penaltyMute, penaltyBan, penaltyKick); penalty != nil {
+			return nil, nil, nil, &danmuError{Status: http.StatusForbidden, Message: penaltyMessage(penalty)}
+		}
+	}
+
+	var sub *models.ChannelSubscription
+	if room.ID != uuid.Nil {
+		sub = activeChannelSub(room.StreamerID, user.ID)
+		if room.SubscriberOnly && sub == nil && !isStreamer && roomRoleOf(room.StreamerID, user.ID, "") < roomRoleModerator {
+			return nil, nil, nil, &danmuError{Status: http.StatusForbidden, Message: "subscriber-only chat is on"}
+		}
+	}
+
+	mode, fontSize, danmuColor, errMsg := danmuStyle(ctx, req, user)
+	if errMsg != "" {
+		return nil, nil, nil, &danmuError{Status: http.StatusBadRequest, Message: errMsg}
 	}
 
 	danmuMsg := centrifugo.DanmuMessage{
@@ -81,27 +155,186 @@ func (h *DanmuHandler) SendDanmu(c *gin.Context) {
 	}
 	danmuMsg.Data.ID = uuid.New().String()
 	danmuMsg.Data.UserID = userID
-	danmuMsg.Data.Nickname = user.Nickname
-	if user.Nickname != "" {
-		danmuMsg.Data.Nickname = user.Nickname
-	} else {
-		danmuMsg.Data.Nickname = user.Username
-	}
+	danmuMsg.Data.Nickname = displayName(user)
 	danmuMsg.Data.Level = user.Level
 	danmuMsg.Data.Avatar = user.AvatarURL
 	danmuMsg.Data.Content = content
 	danmuMsg.Data.Color = danmuColor
+	danmuMsg.Data.Mode = mode
+	danmuMsg.Data.FontSize = fontSize
+	danmuMsg.Data.Badges = []string{}
+	if sub != nil {
+		danmuMsg.Data.Badges = append(danmuMsg.Data.Badges, "subscriber")
+		danmuMsg.Data.SubTier = sub.Tier
+		danmuMsg.Data.SubMonths = sub.Months
+	}
+	danmuMsg.Data.Emotes = resolveEmotes(room.StreamerID, content)
+	danmuMsg.Data.Mentions = parseMentions(content, user.ID)
 
-	client := centrifugo.NewClient("http://localhost:8000", "")
-	channel := centrifugo.GetChannels(req.RoomID)[0]
-	if err := client.Publish(channel, danmuMsg); err != nil {
-		response.Fail(c, "failed to send danmu: "+err.Error())
+	record := models.DanmuRecord{
+		ID:        uuid.MustParse(danmuMsg.Data.ID),
+		UserID:    user.ID,
+		Nickname:  danmuMsg.Data.Nickname,
+		Level:     user.Level,
+		Content:   content,
+		Color:     danmuColor,
+		Mode:      mode,
+		FontSize:  fontSize,
+		CreatedAt: time.UnixMilli(danmuMsg.Timestamp),
+	}
+	if room.ID != uuid.Nil {
+		record.RoomID = room.ID
+		record.OffsetMs = danmuOffset(room.StartAt, record.CreatedAt)
+	} else {
+		record.RoomID = relay.ID
+	}
+
+	// The limiters go last: a danmu rejected for anything else must not use
+	// up the sender's rate, slow-mode or duplicate windows.
+	release, limit := checkDanmuLimits(ctx, user, req.RoomID, room.SlowModeSeconds, isStreamer, content)
+	if limit != nil {
+		return nil, nil, nil, &danmuError{Status: http.StatusTooManyRequests, Message: limit.Message, Limit: limit}
+	}
+
+	return &danmuMsg, &record, release, nil
+}
+
+// afterDanmu runs the side effects of a delivered danmu.
+func (h *DanmuHandler) afterDanmu(roomID string, danmuMsg *centrifugo.DanmuMessage) {
+	if len(danmuMsg.Data.Mentions) > 0 {
+		go h.notifyMentions(danmuMsg.Data.Mentions, danmuMsg.Data.Nickname, roomID, danmuMsg.Data.Content)
+	}
+}
+
+type UpdateChatSettingsRequest struct {
+	SlowModeSeconds *int  `json:"slow_mode_seconds" binding:"omitempty,min=0,max=300"`
+	SubscriberOnly  *bool `json:"subscriber_only"`
+}
+
+func (h *DanmuHandler) UpdateChatSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+	roomID := c.Param("id")
+
+	var room models.LiveRoom
+	if err := repository.DB.Where("id = ? AND streamer_id = ?", roomID, userID).First(&room).Error; err != nil {
+		response.BadRequest(c, "room not found")
+		return
+	}
+
+	var req UpdateChatSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.SlowModeSeconds != nil {
+		updates["slow_mode_seconds"] = *req.SlowModeSeconds
+		room.SlowModeSeconds = *req.SlowModeSeconds
+	}
+	if req.SubscriberOnly != nil {
+		updates["subscriber_only"] = *req.SubscriberOnly
+		room.SubscriberOnly = *req.SubscriberOnly
+	}
+	if len(updates) == 0 {
+		response.BadRequest(c, "nothing to update")
+		return
+	}
+
+	if err := repository.DB.Model(&room).Updates(updates).Error; err != nil {
+		response.Fail(c, "failed to update chat settings")
+		return
+	}
+
+	settings := gin.H{
+		"slow_mode_seconds": room.SlowModeSeconds,
+		"subscriber_only":   room.SubscriberOnly,
+	}
+	h.centrifugoClient.Publish(centrifugo.GetChannels(roomID)[0], gin.H{
+		"type":      "chat_settings",
+		"timestamp": time.Now().UnixMilli(),
+		"data":      settings,
+	})
+
+	settings["room_id"] = room.ID.String()
+	response.Success(c, settings)
+}
+
+type DeleteDanmuRequest struct {
+	Reason string `json:"reason" binding:"max=200"`
+}
+
+// DeleteDanmu lets the sender recall their own danmu within the recall
+// window, and lets the room owner, its moderators and site admins remove any
+// danmu. Either way the room is told to retract the message.
+func (h *DanmuHandler) DeleteDanmu(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		response.Unauthorized(c, "unauthorized")
 		return
 	}
 
+	var req DeleteDanmuRequest
+	c.ShouldBindJSON(&req)
+
+	var record models.DanmuRecord
+	if err := repository.DB.Where("id = ? AND deleted_at IS NULL", c.Param("id")).First(&record).Error; err != nil {
+		response.BadRequest(c, "danmu not found")
+		return
+	}
+
+	// Relay rooms have no streamer, so their audit entries are keyed by the
+	// relay ID and only site admins can moderate them.
+	streamerID := record.RoomID
+	var room models.LiveRoom
+	if err := repository.DB.Select("streamer_id").Where("id = ?", record.RoomID).First(&room).Error; err == nil {
+		streamerID = room.StreamerID
+	}
+
+	action := "danmu_delete"
+	if roomRoleOf(streamerID, userID, c.GetString("user_role")) < roomRoleModerator {
+		if record.UserID != userID {
+			response.Forbidden(c, "permission denied")
+			return
+		}
+		window := time.Duration(SystemConfigInt("danmu_recall_window", 120)) * time.Second
+		if time.Since(record.CreatedAt) > window {
+			response.BadRequest(c, "recall window has passed")
+			return
+		}
+		action = "danmu_recall"
+	}
+
+	now := time.Now()
+	result := repository.DB.Model(&models.DanmuRecord{}).
+		Where("id = ? AND created_at = ? AND deleted_at IS NULL", record.ID, record.CreatedAt).
+		Updates(map[string]interface{}{
+			"deleted_at": now,
+			"deleted_by": userID,
+		})
+	if result.Error != nil {
+		response.Fail(c, "failed to delete danmu")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "danmu not found")
+		return
+	}
+
+	recordModeration(streamerID, userID, &record.UserID, action, req.Reason, record.ID.String()+": "+record.Content)
+
+	h.centrifugoClient.Publish(centrifugo.GetChannels(record.RoomID.String())[0], gin.H{
+		"type":      "danmu_deleted",
+		"timestamp": now.UnixMilli(),
+		"data": gin.H{
+			"message_id": record.ID.String(),
+			"deleted_by": userID.String(),
+			"recalled":   action == "danmu_recall",
+		},
+	})
+
 	response.Success(c, gin.H{
-		"message_id": danmuMsg.Data.ID,
-		"content":    content,
-		"timestamp":  danmuMsg.Timestamp,
+		"message_id": record.ID.String(),
+		"action":     action,
 	})
 }
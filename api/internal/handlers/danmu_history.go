@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/response"
+)
+
+const (
+	danmuHistoryDefaultLimit = 50
+	danmuHistoryMaxLimit     = 200
+)
+
+type DanmuHistoryItem struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Nickname  string `json:"nickname"`
+	Level     int    `json:"level"`
+	Content   string `json:"content"`
+	Color     string `json:"color"`
+	Mode      string `json:"mode"`
+	FontSize  int    `json:"font_size"`
+	OffsetMs  int64  `json:"offset_ms"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// danmuCursor is the oldest danmu of a history page. The ID breaks ties
+// between danmu sent in the same millisecond.
+type danmuCursor struct {
+	Ms int64
+	ID uuid.UUID
+}
+
+// GetDanmuHistory pages through a room's stored danmu. Without parameters
+// it returns the latest messages for late joiners; `before` (unix ms) with
+// `before_id` pages further back, and `from_offset`/`to_offset` (ms since
+// the stream started) select a window for VOD replay. Items are always in
+// chronological order.
+func (h *DanmuHandler) GetDanmuHistory(c *gin.Context) {
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid room id")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(danmuHistoryDefaultLimit)))
+	if limit <= 0 || limit > danmuHistoryMaxLimit {
+		limit = danmuHistoryDefaultLimit
+	}
+
+	var records []models.DanmuRecord
+	hasMore := false
+	if from := c.Query("from_offset"); from != "" {
+		fromOffset, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "invalid from_offset")
+			return
+		}
+		query := repository.DB.Where("room_id = ? AND deleted_at IS NULL AND offset_ms >= ?", roomID, fromOffset)
+		if to := c.Query("to_offset"); to != "" {
+			toOffset, err := strconv.ParseInt(to, 10, 64)
+			if err != nil {
+				response.BadRequest(c, "invalid to_offset")
+				return
+			}
+			query = query.Where("offset_ms < ?", toOffset)
+		}
+		query.Order("offset_ms ASC, id ASC").Limit(limit + 1).Find(&records)
+		if len(records) > limit {
+			hasMore = true
+			records = records[:limit]
+		}
+	} else {
+		var before danmuCursor
+		if v := c.Query("before"); v != "" {
+			before.Ms, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				response.BadRequest(c, "invalid before")
+				return
+			}
+		}
+		if v := c.Query("before_id"); v != "" {
+			before.ID, err = uuid.Parse(v)
+			if err != nil {
+				response.BadRequest(c, "invalid before_id")
+				return
+			}
+		}
+		records, hasMore = latestDanmu(roomID, before, limit)
+	}
+
+	items := danmuHistoryItems(records)
+
+	result := gin.H{
+		"items":    items,
+		"has_more": hasMore,
+	}
+	if hasMore && len(items) > 0 && c.Query("from_offset") == "" {
+		result["next_before"] = items[0].Timestamp
+		result["next_before_id"] = items[0].ID
+	}
+	response.Success(c, result)
+}
+
+// latestDanmu returns up to limit danmu older than before (or the newest ones
+// when it is zero) in chronological order. Without an ID, before only
+// compares timestamps.
+func latestDanmu(roomID uuid.UUID, before danmuCursor, limit int) ([]models.DanmuRecord, bool) {
+	query := repository.DB.Where("room_id = ? AND deleted_at IS NULL", roomID)
+	if before.Ms > 0 {
+		at := time.UnixMilli(before.Ms)
+		if before.ID != uuid.Nil {
+			query = query.Where("(created_at, id) < (?, ?)", at, before.ID)
+		} else {
+			query = query.Where("created_at < ?", at)
+		}
+	}
+
+	var records []models.DanmuRecord
+	query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&records)
+
+	hasMore := len(records) > limit
+	if hasMore {
+		records = records[:limit]
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, hasMore
+}
+
+func danmuHistoryItems(records []models.DanmuRecord) []DanmuHistoryItem {
+	items := make([]DanmuHistoryItem, 0, len(records))
+	for _, r := range records {
+		items = append(items, DanmuHistoryItem{
+			ID:        r.ID.String(),
+			UserID:    r.UserID.String(),
+			Nickname:  r.Nickname,
+			Level:     r.Level,
+			Content:   r.Content,
+			Color:     r.Color,
+			Mode:      r.Mode,
+			FontSize:  r.FontSize,
+			OffsetMs:  r.OffsetMs,
+			Timestamp: r.CreatedAt.UnixMilli(),
+		})
+	}
+	return items
+}
+
+// PurgeDanmuHistory keeps the monthly danmu partitions ready ahead of time
+// and drops danmu older than the configured retention: whole partitions
+// where possible, then the expired rows of the partition that straddles the
+// cutoff.
+func PurgeDanmuHistory() (string, error) {
+	now := time.Now()
+	if err := repository.EnsureDanmuPartitions(repository.DB, now, now.AddDate(0, repository.DanmuPartitionsAhead, 0)); err != nil {
+		return "", err
+	}
+
+	days := SystemConfigInt("danmu_retention_days", 90)
+	if days <= 0 {
+		return "retention disabled", nil
+	}
+	cutoff := now.AddDate(0, 0, -days)
+
+	dropped, err := repository.DropDanmuPartitionsBefore(repository.DB, cutoff)
+	if err != nil {
+		return "", err
+	}
+	result := repository.DB.Where("created_at < ?", cutoff).Delete(&models.DanmuRecord{})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return fmt.Sprintf("partitions_dropped=%d danmu_purged=%d", dropped, result.RowsAffected), nil
+}
+
+func danmuOffset(startAt *time.Time, at time.Time) int64 {
+	if startAt == nil || at.Before(*startAt) {
+		return 0
+	}
+	return at.Sub(*startAt).Milliseconds()
+}
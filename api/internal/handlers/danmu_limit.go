@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/pkg/ratelimit"
+)
+
+const (
+	newAccountAge      = 24 * time.Hour
+	newAccountMaxLevel = 3
+)
+
+// danmuLimit describes why a danmu was throttled.
+type danmuLimit struct {
+	Reason     string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// danmuCooldown is a per-room window a danmu starts once it is accepted.
+type danmuCooldown struct {
+	key     string
+	window  time.Duration
+	reason  string
+	message string
+}
+
+// checkDanmuLimits applies the room's slow mode, the duplicate message
+// window and the per-user global bucket (stricter for new or low-level
+// accounts). Streamers are exempt from slow mode in their own room. Both
+// windows are checked before anything is taken, so a rejected danmu leaves
+// no limiter state behind. On success it returns a release func that ends
+// the windows again, for when delivery fails afterwards. Redis failures let
+// the message through.
+func checkDanmuLimits(ctx context.Context, user models.User, roomID string, slowMode int, isStreamer bool, content string) (func(), *danmuLimit) {
+	userID := user.ID.String()
+
+	var cooldowns []danmuCooldown
+	if slowMode > 0 && !isStreamer {
+		cooldowns = append(cooldowns, danmuCooldown{
+			key:     "danmu_slow:" + roomID + ":" + userID,
+			window:  time.Duration(slowMode) * time.Second,
+			reason:  "slow_mode",
+			message: "slow mode is on in this room",
+		})
+	}
+	if window := SystemConfigInt("danmu_duplicate_window", 10); window > 0 {
+		sum := sha1.Sum([]byte(strings.ToLower(content)))
+		cooldowns = append(cooldowns, danmuCooldown{
+			key:     "danmu_dup:" + roomID + ":" + userID + ":" + hex.EncodeToString(sum[:8]),
+			window:  time.Duration(window) * time.Second,
+			reason:  "duplicate",
+			message: "duplicate message",
+		})
+	}
+
+	for _, cd := range cooldowns {
+		if retry, err := ratelimit.CooldownRemaining(ctx, cd.key); err == nil && retry > 0 {
+			return nil, &danmuLimit{Reason: cd.reason, Message: cd.message, RetryAfter: retry}
+		}
+	}
+
+	perMinute := SystemConfigInt("danmu_rate_limit", 20)
+	if user.Level < newAccountMaxLevel || time.Since(user.CreatedAt) < newAccountAge {
+		perMinute = SystemConfigInt("danmu_rate_limit_new_user", 6)
+	}
+	if ok, retry, err := ratelimit.Allow(ctx, "danmu:"+userID, perMinute); err == nil && !ok {
+		return nil, &danmuLimit{Reason: "rate_limited", Message: "sending too fast, please slow down", RetryAfter: retry}
+	}
+
+	var taken []string
+	release := func() {
+		for _, key := range taken {
+			if err := ratelimit.ReleaseCooldown(context.Background(), key); err != nil {
+				log.Printf("release danmu cooldown %s: %v", key, err)
+			}
+		}
+	}
+	for _, cd := range cooldowns {
+		ok, retry, err := ratelimit.Cooldown(ctx, cd.key, cd.window)
+		if err != nil {
+			continue
+		}
+		if !ok {
+			// A concurrent send from the same user won the window.
+			release()
+			return nil, &danmuLimit{Reason: cd.reason, Message: cd.message, RetryAfter: retry}
+		}
+		taken = append(taken, cd.key)
+	}
+	return release, nil
+}
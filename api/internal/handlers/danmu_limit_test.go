@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/pkg/redis"
+)
+
+// useDanmuLimits points the limiters at an in-memory Redis and pins the
+// limiter settings, so no system_configs lookup reaches the database.
+func useDanmuLimits(t *testing.T, perMinute int) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := redis.Init(mr.Addr(), "", 0); err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+
+	systemConfigCache.Lock()
+	prev, prevLoaded := systemConfigCache.values, systemConfigCache.loadedAt
+	systemConfigCache.values = map[string]string{
+		"danmu_rate_limit":       strconv.Itoa(perMinute),
+		"danmu_duplicate_window": "10",
+	}
+	systemConfigCache.loadedAt = time.Now()
+	systemConfigCache.Unlock()
+	t.Cleanup(func() {
+		systemConfigCache.Lock()
+		systemConfigCache.values, systemConfigCache.loadedAt = prev, prevLoaded
+		systemConfigCache.Unlock()
+	})
+}
+
+func limitUser() models.User {
+	return models.User{ID: uuid.New(), Level: 10, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+}
+
+func TestCheckDanmuLimitsRejectionLeavesNoState(t *testing.T) {
+	useDanmuLimits(t, 20)
+	ctx := context.Background()
+	user := limitUser()
+	room := uuid.NewString()
+
+	if _, limit := checkDanmuLimits(ctx, user, room, 0, false, "hello"); limit != nil {
+		t.Fatalf("first danmu limited: %+v", limit)
+	}
+	_, limit := checkDanmuLimits(ctx, user, room, 0, false, "hello")
+	if limit == nil || limit.Reason != "duplicate" {
+		t.Fatalf("repeat = %+v, want duplicate", limit)
+	}
+
+	// Slow mode is turned on now; the duplicate rejection above must not
+	// have started its window, so a different message still goes through.
+	if _, limit := checkDanmuLimits(ctx, user, room, 30, false, "another"); limit != nil {
+		t.Fatalf("new message after a duplicate limited: %+v", limit)
+	}
+	_, limit = checkDanmuLimits(ctx, user, room, 30, false, "third")
+	if limit == nil || limit.Reason != "slow_mode" {
+		t.Fatalf("third message = %+v, want slow_mode", limit)
+	}
+}
+
+func TestCheckDanmuLimitsRejectionKeepsTokens(t *testing.T) {
+	useDanmuLimits(t, 2)
+	ctx := context.Background()
+	user := limitUser()
+	room := uuid.NewString()
+
+	if _, limit := checkDanmuLimits(ctx, user, room, 0, false, "hello"); limit != nil {
+		t.Fatalf("first danmu limited: %+v", limit)
+	}
+	for i := 0; i < 3; i++ {
+		if _, limit := checkDanmuLimits(ctx, user, room, 0, false, "hello"); limit == nil || limit.Reason != "duplicate" {
+			t.Fatalf("repeat %d = %+v, want duplicate", i, limit)
+		}
+	}
+	// The duplicates did not take tokens, so the second of two is left.
+	if _, limit := checkDanmuLimits(ctx, user, room, 0, false, "world"); limit != nil {
+		t.Errorf("second distinct danmu limited: %+v", limit)
+	}
+}
+
+func TestCheckDanmuLimitsRelease(t *testing.T) {
+	useDanmuLimits(t, 20)
+	ctx := context.Background()
+	user := limitUser()
+	room := uuid.NewString()
+
+	release, limit := checkDanmuLimits(ctx, user, room, 30, false, "hello")
+	if limit != nil {
+		t.Fatalf("first danmu limited: %+v", limit)
+	}
+	// Delivery failed: the retry must not hit the slow-mode or duplicate
+	// window the failed attempt started.
+	release()
+	if _, limit := checkDanmuLimits(ctx, user, room, 30, false, "hello"); limit != nil {
+		t.Errorf("retry after release limited: %+v", limit)
+	}
+}
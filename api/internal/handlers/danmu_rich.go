@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
+)
+
+const (
+	danmuModeScroll = "scroll"
+	danmuModeTop    = "top"
+	danmuModeBottom = "bottom"
+
+	danmuFontSizeDefault = 25
+	danmuDefaultColor    = "#FFFFFF"
+
+	maxDanmuMentions = 5
+	maxStreamerEmote = 100
+
+	// mentionMinRunes matches the shortest name mentionRegexp accepts, and
+	// mentionMaxUsers bounds the users a shared nickname can pull in.
+	mentionMinRunes = 2
+	mentionMaxUsers = 50
+)
+
+var (
+	hexColorPattern  = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	emoteTokenRegexp = regexp.MustCompile(`\[([^\[\]\s]{1,32})\]`)
+	emoteCodePattern = regexp.MustCompile(`^[A-Za-z0-9_\p{Han}]{1,32}$`)
+	mentionRegexp    = regexp.MustCompile(`@([\p{L}\p{N}_]{2,30})`)
+)
+
+var danmuFontSizes = map[int]bool{18: true, 25: true, 36: true}
+
+// danmuPalette is available to everyone; any other color is a special color
+// gated by user level or a VIP tier.
+var danmuPalette = map[string]bool{
+	"#FFFFFF": true,
+	"#FE0302": true,
+	"#FF7204": true,
+	"#FFAA02": true,
+	"#FFD302": true,
+	"#00CD00": true,
+	"#00A2FF": true,
+	"#CC0273": true,
+}
+
+// danmuStyle validates the presentation fields of a danmu and fills in
+// defaults. It returns a non-empty error message when the request is invalid.
+func danmuStyle(ctx context.Context, req SendDanmuRequest, user models.User) (mode string, fontSize int, color string, errMsg string) {
+	mode = req.Mode
+	switch mode {
+	case "":
+		mode = danmuModeScroll
+	case danmuModeScroll, danmuModeTop, danmuModeBottom:
+	default:
+		return "", 0, "", "mode must be scroll, top or bottom"
+	}
+
+	fontSize = req.FontSize
+	if fontSize == 0 {
+		fontSize = danmuFontSizeDefault
+	}
+	if !danmuFontSizes[fontSize] {
+		return "", 0, "", "font_size must be 18, 25 or 36"
+	}
+
+	color = strings.ToUpper(req.Color)
+	if color == "" {
+		color = danmuDefaultColor
+	}
+	if !hexColorPattern.MatchString(color) {
+		return "", 0, "", "color must be a hex color like #FFFFFF"
+	}
+	if !danmuPalette[color] && user.Level < SystemConfigInt("danmu_special_color_level", 10) {
+		if _, perks := noblePerks(ctx, user.ID.String()); !perks.SpecialColors {
+			return "", 0, "", "level not high enough for this color"
+		}
+	}
+
+	return mode, fontSize, color, ""
+}
+
+// resolveEmotes returns the streamer's emotes referenced as [code] tokens in
+// content. Unknown tokens are left for the client to render as plain text.
+func resolveEmotes(streamerID uuid.UUID, content string) []centrifugo.DanmuEmote {
+	matches := emoteTokenRegexp.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 || streamerID == uuid.Nil {
+		return nil
+	}
+
+	codes := make([]string, 0, len(matches))
+	for _, m := range matches {
+		codes = append(codes, m[1])
+	}
+
+	var emotes []models.StreamerEmote
+	repository.DB.Where("streamer_id = ? AND code IN ?", streamerID, codes).Find(&emotes)
+
+	resolved := make([]centrifugo.DanmuEmote, 0, len(emotes))
+	for _, e := range emotes {
+		resolved = append(resolved, centrifugo.DanmuEmote{Code: e.Code, URL: e.ImageURL})
+	}
+	return resolved
+}
+
+// parseMentions resolves @name tokens against usernames and nicknames.
+// Chinese text usually runs straight on after a name ("@张三你好"), so each
+// token resolves to its longest prefix that names a user, with usernames
+// winning over nicknames.
+func parseMentions(content string, senderID uuid.UUID) []centrifugo.DanmuMention {
+	matches := mentionRegexp.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	tokens := make([]string, 0, maxDanmuMentions)
+	for _, m := range matches {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		tokens = append(tokens, m[1])
+		if len(tokens) == maxDanmuMentions {
+			break
+		}
+	}
+
+	var candidates []string
+	for _, token := range tokens {
+		candidates = append(candidates, mentionPrefixes(token)...)
+	}
+
+	var users []models.User
+	repository.DB.Select("id", "username", "nickname").
+		Where("(username IN ? OR nickname IN ?) AND id <> ?", candidates, candidates, senderID).
+		Limit(mentionMaxUsers).
+		Find(&users)
+
+	byName := make(map[string]models.User, len(users)*2)
+	for _, u := range users {
+		if u.Nickname != "" {
+			byName[u.Nickname] = u
+		}
+	}
+	for _, u := range users {
+		byName[u.Username] = u
+	}
+
+	mentioned := make(map[uuid.UUID]bool)
+	mentions := make([]centrifugo.DanmuMention, 0, len(tokens))
+	for _, token := range tokens {
+		for _, prefix := range mentionPrefixes(token) {
+			u, ok := byName[prefix]
+			if !ok {
+				continue
+			}
+			if !mentioned[u.ID] {
+				mentioned[u.ID] = true
+				mentions = append(mentions, centrifugo.DanmuMention{UserID: u.ID.String(), Nickname: displayName(u)})
+			}
+			break
+		}
+	}
+	return mentions
+}
+
+// mentionPrefixes returns the prefixes of token that could be a name,
+// longest first.
+func mentionPrefixes(token string) []string {
+	runes := []rune(token)
+	prefixes := make([]string, 0, len(runes))
+	for n := len(runes); n >= mentionMinRunes; n-- {
+		prefixes = append(prefixes, string(runes[:n]))
+	}
+	return prefixes
+}
+
+func (h *DanmuHandler) notifyMentions(mentions []centrifugo.DanmuMention, senderName, roomID, content string) {
+	now := time.Now()
+	link := "/live/" + roomID
+	for _, m := range mentions {
+		notification := models.Notification{
+			ID:        uuid.New(),
+			UserID:    uuid.MustParse(m.UserID),
+			Type:      "mention",
+			Title:     senderName + " 在直播间提到了你",
+			Content:   content,
+			Link:      link,
+			CreatedAt: now,
+		}
+		repository.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+			return outbox.Publish(tx, centrifugo.UserChannel(m.UserID), map[string]interface{}{
+				"type":       "mention",
+				"id":         notification.ID.String(),
+				"room_id":    roomID,
+				"title":      notification.Title,
+				"content":    content,
+				"link":       link,
+				"created_at": now.Format(time.RFC3339),
+			})
+		})
+	}
+	outbox.Wake()
+}
+
+type CreateEmoteRequest struct {
+	Code     string `json:"code" binding:"required"`
+	ImageURL string `json:"image_url" binding:"required,url"`
+}
+
+func (h *DanmuHandler) ListEmotes(c *gin.Context) {
+	var emotes []models.StreamerEmote
+	repository.DB.Where("streamer_id = ?", c.Param("id")).Order("created_at ASC").Find(&emotes)
+	response.Success(c, emotes)
+}
+
+func (h *DanmuHandler) CreateEmote(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var streamer models.Streamer
+	if err := repository.DB.Where("user_id = ?", userID).First(&streamer).Error; err != nil {
+		response.BadRequest(c, "you are not a streamer")
+		return
+	}
+
+	var req CreateEmoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+	if !emoteCodePattern.MatchString(req.Code) {
+		response.BadRequest(c, "emote code may only contain letters, digits, underscores or Chinese characters")
+		return
+	}
+
+	var count int64
+	repository.DB.Model(&models.StreamerEmote{}).Where("streamer_id = ?", streamer.UserID).Count(&count)
+	if count >= maxStreamerEmote {
+		response.BadRequest(c, "emote limit reached")
+		return
+	}
+
+	emote := models.StreamerEmote{
+		StreamerID: streamer.UserID,
+		Code:       req.Code,
+		ImageURL:   req.ImageURL,
+	}
+	if err := repository.DB.Create(&emote).Error; err != nil {
+		response.BadRequest(c, "emote code already exists")
+		return
+	}
+
+	response.Success(c, emote)
+}
+
+func (h *DanmuHandler) DeleteEmote(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	result := repository.DB.Where("id = ? AND streamer_id = ?", c.Param("id"), userID).Delete(&models.StreamerEmote{})
+	if result.Error != nil {
+		response.Fail(c, "failed to delete emote")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "emote not found")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "emote deleted"})
+}
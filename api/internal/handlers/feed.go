@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/pagination"
+	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	feedDefaultLimit  = 20
+	feedMaxLimit      = 50
+	feedMaxCandidates = 500
+	// feedHistoryWindow bounds the watch history used for category taste.
+	feedHistoryWindow = 30 * 24 * time.Hour
+)
+
+// Feed signal weights. A followed streamer always outranks taste and
+// popularity; category affinity is the watched-time share of a category.
+const (
+	feedFollowWeight      = 100.0
+	feedFanLevelWeight    = 2.0
+	feedCategoryWeight    = 40.0
+	feedViewerWeight      = 4.0
+	feedLikeWeight        = 3.0
+	feedFreshnessWeight   = 5.0
+	feedFreshnessHalfLife = 2 * time.Hour
+)
+
+// FeedReason is one signal's contribution to a feed item's score.
+type FeedReason struct {
+	Signal string  `json:"signal"`
+	Value  float64 `json:"value"`
+	Score  float64 `json:"score"`
+}
+
+type FeedItem struct {
+	RoomListItem
+	Likes     int          `json:"likes"`
+	Following bool         `json:"following"`
+	Score     float64      `json:"score,omitempty"`
+	Reasons   []FeedReason `json:"reasons,omitempty"`
+
+	score float64
+}
+
+type FeedHandler struct {
+	centrifugoClient *centrifugo.Client
+}
+
+func NewFeedHandler(centrifugoClient *centrifugo.Client) *FeedHandler {
+	return &FeedHandler{centrifugoClient: centrifugoClient}
+}
+
+// feedProfile is what the feed knows about a viewer's taste.
+type feedProfile struct {
+	following  map[uuid.UUID]int  // streamer -> fan level
+	categories map[string]float64 // category -> share of watched time
+}
+
+func (p feedProfile) coldStart() bool {
+	return len(p.following) == 0 && len(p.categories) == 0
+}
+
+// feedPage pages the ranked feed. Ranking happens in memory, so score is
+// the only sort and pages are offsets into the ranking.
+var feedPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: feedDefaultLimit,
+	MaxLimit:     feedMaxLimit,
+	Sorts:        map[string]string{"score": "score"},
+	DefaultSort:  "-score",
+	Key:          "id",
+}
+
+// FeedPage is the feed's page envelope plus whether it was ranked without
+// any follows or history.
+type FeedPage struct {
+	response.Page
+	ColdStart bool `json:"cold_start"`
+}
+
+// GetFeed ranks live rooms and running relays for the current user. Rooms of
+// followed streamers come first, marked following and ordered by viewers;
+// everything else is scored from category taste (watch history), viewers,
+// likes and freshness. Users without follows or history get a
+// popularity-only ranking and cold_start=true. ?debug=1 adds each item's
+// score and the signals behind it.
+func (h *FeedHandler) GetFeed(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		response.Unauthorized(c, "未登录")
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), feedPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	debug := c.Query("debug") == "1" || c.Query("debug") == "true"
+
+	profile := loadFeedProfile(userID)
+	rows, err := feedCandidates(profile)
+	if err != nil {
+		response.Fail(c, "failed to load feed")
+		return
+	}
+
+	ids := make([]string, 0, len(rows))
+	uuids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID.String())
+		uuids = append(uuids, row.ID)
+	}
+	online := roomOnlineCounts(h.centrifugoClient, ids)
+	likes := roomLikeCounts(uuids)
+
+	now := time.Now()
+	following := make([]FeedItem, 0)
+	recommended := make([]FeedItem, 0, len(rows))
+	for _, row := range rows {
+		item := FeedItem{RoomListItem: row.listItem(), Likes: likes[row.ID]}
+		item.Online = online[item.ID]
+		reasons := scoreFeedItem(profile, row, item.Online, item.Likes, now)
+		for _, r := range reasons {
+			item.score += r.Score
+		}
+		if debug {
+			item.Score = round2(item.score)
+			item.Reasons = reasons
+		}
+
+		if _, ok := profile.following[row.StreamerID]; ok && !row.IsRelay {
+			item.Following = true
+			following = append(following, item)
+		} else {
+			recommended = append(recommended, item)
+		}
+	}
+
+	sort.SliceStable(following, func(i, j int) bool { return following[i].Online > following[j].Online })
+	sort.SliceStable(recommended, func(i, j int) bool { return recommended[i].score > recommended[j].score })
+	ranked := append(following, recommended...)
+
+	start := page.Offset
+	if start > len(ranked) {
+		start = len(ranked)
+	}
+	end := start + page.Limit + 1
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+	items := ranked[start:end]
+	n, next := page.Next(len(items), nil)
+
+	response.Success(c, FeedPage{
+		Page:      response.Page{Items: items[:n], NextCursor: next, Total: int64(len(ranked))},
+		ColdStart: profile.coldStart(),
+	})
+}
+
+// feedCandidates loads the rooms worth scoring: every live room of a
+// followed streamer, plus the feedMaxCandidates others that rank highest on
+// what Postgres knows of the feed signals, category taste, peak viewers and
+// freshness. Live viewer and like counts are only fetched for these.
+func feedCandidates(profile feedProfile) ([]roomFeedRow, error) {
+	followed := make([]uuid.UUID, 0, len(profile.following))
+	for streamerID := range profile.following {
+		followed = append(followed, streamerID)
+	}
+
+	var rows []roomFeedRow
+	if len(followed) > 0 {
+		if err := roomFeedQuery("live", "", "").
+			Where("NOT is_relay AND streamer_id IN ?", followed).
+			Find(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	others := roomFeedQuery("live", "", "")
+	if len(followed) > 0 {
+		others = others.Where("(is_relay OR streamer_id NOT IN ?)", followed)
+	}
+
+	relevance := "? * LN(1 + peak_online) + ? * POWER(2, -EXTRACT(EPOCH FROM (NOW() - started_at)) / ?)"
+	vars := []interface{}{feedViewerWeight, feedFreshnessWeight, feedFreshnessHalfLife.Seconds()}
+	if len(profile.categories) > 0 {
+		categories := make([]string, 0, len(profile.categories))
+		for category := range profile.categories {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+
+		taste := "CASE category"
+		for _, category := range categories {
+			taste += " WHEN ? THEN ?"
+			vars = append(vars, category, feedCategoryWeight*profile.categories[category])
+		}
+		relevance += " + " + taste + " ELSE 0 END"
+	}
+
+	var candidates []roomFeedRow
+	if err := others.
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "(" + relevance + ") DESC, id", Vars: vars}}).
+		Limit(feedMaxCandidates).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	return append(rows, candidates...), nil
+}
+
+// scoreFeedItem returns the signals that contribute to a candidate's score.
+func scoreFeedItem(profile feedProfile, row roomFeedRow, online, likes int, now time.Time) []FeedReason {
+	var reasons []FeedReason
+	add := func(signal string, value, score float64) {
+		if score != 0 {
+			reasons = append(reasons, FeedReason{Signal: signal, Value: value, Score: round2(score)})
+		}
+	}
+
+	if level, ok := profile.following[row.StreamerID]; ok && !row.IsRelay {
+		add("following", 1, feedFollowWeight)
+		add("fan_level", float64(level), feedFanLevelWeight*float64(level))
+	}
+	if share := profile.categories[row.Category]; share > 0 {
+		add("category", share, feedCategoryWeight*share)
+	}
+	add("viewers", float64(online), feedViewerWeight*math.Log1p(float64(online)))
+	add("likes", float64(likes), feedLikeWeight*math.Log1p(float64(likes)))
+
+	age := now.Sub(row.StartedAt)
+	if age < 0 {
+		age = 0
+	}
+	freshness := math.Exp2(-age.Hours() / feedFreshnessHalfLife.Hours())
+	add("freshness", round2(age.Minutes()), feedFreshnessWeight*freshness)
+
+	return reasons
+}
+
+// loadFeedProfile reads the user's follows and the category split of what
+// they watched recently, counting both rooms and relays.
+func loadFeedProfile(userID uuid.UUID) feedProfile {
+	profile := feedProfile{
+		following:  make(map[uuid.UUID]int),
+		categories: make(map[string]float64),
+	}
+
+	var relations []models.FanRelation
+	repository.DB.Select("streamer_id", "fan_level").Where("user_id = ?", userID).Find(&relations)
+	for _, rel := range relations {
+		profile.following[rel.StreamerID] = rel.FanLevel
+	}
+
+	var watched []struct {
+		Category string
+		Seconds  float64
+	}
+	repository.DB.Raw(`
+		SELECT COALESCE(r.category, s.category) AS category,
+		       SUM(GREATEST(h.watch_duration, 60)) AS seconds
+		FROM watch_histories h
+		LEFT JOIN live_rooms r ON r.id = h.room_id
+		LEFT JOIN relay_streams s ON s.id = h.room_id
+		WHERE h.user_id = ? AND h.created_at >= ?
+		  AND COALESCE(r.category, s.category, '') <> ''
+		GROUP BY 1
+	`, userID, time.Now().Add(-feedHistoryWindow)).Scan(&watched)
+
+	var total float64
+	for _, w := range watched {
+		total += w.Seconds
+	}
+	for _, w := range watched {
+		if total > 0 {
+			profile.categories[w.Category] = w.Seconds / total
+		}
+	}
+	return profile
+}
+
+// roomLikeCounts counts likes for each room in one query.
+func roomLikeCounts(roomIDs []uuid.UUID) map[uuid.UUID]int {
+	counts := make(map[uuid.UUID]int, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return counts
+	}
+
+	var rows []struct {
+		RoomID uuid.UUID
+		Count  int
+	}
+	repository.DB.Model(&models.RoomLike{}).
+		Select("room_id, COUNT(*) AS count").
+		Where("room_id IN ?", roomIDs).
+		Group("room_id").
+		Scan(&rows)
+	for _, r := range rows {
+		counts[r.RoomID] = r.Count
+	}
+	return counts
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -6,15 +6,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
 	"github.com/huya_live/api/internal/repository"
 	"github.com/huya_live/api/pkg/centrifugo"
 	"github.com/huya_live/api/pkg/response"
 )
 
-type GiftHandler struct{}
+type GiftHandler struct {
+	centrifugoClient *centrifugo.Client
+}
 
-func NewGiftHandler() *GiftHandler {
-	return &GiftHandler{}
+func NewGiftHandler(centrifugoClient *centrifugo.Client) *GiftHandler {
+	return &GiftHandler{centrifugoClient: centrifugoClient}
 }
 
 type SendGiftRequest struct {
@@ -65,12 +68,24 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 		return
 	}
 
+	if !isRelay {
+		if penalty := activeRoomPenalty(room.StreamerID, uuid.MustParse(userID), penaltyBan, penaltyKick); penalty != nil {
+			response.Forbidden(c, penaltyMessage(penalty))
+			return
+		}
+	}
+
 	var gift models.Gift
 	if err := repository.DB.Where("id = ? AND is_active = ?", req.GiftID, true).First(&gift).Error; err != nil {
 		response.BadRequest(c, "gift not found")
 		return
 	}
 
+	if required, ok := vipGiftCategories[gift.Category]; ok && effectiveNobleTier(c.Request.Context(), userID) < required {
+		response.Forbidden(c, "this gift requires noble tier "+vipTierLabel(required)+" or above")
+		return
+	}
+
 	if gift.MinLevelRequired > 1 {
 		var user models.User
 		if err := repository.DB.First(&user, "id = ?", userID).Error; err == nil {
@@ -117,35 +132,28 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 		}
 	}()
 
-	user.CoinBalance -= totalCost
-	if err := tx.Save(&user).Error; err != nil {
+	if _, err := debitCoins(tx, &user, totalCost, "gift", "Send gift to room "+req.RoomID); err != nil {
 		tx.Rollback()
+		if err == errInsufficientCoins {
+			response.BadRequest(c, "insufficient coins")
+			return
+		}
 		response.Fail(c, "failed to deduct coins")
 		return
 	}
 
-	coinTx := models.CoinTransaction{
-		UserID:       user.ID,
-		Amount:       -totalCost,
-		BalanceAfter: user.CoinBalance,
-		Type:         "gift",
-		Description:  "Send gift to room " + room.ID.String(),
-	}
-	if err := tx.Create(&coinTx).Error; err != nil {
-		tx.Rollback()
-		response.Fail(c, "failed to record transaction")
-		return
-	}
-
-	levelConfig := models.LevelConfig{}
-	repository.DB.First(&levelConfig, "level = ?", user.Level)
-	bonusMultiplier := levelConfig.BonusMultiplier
-	if bonusMultiplier == 0 {
-		bonusMultiplier = 1.0
-	}
-	loyaltyPoints := int64(float64(totalCost) * bonusMultiplier)
+	loyaltyPoints, bonusMultiplier := loyaltyPointsFor(tx, user, totalCost)
 
 	if isRelay {
+		if err := queueGiftBanner(tx, req.RoomID, user, relay.Name, gift, req.GiftCount, totalCost); err != nil {
+			tx.Rollback()
+			response.Fail(c, "failed to queue gift event")
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			response.Fail(c, "failed to commit transaction")
+			return
+		}
 		response.Success(c, gin.H{
 			"message":           "gift sent to relay stream (no streamer revenue)",
 			"gift_name":         gift.Name,
@@ -153,6 +161,7 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 			"total_cost":        totalCost,
 			"remaining_balance": user.CoinBalance,
 		})
+		outbox.Wake()
 		return
 	}
 
@@ -173,25 +182,9 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 		return
 	}
 
-	if !isRelay {
-		streamer.TotalRevenue += int64(totalCost)
-		if err := tx.Save(&streamer).Error; err != nil {
-			tx.Rollback()
-			response.Fail(c, "failed to update streamer revenue")
-			return
-		}
-
-		var fanRelation models.FanRelation
-		if err := tx.Where("user_id = ? AND streamer_id = ?", userID, room.StreamerID).First(&fanRelation).Error; err == nil {
-			fanRelation.LoyaltyPoints += loyaltyPoints
-			fanRelation.TotalGiftAmount += int64(totalCost)
-			fanRelation.LastGiftAt = ptrTimeNow()
-			tx.Save(&fanRelation)
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		response.Fail(c, "failed to commit transaction")
+	if err := creditStreamer(tx, &streamer, user.ID, totalCost, loyaltyPoints); err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to update streamer revenue")
 		return
 	}
 
@@ -215,14 +208,23 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 	giftMsg.Data.Combo = 1
 	giftMsg.Data.TotalValue = totalCost
 
-	client := centrifugo.NewClient("http://localhost:8000", "")
-	channel := centrifugo.GetChannels(req.RoomID)[0]
-	go client.Publish(channel, giftMsg)
+	if err := outbox.Publish(tx, centrifugo.GetChannels(req.RoomID)[0], giftMsg); err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to queue gift event")
+		return
+	}
+	if err := queueGiftBanner(tx, req.RoomID, user, getNicknameByID(streamer.UserID), gift, req.GiftCount, totalCost); err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to queue gift event")
+		return
+	}
 
-	streamerRevenue := int64(0)
-	if !isRelay {
-		streamerRevenue = streamer.TotalRevenue
+	if err := tx.Commit().Error; err != nil {
+		response.Fail(c, "failed to commit transaction")
+		return
 	}
+	outbox.Wake()
+	recordLeaderboards(room.ID, streamer.UserID, user.ID, totalCost, giftTx.CreatedAt)
 
 	response.Success(c, gin.H{
 		"transaction_id":    giftTx.ID,
@@ -231,6 +233,6 @@ func (h *GiftHandler) SendGift(c *gin.Context) {
 		"total_cost":        totalCost,
 		"remaining_balance": user.CoinBalance,
 		"loyalty_points":    loyaltyPoints,
-		"streamer_revenue":  streamerRevenue,
+		"streamer_revenue":  streamer.TotalRevenue,
 	})
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -14,6 +15,15 @@ func NewGiftInventoryHandler() *GiftInventoryHandler {
 	return &GiftInventoryHandler{}
 }
 
+var inventoryPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"sort_order": "g.sort_order"},
+	DefaultSort:  "sort_order",
+	Key:          "g.id",
+}
+
 func (h *GiftInventoryHandler) GetInventory(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -21,7 +31,17 @@ func (h *GiftInventoryHandler) GetInventory(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), inventoryPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	userUUID := uuid.MustParse(userID)
+	query := repository.DB.Table("gifts g").
+		Joins("LEFT JOIN gift_inventories i ON i.gift_id = g.id AND i.user_id = ?", userUUID).
+		Where("g.is_active = true AND (i.count > 0 OR g.category = 'special')")
+
 	var inventory []struct {
 		GiftID    int    `json:"gift_id"`
 		Name      string `json:"name"`
@@ -29,17 +49,18 @@ func (h *GiftInventoryHandler) GetInventory(c *gin.Context) {
 		Count     int    `json:"count"`
 		CoinPrice int    `json:"coin_price"`
 		Category  string `json:"category"`
+		SortOrder int    `json:"-"`
 	}
+	total, err := page.Find(query.Select("g.id AS gift_id, g.name, g.icon_url, COALESCE(i.count, 0) AS count, g.coin_price, g.category, g.sort_order"), &inventory)
+	if err != nil {
+		response.Fail(c, "获取礼物背包失败")
+		return
+	}
+	n, next := page.Next(len(inventory), func(i int) (interface{}, interface{}) {
+		return inventory[i].SortOrder, inventory[i].GiftID
+	})
 
-	repository.DB.Raw(`
-		SELECT g.id as gift_id, g.name, g.icon_url, COALESCE(i.count, 0) as count, g.coin_price, g.category
-		FROM gifts g
-		LEFT JOIN gift_inventories i ON i.gift_id = g.id AND i.user_id = ?
-		WHERE g.is_active = true AND (i.count > 0 OR g.category = 'special')
-		ORDER BY g.sort_order
-	`, userUUID).Scan(&inventory)
-
-	response.Success(c, inventory)
+	response.Paged(c, inventory[:n], next, total)
 }
 
 func (h *GiftInventoryHandler) AddGift(userID uuid.UUID, giftID int, count int) error {
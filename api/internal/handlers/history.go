@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -14,6 +17,15 @@ func NewHistoryHandler() *HistoryHandler {
 	return &HistoryHandler{}
 }
 
+var watchHistoryPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "h.created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "h.id",
+}
+
 func (h *HistoryHandler) GetWatchHistory(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -21,32 +33,40 @@ func (h *HistoryHandler) GetWatchHistory(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), watchHistoryPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	userUUID := uuid.MustParse(userID)
 	var history []struct {
-		ID            string `json:"id"`
-		RoomID        string `json:"room_id"`
-		RoomTitle     string `json:"room_title"`
-		StreamerID    string `json:"streamer_id"`
-		StreamerName  string `json:"streamer_name"`
-		CoverURL      string `json:"cover_url"`
-		WatchDuration int    `json:"watch_duration"`
-		StartTime     string `json:"start_time"`
+		ID            string    `json:"id"`
+		RoomID        string    `json:"room_id"`
+		RoomTitle     string    `json:"room_title"`
+		StreamerID    string    `json:"streamer_id"`
+		StreamerName  string    `json:"streamer_name"`
+		CoverURL      string    `json:"cover_url"`
+		WatchDuration int       `json:"watch_duration"`
+		StartTime     time.Time `json:"start_time"`
+	}
+
+	query := repository.DB.Table("watch_histories h").
+		Joins("JOIN live_rooms r ON r.id = h.room_id").
+		Joins("JOIN users u ON u.id = r.streamer_id").
+		Where("h.user_id = ?", userUUID)
+	total, err := page.Find(query.Select(`h.id, h.room_id, r.title AS room_title,
+		r.streamer_id, u.nickname AS streamer_name,
+		r.cover_url, h.watch_duration, h.created_at AS start_time`), &history)
+	if err != nil {
+		response.Fail(c, "获取观看历史失败")
+		return
 	}
+	n, next := page.Next(len(history), func(i int) (interface{}, interface{}) {
+		return history[i].StartTime, history[i].ID
+	})
 
-	repository.DB.Raw(`
-		SELECT 
-			h.id, h.room_id, r.title as room_title,
-			r.streamer_id, u.nickname as streamer_name,
-			r.cover_url, h.watch_duration, h.created_at as start_time
-		FROM watch_histories h
-		JOIN live_rooms r ON r.id = h.room_id
-		JOIN users u ON u.id = r.streamer_id
-		WHERE h.user_id = ?
-		ORDER BY h.created_at DESC
-		LIMIT 50
-	`, userUUID).Scan(&history)
-
-	response.Success(c, history)
+	response.Paged(c, history[:n], next, total)
 }
 
 func (h *HistoryHandler) AddWatchHistory(c *gin.Context) {
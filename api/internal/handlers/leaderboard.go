@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/internal/leaderboard"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
 	"github.com/huya_live/api/pkg/response"
 )
 
-type LeaderboardHandler struct{}
+type LeaderboardHandler struct {
+	centrifugoClient *centrifugo.Client
+}
 
-func NewLeaderboardHandler() *LeaderboardHandler {
-	return &LeaderboardHandler{}
+func NewLeaderboardHandler(centrifugoClient *centrifugo.Client) *LeaderboardHandler {
+	return &LeaderboardHandler{centrifugoClient: centrifugoClient}
 }
 
 type LeaderboardEntry struct {
@@ -23,140 +29,241 @@ type LeaderboardEntry struct {
 	StreamerID string `json:"streamer_id"`
 }
 
+// GetRoomLeaderboard ranks the viewers who spent the most in a live session.
 func (h *LeaderboardHandler) GetRoomLeaderboard(c *gin.Context) {
 	roomID := c.Param("room_id")
 
-	var transactions []models.GiftTransaction
-	repository.DB.Where("room_id = ?", roomID).
-		Select("receiver_id, SUM(coin_amount) as total").
-		Group("receiver_id").
-		Order("total DESC").
-		Limit(10).
-		Find(&transactions)
-
-	result := make([]LeaderboardEntry, 0, len(transactions))
-	for i, tx := range transactions {
-		var streamer models.Streamer
-		repository.DB.Where("user_id = ?", tx.ReceiverID).First(&streamer)
-
-		var user models.User
-		repository.DB.Where("id = ?", tx.ReceiverID).First(&user)
-
-		name := user.Nickname
-		if name == "" {
-			name = user.Username
+	entries, err := leaderboard.Top(c.Request.Context(), leaderboard.RoomKey(roomID), 50)
+	if err != nil {
+		entries, err = leaderboard.RoomTop(repository.DB, roomID, 50)
+		if err != nil {
+			response.Fail(c, "Failed to get leaderboard")
+			return
 		}
-
-		result = append(result, LeaderboardEntry{
-			ID:         user.ID.String(),
-			Name:       name,
-			Avatar:     user.AvatarURL,
-			Score:      int64(tx.CoinAmount),
-			Rank:       i + 1,
-			Level:      user.Level,
-			StreamerID: streamer.UserID.String(),
-		})
 	}
 
-	response.Success(c, result)
+	response.Success(c, leaderboardEntries(entries, false, 10))
 }
 
+// GetGlobalLeaderboard ranks streamers by revenue, either lifetime or within
+// the daily, weekly or monthly window.
 func (h *LeaderboardHandler) GetGlobalLeaderboard(c *gin.Context) {
 	limit := 20
-	streamers := []models.Streamer{}
-	repository.DB.Order("total_revenue DESC").Limit(limit).Find(&streamers)
+	period := c.DefaultQuery("period", "all")
 
-	result := make([]LeaderboardEntry, 0, len(streamers))
-	for i, streamer := range streamers {
-		var user models.User
-		repository.DB.Where("id = ?", streamer.UserID).First(&user)
+	if period != "all" {
+		h.periodLeaderboard(c, leaderboard.BoardStreamer, period, limit)
+		return
+	}
+
+	streamers := []models.Streamer{}
+	repository.DB.Joins("User").Order("streamers.total_revenue DESC").Limit(limit).Find(&streamers)
 
-		name := user.Nickname
-		if name == "" {
-			name = user.Username
+	entries := make([]leaderboard.Entry, 0, len(streamers))
+	users := make(map[string]models.User, len(streamers))
+	for _, streamer := range streamers {
+		entries = append(entries, leaderboard.Entry{Member: streamer.UserID.String(), Score: streamer.TotalRevenue})
+		if streamer.User != nil {
+			users[streamer.UserID.String()] = *streamer.User
 		}
+	}
 
-		result = append(result, LeaderboardEntry{
-			ID:         user.ID.String(),
-			Name:       name,
-			Avatar:     user.AvatarURL,
-			Score:      streamer.TotalRevenue,
-			Rank:       i + 1,
-			Level:      user.Level,
-			StreamerID: streamer.UserID.String(),
-		})
+	response.Success(c, rankEntries(entries, users, true, limit))
+}
+
+// GetGifterLeaderboard ranks viewers by coins spent on gifts and super chats
+// within the daily, weekly or monthly window.
+func (h *LeaderboardHandler) GetGifterLeaderboard(c *gin.Context) {
+	h.periodLeaderboard(c, leaderboard.BoardGifter, c.DefaultQuery("period", leaderboard.PeriodWeekly), 20)
+}
+
+func (h *LeaderboardHandler) periodLeaderboard(c *gin.Context, board, period string, limit int) {
+	key, err := leaderboard.PeriodKey(board, period, time.Now())
+	if err != nil {
+		response.BadRequest(c, "period must be daily, weekly or monthly")
+		return
 	}
 
-	response.Success(c, result)
+	// Over-fetch so that users hidden from rankings can be dropped.
+	entries, err := leaderboard.Top(c.Request.Context(), key, limit*5)
+	if err != nil {
+		response.Fail(c, "leaderboard unavailable")
+		return
+	}
+
+	response.Success(c, leaderboardEntries(entries, board == leaderboard.BoardStreamer, limit))
 }
 
-func (h *LeaderboardHandler) GetRichList(c *gin.Context) {
-	limit := 20
-	users := []models.User{}
-	repository.DB.Order("coin_balance DESC").Limit(limit).Find(&users)
-
-	result := make([]LeaderboardEntry, 0, len(users))
-	for i, user := range users {
-		name := user.Nickname
-		if name == "" {
-			name = user.Username
+// GetMyRank returns the caller's position on a board: "room" (requires
+// room_id), "streamer" or "gifter" (with period). Viewer board ranks skip
+// users who opted out of rankings, matching the public lists.
+func (h *LeaderboardHandler) GetMyRank(c *gin.Context) {
+	userID := c.GetString("user_id")
+	board := c.DefaultQuery("board", leaderboard.BoardGifter)
+
+	var key string
+	switch board {
+	case "room":
+		roomID := c.Query("room_id")
+		if roomID == "" {
+			response.BadRequest(c, "room_id is required")
+			return
+		}
+		key = leaderboard.RoomKey(roomID)
+	case leaderboard.BoardStreamer, leaderboard.BoardGifter:
+		var err error
+		key, err = leaderboard.PeriodKey(board, c.DefaultQuery("period", leaderboard.PeriodWeekly), time.Now())
+		if err != nil {
+			response.BadRequest(c, "period must be daily, weekly or monthly")
+			return
+		}
+	default:
+		response.BadRequest(c, "board must be room, streamer or gifter")
+		return
+	}
+
+	rank, score, err := leaderboard.Rank(c.Request.Context(), key, userID)
+	if err != nil {
+		response.Fail(c, "leaderboard unavailable")
+		return
+	}
+	if rank > 0 && board != leaderboard.BoardStreamer {
+		rank, err = visibleRank(c, key, userID, rank)
+		if err != nil {
+			response.Fail(c, "leaderboard unavailable")
+			return
 		}
+	}
 
-		result = append(result, LeaderboardEntry{
-			ID:     user.ID.String(),
-			Name:   name,
+	response.Success(c, gin.H{
+		"board":  board,
+		"rank":   rank,
+		"score":  score,
+		"ranked": rank > 0,
+	})
+}
+
+// visibleRank renumbers a viewer board rank the way rankEntries does, by
+// skipping the users above who opted out of rankings. A caller who opted out
+// is not ranked at all.
+func visibleRank(c *gin.Context, key, userID string, rank int64) (int64, error) {
+	var user models.User
+	if err := repository.DB.Select("hide_from_rankings").
+		Where("id = ?", userID).
+		First(&user).Error; err != nil {
+		return 0, err
+	}
+	if user.HideFromRankings {
+		return 0, nil
+	}
+	if rank == 1 {
+		return rank, nil
+	}
+
+	above, err := leaderboard.Top(c.Request.Context(), key, int(rank-1))
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, 0, len(above))
+	for _, e := range above {
+		ids = append(ids, e.Member)
+	}
+	var visible int64
+	if err := repository.DB.Model(&models.User{}).
+		Where("id IN ? AND hide_from_rankings = ?", ids, false).
+		Count(&visible).Error; err != nil {
+		return 0, err
+	}
+	return visible + 1, nil
+}
+
+// leaderboardEntries loads the users behind ranked member IDs in one query
+// and returns at most limit of them. Viewer boards skip users who opted out
+// of rankings; streamers are public by nature.
+func leaderboardEntries(entries []leaderboard.Entry, streamers bool, limit int) []LeaderboardEntry {
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.Member)
+	}
+
+	var users []models.User
+	if len(ids) > 0 {
+		repository.DB.Where("id IN ?", ids).Find(&users)
+	}
+	byID := make(map[string]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID.String()] = u
+	}
+
+	return rankEntries(entries, byID, streamers, limit)
+}
+
+// rankEntries numbers ranked members using already loaded users.
+func rankEntries(entries []leaderboard.Entry, byID map[string]models.User, streamers bool, limit int) []LeaderboardEntry {
+	result := make([]LeaderboardEntry, 0, limit)
+	for _, e := range entries {
+		if len(result) == limit {
+			break
+		}
+		user, ok := byID[e.Member]
+		if !streamers && (!ok || user.HideFromRankings) {
+			continue
+		}
+		entry := LeaderboardEntry{
+			ID:     e.Member,
+			Name:   displayName(user),
 			Avatar: user.AvatarURL,
-			Score:  int64(user.CoinBalance),
-			Rank:   i + 1,
+			Score:  e.Score,
+			Rank:   len(result) + 1,
 			Level:  user.Level,
-		})
+		}
+		if streamers {
+			entry.StreamerID = e.Member
+		}
+		result = append(result, entry)
 	}
-
-	response.Success(c, result)
+	return result
 }
 
-func (h *LeaderboardHandler) GetCategories(c *gin.Context) {
-	categories := []string{
-		"娱乐",
-		"游戏",
-		"美食",
-		"音乐",
-		"舞蹈",
-		"户外",
-		"科技",
-		"体育",
-		"汽车",
-		"时尚",
-		"教育",
-		"财经",
-		"新闻",
-		"综合",
-		"测试",
-	}
-
-	result := make([]gin.H, 0, len(categories))
-	for i, cat := range categories {
-		result = append(result, gin.H{
-			"id":    i + 1,
-			"name":  cat,
-			"icon":  "https://placeholder.com/category-" + cat + ".png",
-			"count": 0,
-		})
-	}
-
-	response.Success(c, result)
+// GetRichList ranks users by coins spent on gifts to streamers and super
+// chats, lifetime or within a window; every window counts the same
+// spending. Balances are never shown, and users who opted out of rankings
+// are left out.
+func (h *LeaderboardHandler) GetRichList(c *gin.Context) {
+	limit := 20
+	period := c.DefaultQuery("period", "all")
+
+	if period != "all" {
+		h.periodLeaderboard(c, leaderboard.BoardGifter, period, limit)
+		return
+	}
+
+	// Over-fetch so that users hidden from rankings can be dropped.
+	entries, err := leaderboard.Top(c.Request.Context(), leaderboard.LifetimeKey(leaderboard.BoardGifter), limit*5)
+	if err != nil {
+		response.Fail(c, "leaderboard unavailable")
+		return
+	}
+
+	response.Success(c, leaderboardEntries(entries, false, limit))
 }
 
 func (h *LeaderboardHandler) GetOnlineCount(c *gin.Context) {
-	var liveCount int64
-	repository.DB.Model(&models.LiveRoom{}).Where("status = ?", "live").Count(&liveCount)
+	var liveIDs []string
+	repository.DB.Model(&models.LiveRoom{}).Where("status = ?", "live").Pluck("id", &liveIDs)
+
+	var relayIDs []string
+	repository.DB.Model(&models.RelayStream{}).Where("status = ?", "running").Pluck("id", &relayIDs)
 
-	var relayCount int64
-	repository.DB.Model(&models.RelayStream{}).Where("status = ?", "running").Count(&relayCount)
+	viewers := 0
+	for _, count := range roomOnlineCounts(h.centrifugoClient, append(liveIDs, relayIDs...)) {
+		viewers += count
+	}
 
 	response.Success(c, gin.H{
-		"live_rooms":    liveCount,
-		"relay_streams": relayCount,
-		"total":         liveCount + relayCount,
+		"live_rooms":    len(liveIDs),
+		"relay_streams": len(relayIDs),
+		"total":         len(liveIDs) + len(relayIDs),
+		"viewers":       viewers,
 	})
 }
@@ -1,19 +1,48 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/cache"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/filter"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
 )
 
-type LiveHandler struct{}
+// Room lists and details are cached briefly in Redis and dropped whenever a
+// room or relay changes, so the TTL only bounds staleness of viewer counts.
+const (
+	roomListCacheTTL   = 10 * time.Second
+	roomDetailCacheTTL = 10 * time.Second
+)
+
+var errRoomNotFound = errors.New("room not found")
+
+// invalidateRoomCaches drops cached room lists and details together with the
+// category live counts after a room or relay changed status or metadata.
+func invalidateRoomCaches() {
+	if err := cache.Invalidate(context.Background(), cache.GroupRooms); err != nil {
+		log.Printf("invalidate room cache: %v", err)
+	}
+	invalidateCategories()
+}
 
-func NewLiveHandler() *LiveHandler {
-	return &LiveHandler{}
+type LiveHandler struct {
+	liveNotifier     *LiveNotifier
+	centrifugoClient *centrifugo.Client
+}
+
+func NewLiveHandler(liveNotifier *LiveNotifier, centrifugoClient *centrifugo.Client) *LiveHandler {
+	return &LiveHandler{liveNotifier: liveNotifier, centrifugoClient: centrifugoClient}
 }
 
 type CreateRoomRequest struct {
@@ -30,6 +59,18 @@ func (h *LiveHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	title := filterContent(req.Title, filter.SeverityLow)
+	if title.Blocked {
+		response.BadRequest(c, "title contains sensitive words")
+		return
+	}
+
+	category, ok := resolveCategory(req.Category)
+	if !ok {
+		response.BadRequest(c, "unknown category")
+		return
+	}
+
 	var streamer models.Streamer
 	if err := repository.DB.Where("user_id = ?", userID).First(&streamer).Error; err != nil {
 		response.BadRequest(c, "you are not a streamer")
@@ -46,8 +87,8 @@ func (h *LiveHandler) CreateRoom(c *gin.Context) {
 
 	room := models.LiveRoom{
 		StreamerID:  uuid.MustParse(userID),
-		Title:       req.Title,
-		Category:    req.Category,
+		Title:       title.Text,
+		Category:    category,
 		CoverURL:    req.CoverURL,
 		ChannelName: channelName,
 		Status:      "live",
@@ -59,11 +100,21 @@ func (h *LiveHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	schedule := linkScheduleToRoom(&room)
+	invalidateRoomCaches()
+	h.liveNotifier.NotifyLive(room.StreamerID, room.ID.String(), room.Title)
+
+	scheduleID := ""
+	if schedule != nil {
+		scheduleID = schedule.ID.String()
+	}
+
 	response.Success(c, gin.H{
 		"room_id":      room.ID.String(),
 		"channel_name": channelName,
 		"stream_url":   "rtmp://localhost/live/" + channelName,
 		"status":       "live",
+		"schedule_id":  scheduleID,
 	})
 }
 
@@ -91,6 +142,9 @@ func (h *LiveHandler) EndRoom(c *gin.Context) {
 		return
 	}
 
+	completeScheduleForRoom(&room)
+	invalidateRoomCaches()
+
 	response.Success(c, gin.H{
 		"message": "room ended successfully",
 		"room_id": room.ID.String(),
@@ -98,7 +152,7 @@ func (h *LiveHandler) EndRoom(c *gin.Context) {
 }
 
 type UpdateRoomRequest struct {
-	Title    string `json:"title" max=200"`
+	Title    string `json:"title" binding:"max=200"`
 	Category string `json:"category"`
 	CoverURL string `json:"cover_url"`
 }
@@ -121,10 +175,20 @@ func (h *LiveHandler) UpdateRoom(c *gin.Context) {
 
 	updates := make(map[string]interface{})
 	if req.Title != "" {
-		updates["title"] = req.Title
+		title := filterContent(req.Title, filter.SeverityLow)
+		if title.Blocked {
+			response.BadRequest(c, "title contains sensitive words")
+			return
+		}
+		updates["title"] = title.Text
 	}
 	if req.Category != "" {
-		updates["category"] = req.Category
+		category, ok := resolveCategory(req.Category)
+		if !ok {
+			response.BadRequest(c, "unknown category")
+			return
+		}
+		updates["category"] = category
 	}
 	if req.CoverURL != "" {
 		updates["cover_url"] = req.CoverURL
@@ -133,6 +197,7 @@ func (h *LiveHandler) UpdateRoom(c *gin.Context) {
 	if len(updates) > 0 {
 		updates["updated_at"] = time.Now()
 		repository.DB.Model(&room).Updates(updates)
+		invalidateRoomCaches()
 	}
 
 	response.Success(c, gin.H{
@@ -153,20 +218,37 @@ type GetRoomResponse struct {
 	StartAt      string `json:"start_at"`
 	PeakOnline   int    `json:"peak_online"`
 	TotalViews   int    `json:"total_views"`
+	Online       int    `json:"online"`
 	StreamURL    string `json:"stream_url,omitempty"`
 	FLVURL       string `json:"flv_url,omitempty"`
 	HLSURL       string `json:"hls_url,omitempty"`
 }
 
+// GetRoom returns a room or relay by ID through the room cache.
 func (h *LiveHandler) GetRoom(c *gin.Context) {
 	roomID := c.Param("id")
 
+	data, err := cache.Fetch(c.Request.Context(), cache.GroupRooms, "room:"+roomID, roomDetailCacheTTL, func() (interface{}, error) {
+		return h.loadRoom(roomID)
+	})
+	if errors.Is(err, errRoomNotFound) {
+		response.BadRequest(c, "room not found")
+		return
+	}
+	if err != nil {
+		response.Fail(c, "failed to load room")
+		return
+	}
+
+	response.Success(c, data)
+}
+
+func (h *LiveHandler) loadRoom(roomID string) (*GetRoomResponse, error) {
 	var room models.LiveRoom
-	if err := repository.DB.First(&room, "id = ?", roomID).Error; err != nil {
+	if err := repository.DB.Joins("Streamer").First(&room, "live_rooms.id = ?", roomID).Error; err != nil {
 		var relay models.RelayStream
 		if err := repository.DB.First(&relay, "id = ?", roomID).Error; err != nil {
-			response.BadRequest(c, "room not found")
-			return
+			return nil, errRoomNotFound
 		}
 
 		resp := GetRoomResponse{
@@ -184,6 +266,8 @@ func (h *LiveHandler) GetRoom(c *gin.Context) {
 		}
 
 		if relay.Status == "running" {
+			resp.Online = roomOnlineCounts(h.centrifugoClient, []string{resp.ID})[resp.ID]
+
 			// Always return SRS URLs for relay streams
 			// The actual relay is handled by FFmpeg pushing to SRS
 			resp.StreamURL = "rtmp://localhost/live/" + relay.ChannelName
@@ -191,19 +275,12 @@ func (h *LiveHandler) GetRoom(c *gin.Context) {
 			resp.HLSURL = "http://localhost:8080/live/" + relay.ChannelName + ".m3u8"
 		}
 
-		response.Success(c, resp)
-		return
+		return &resp, nil
 	}
 
 	streamerName := ""
-	if room.StreamerID != uuid.Nil {
-		var user models.User
-		if err := repository.DB.Select("nickname").Where("id = ?", room.StreamerID).First(&user).Error; err == nil {
-			streamerName = user.Nickname
-			if streamerName == "" {
-				streamerName = user.Username
-			}
-		}
+	if room.Streamer != nil {
+		streamerName = displayName(*room.Streamer)
 	}
 
 	resp := GetRoomResponse{
@@ -221,125 +298,215 @@ func (h *LiveHandler) GetRoom(c *gin.Context) {
 	}
 
 	if room.Status == "live" {
+		resp.Online = roomOnlineCounts(h.centrifugoClient, []string{resp.ID})[resp.ID]
 		resp.StreamURL = "rtmp://localhost/live/" + room.ChannelName
 		resp.FLVURL = "http://localhost:8080/live/" + room.ChannelName + ".flv"
 		resp.HLSURL = "http://localhost:8080/live/" + room.ChannelName + ".m3u8"
 	}
 
-	response.Success(c, resp)
+	return &resp, nil
 }
 
 type RoomListItem struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Category    string `json:"category"`
-	CoverURL    string `json:"cover_url"`
-	ChannelName string `json:"channel_name"`
-	Status      string `json:"status"`
-	StreamerID  string `json:"streamer_id"`
-	StartAt     string `json:"start_at"`
-	PeakOnline  int    `json:"peak_online"`
-	TotalViews  int    `json:"total_views"`
-	FLVURL      string `json:"flv_url,omitempty"`
-	HLSURL      string `json:"hls_url,omitempty"`
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Category     string `json:"category"`
+	CoverURL     string `json:"cover_url"`
+	ChannelName  string `json:"channel_name"`
+	Status       string `json:"status"`
+	StreamerID   string `json:"streamer_id"`
+	StreamerName string `json:"streamer_name"`
+	StartAt      string `json:"start_at"`
+	PeakOnline   int    `json:"peak_online"`
+	TotalViews   int    `json:"total_views"`
+	Online       int    `json:"online"`
+	FLVURL       string `json:"flv_url,omitempty"`
+	HLSURL       string `json:"hls_url,omitempty"`
 }
 
-func (h *LiveHandler) ListRooms(c *gin.Context) {
-	status := c.Query("status")
-	category := c.Query("category")
-	search := c.Query("search")
+var roomPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 30,
+	MaxLimit:     100,
+	Sorts: map[string]string{
+		"start_at":    "started_at",
+		"total_views": "total_views",
+		"peak_online": "peak_online",
+	},
+	DefaultSort: "-start_at",
+	Key:         "id",
+	Filters: map[string][]string{
+		"status":      {"live", "ended"},
+		"category":    nil,
+		"search":      nil,
+		"streamer_id": nil,
+	},
+}
 
-	query := repository.DB.Model(&models.LiveRoom{}).Preload("Streamer")
+// roomFeedRow is a live room or a running relay as selected by ListRooms.
+type roomFeedRow struct {
+	ID           uuid.UUID
+	Title        string
+	Category     string
+	CoverURL     string
+	ChannelName  string
+	Status       string
+	StreamerID   uuid.UUID
+	StreamerName string
+	StartedAt    time.Time
+	PeakOnline   int
+	TotalViews   int
+	IsRelay      bool
+}
 
-	if status != "" {
-		query = query.Where("status = ?", status)
-	} else {
-		query = query.Where("status = ?", "live")
+// roomFeedQuery selects rooms with the given status and, when listing live
+// rooms, running relays as one roomFeedRow table. Streamer names are joined
+// in rather than looked up per row.
+func roomFeedQuery(status, category, search string) *gorm.DB {
+	rooms := repository.DB.Model(&models.LiveRoom{}).
+		Select(`live_rooms.id, live_rooms.title, live_rooms.category, live_rooms.cover_url,
+			live_rooms.channel_name, live_rooms.status, live_rooms.streamer_id,
+			COALESCE(NULLIF(users.nickname, ''), users.username, '') AS streamer_name,
+			COALESCE(live_rooms.start_at, live_rooms.created_at) AS started_at,
+			live_rooms.peak_online, live_rooms.total_views, FALSE AS is_relay`).
+		Joins("LEFT JOIN users ON users.id = live_rooms.streamer_id").
+		Where("live_rooms.status = ?", status)
+	if category != "" {
+		rooms = rooms.Where("live_rooms.category IN ?", categoryFilter(category))
+	}
+	if search != "" {
+		rooms = rooms.Where("live_rooms.title ILIKE ?", likePattern(search))
+	}
+	if status != "live" {
+		return repository.DB.Table("(?) AS feed", rooms)
 	}
 
+	relays := repository.DB.Model(&models.RelayStream{}).
+		Select("id, name AS title, category, cover_url, channel_name, status, NULL::uuid AS streamer_id, name AS streamer_name, created_at AS started_at, peak_online, view_count AS total_views, TRUE AS is_relay").
+		Where("status = ?", "running")
 	if category != "" {
-		query = query.Where("category = ?", category)
+		relays = relays.Where("category IN ?", categoryFilter(category))
 	}
-
 	if search != "" {
-		query = query.Where("title ILIKE ?", "%"+search+"%")
-	}
-
-	var rooms []models.LiveRoom
-	query.Order("start_at DESC").Find(&rooms)
-
-	result := make([]RoomListItem, 0)
-	for _, room := range rooms {
-		streamerName := ""
-		if room.StreamerID != uuid.Nil {
-			var user models.User
-			if err := repository.DB.Select("nickname").Where("id = ?", room.StreamerID).First(&user).Error; err == nil {
-				streamerName = user.Nickname
-				if streamerName == "" {
-					streamerName = user.Username
-				}
-			}
-		}
-		item := RoomListItem{
-			ID:          room.ID.String(),
-			Title:       room.Title,
-			Category:    room.Category,
-			CoverURL:    room.CoverURL,
-			ChannelName: room.ChannelName,
-			Status:      room.Status,
-			StreamerID:  room.StreamerID.String(),
-			StartAt:     formatTime(room.StartAt),
-			PeakOnline:  room.PeakOnline,
-			TotalViews:  room.TotalViews,
+		relays = relays.Where("name ILIKE ?", likePattern(search))
+	}
+	return repository.DB.Table("(?) AS feed", repository.DB.Raw("? UNION ALL ?", rooms, relays))
+}
+
+func (row roomFeedRow) listItem() RoomListItem {
+	if row.IsRelay {
+		relayCover := row.CoverURL
+		if relayCover == "" {
+			relayCover = "https://placeholder.com/relay-" + row.Category + ".png"
 		}
-		if room.Status == "live" {
-			item.FLVURL = "http://localhost:8080/live/" + room.ChannelName + ".flv"
-			item.HLSURL = "http://localhost:8080/live/" + room.ChannelName + ".m3u8"
+		return RoomListItem{
+			ID:           row.ID.String(),
+			Title:        row.Title,
+			Category:     row.Category,
+			CoverURL:     relayCover,
+			ChannelName:  row.ChannelName,
+			Status:       row.Status,
+			StreamerID:   "relay-" + row.ID.String()[:8],
+			StreamerName: row.StreamerName,
+			StartAt:      formatTimeFromTime(row.StartedAt),
+			PeakOnline:   row.PeakOnline,
+			TotalViews:   row.TotalViews,
+			FLVURL:       "http://localhost:8080/live/" + row.ChannelName + ".flv",
+			HLSURL:       "http://localhost:8080/live/" + row.ChannelName + ".m3u8",
 		}
-		result = append(result, item)
 	}
 
-	if status == "" || status == "live" {
-		var relays []models.RelayStream
-		relayQuery := repository.DB.Model(&models.RelayStream{}).Where("status = ?", "running")
+	item := RoomListItem{
+		ID:           row.ID.String(),
+		Title:        row.Title,
+		Category:     row.Category,
+		CoverURL:     row.CoverURL,
+		ChannelName:  row.ChannelName,
+		Status:       row.Status,
+		StreamerID:   row.StreamerID.String(),
+		StreamerName: row.StreamerName,
+		StartAt:      formatTimeFromTime(row.StartedAt),
+		PeakOnline:   row.PeakOnline,
+		TotalViews:   row.TotalViews,
+	}
+	if row.Status == "live" {
+		item.FLVURL = "http://localhost:8080/live/" + row.ChannelName + ".flv"
+		item.HLSURL = "http://localhost:8080/live/" + row.ChannelName + ".m3u8"
+	}
+	return item
+}
 
-		if category != "" {
-			relayQuery = relayQuery.Where("category = ?", category)
+// ListRooms pages through rooms and, for live listings, running relays in
+// a single ordering, so a cursor stays valid across both sources.
+func (h *LiveHandler) ListRooms(c *gin.Context) {
+	page, err := pagination.Parse(c.Request.URL.Query(), roomPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	status := page.Filter("status")
+	category := page.Filter("category")
+	search := page.Filter("search")
+	if status == "" {
+		status = "live"
+	}
+	if streamerID := page.Filter("streamer_id"); streamerID != "" {
+		if _, err := uuid.Parse(streamerID); err != nil {
+			response.BadRequest(c, "invalid streamer_id")
+			return
 		}
+	}
+
+	data, err := cache.Fetch(c.Request.Context(), cache.GroupRooms, "list:"+page.Key(), roomListCacheTTL, func() (interface{}, error) {
+		return h.loadRoomPage(page, status, category, search)
+	})
+	if err != nil {
+		response.Fail(c, "failed to load rooms")
+		return
+	}
+
+	response.Success(c, data)
+}
+
+func (h *LiveHandler) loadRoomPage(page pagination.Params, status, category, search string) (*response.Page, error) {
+	feed := roomFeedQuery(status, category, search)
+	if streamerID := page.Filter("streamer_id"); streamerID != "" {
+		feed = feed.Where("NOT is_relay AND streamer_id = ?", streamerID)
+	}
 
-		if search != "" {
-			relayQuery = relayQuery.Where("name ILIKE ?", "%"+search+"%")
+	var rows []roomFeedRow
+	total, err := page.Find(feed, &rows)
+	if err != nil {
+		return nil, err
+	}
+	n, next := page.Next(len(rows), func(i int) (interface{}, interface{}) {
+		switch page.Sort {
+		case "total_views":
+			return rows[i].TotalViews, rows[i].ID
+		case "peak_online":
+			return rows[i].PeakOnline, rows[i].ID
 		}
+		return rows[i].StartedAt, rows[i].ID
+	})
+	rows = rows[:n]
 
-		relayQuery.Order("created_at DESC").Find(&relays)
-
-		for _, relay := range relays {
-			relayCover := relay.CoverURL
-			if relayCover == "" {
-				relayCover = "https://placeholder.com/relay-" + relay.Category + ".png"
-			}
-			startAt := relay.CreatedAt
-			if startAt.IsZero() {
-				startAt = time.Now()
-			}
-			result = append(result, RoomListItem{
-				ID:          relay.ID.String(),
-				Title:       relay.Name,
-				Category:    relay.Category,
-				CoverURL:    relayCover,
-				ChannelName: relay.ChannelName,
-				Status:      relay.Status,
-				StreamerID:  "relay-" + relay.ID.String()[:8],
-				StartAt:     formatTimeFromTime(startAt),
-				PeakOnline:  int(relay.PeakOnline),
-				TotalViews:  int(relay.ViewCount),
-				FLVURL:      "http://localhost:8080/live/" + relay.ChannelName + ".flv",
-				HLSURL:      "http://localhost:8080/live/" + relay.ChannelName + ".m3u8",
-			})
+	result := make([]RoomListItem, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row.listItem())
+	}
+
+	liveIDs := make([]string, 0, len(result))
+	for _, item := range result {
+		if item.Status == "live" || item.Status == "running" {
+			liveIDs = append(liveIDs, item.ID)
 		}
 	}
+	online := roomOnlineCounts(h.centrifugoClient, liveIDs)
+	for i := range result {
+		result[i].Online = online[result[i].ID]
+	}
 
-	response.Success(c, result)
+	return &response.Page{Items: result, NextCursor: next, Total: total}, nil
 }
 
 type StreamerHandler struct{}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/redis"
+)
+
+const (
+	liveNotifyBatchSize = 500
+	liveNotifyQueueSize = 100
+	liveNotifyCooldown  = 10 * time.Minute
+)
+
+type liveNotifyJob struct {
+	StreamerID uuid.UUID
+	RoomID     string
+	Title      string
+}
+
+// LiveNotifier fans "streamer is live" notifications out to followers in the
+// background so that large follower lists never hold up the HTTP request.
+type LiveNotifier struct {
+	centrifugoClient *centrifugo.Client
+	jobs             chan liveNotifyJob
+}
+
+func NewLiveNotifier(centrifugoClient *centrifugo.Client) *LiveNotifier {
+	n := &LiveNotifier{
+		centrifugoClient: centrifugoClient,
+		jobs:             make(chan liveNotifyJob, liveNotifyQueueSize),
+	}
+	go n.run()
+	return n
+}
+
+// NotifyLive queues a go-live fan-out. Repeated calls for the same streamer
+// within liveNotifyCooldown (CreateRoom followed by the SRS publish callback,
+// or a flapping encoder) only notify once.
+func (n *LiveNotifier) NotifyLive(streamerID uuid.UUID, roomID, title string) {
+	if n == nil {
+		return
+	}
+
+	ok, err := redis.SetNX(context.Background(), "golive_notified:"+streamerID.String(), roomID, liveNotifyCooldown)
+	if err == nil && !ok {
+		return
+	}
+
+	select {
+	case n.jobs <- liveNotifyJob{StreamerID: streamerID, RoomID: roomID, Title: title}:
+	default:
+		log.Printf("go-live notify queue full, dropping fan-out for streamer %s", streamerID)
+	}
+}
+
+func (n *LiveNotifier) run() {
+	for job := range n.jobs {
+		n.fanOut(job)
+	}
+}
+
+func (n *LiveNotifier) fanOut(job liveNotifyJob) {
+	streamerName := getNicknameByID(job.StreamerID)
+	title := streamerName + " 开播了"
+	content := job.Title
+	if content == "" {
+		content = streamerName + " 正在直播，快来围观"
+	}
+	link := ""
+	if job.RoomID != "" {
+		link = "/live/" + job.RoomID
+	}
+
+	lastUserID := uuid.Nil
+	sent := 0
+	for {
+		var followerIDs []uuid.UUID
+		if err := repository.DB.Model(&models.FanRelation{}).
+			Where("streamer_id = ? AND live_notify = ? AND user_id > ?", job.StreamerID, true, lastUserID).
+			Order("user_id ASC").
+			Limit(liveNotifyBatchSize).
+			Pluck("user_id", &followerIDs).Error; err != nil {
+			log.Printf("go-live fan-out for streamer %s failed: %v", job.StreamerID, err)
+			return
+		}
+		if len(followerIDs) == 0 {
+			break
+		}
+
+		now := time.Now()
+		notifications := make([]models.Notification, 0, len(followerIDs))
+		for _, followerID := range followerIDs {
+			notifications = append(notifications, models.Notification{
+				ID:        uuid.New(),
+				UserID:    followerID,
+				Type:      "live_start",
+				Title:     title,
+				Content:   content,
+				Link:      link,
+				CreatedAt: now,
+			})
+		}
+		if err := repository.DB.CreateInBatches(&notifications, liveNotifyBatchSize).Error; err != nil {
+			log.Printf("go-live fan-out for streamer %s failed to save notifications: %v", job.StreamerID, err)
+		}
+
+		if n.centrifugoClient != nil {
+			messages := make([]centrifugo.Message, 0, len(notifications))
+			for _, notification := range notifications {
+				messages = append(messages, centrifugo.Message{
+					Channel: centrifugo.UserChannel(notification.UserID.String()),
+					Data: map[string]interface{}{
+						"id":            notification.ID.String(),
+						"type":          "live_start",
+						"streamer_id":   job.StreamerID.String(),
+						"streamer_name": streamerName,
+						"room_id":       job.RoomID,
+						"title":         title,
+						"content":       content,
+						"link":          link,
+						"created_at":    now.Format(time.RFC3339),
+					},
+				})
+			}
+			if err := n.centrifugoClient.PublishBatch(messages); err != nil {
+				log.Printf("go-live fan-out for streamer %s failed to publish: %v", job.StreamerID, err)
+			}
+		}
+
+		sent += len(followerIDs)
+		lastUserID = followerIDs[len(followerIDs)-1]
+		if len(followerIDs) < liveNotifyBatchSize {
+			break
+		}
+	}
+
+	log.Printf("go-live fan-out for streamer %s notified %d followers", job.StreamerID, sent)
+}
@@ -6,9 +6,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
 	"github.com/huya_live/api/internal/repository"
 	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/filter"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
 )
 
 type MessageHandler struct {
@@ -54,31 +58,40 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
+	filtered := filterContent(req.Content, filter.SeverityMedium)
+	if filtered.Blocked {
+		response.BadRequest(c, "内容包含敏感词")
+		return
+	}
+
 	senderUUID := uuid.MustParse(senderID)
 	message := models.PrivateMessage{
 		ID:         uuid.New(),
 		SenderID:   senderUUID,
 		ReceiverID: receiverUUID,
-		Content:    req.Content,
+		Content:    filtered.Text,
 		IsRead:     false,
 		CreatedAt:  time.Now(),
 	}
 
-	if err := repository.DB.Create(&message).Error; err != nil {
-		response.Fail(c, "发送失败")
-		return
-	}
-
-	if h.centrifugoClient != nil {
-		h.centrifugoClient.Publish("user:"+req.ReceiverID, map[string]interface{}{
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		return outbox.Publish(tx, centrifugo.UserChannel(req.ReceiverID), map[string]interface{}{
 			"type":        "private_message",
 			"id":          message.ID.String(),
 			"sender_id":   senderID,
 			"sender_name": getNicknameByID(senderUUID),
-			"content":     req.Content,
+			"content":     message.Content,
 			"created_at":  message.CreatedAt.Format(time.RFC3339),
 		})
+	})
+	if err != nil {
+		response.Fail(c, "发送失败")
+		return
 	}
+	outbox.Wake()
 
 	response.Success(c, gin.H{"message": "发送成功", "data": message})
 }
@@ -92,6 +105,15 @@ func getNicknameByID(userID uuid.UUID) string {
 	return "用户"
 }
 
+var conversationPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"last_time": "c.last_time"},
+	DefaultSort:  "-last_time",
+	Key:          "c.user_id",
+}
+
 func (h *MessageHandler) GetConversations(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -99,7 +121,28 @@ func (h *MessageHandler) GetConversations(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), conversationPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	// One row per counterpart: the latest message either way, plus how many
+	// of theirs are unread.
 	userUUID := uuid.MustParse(userID)
+	latest := repository.DB.Raw(`
+		SELECT DISTINCT ON (other_id) other_id AS user_id,
+			content AS last_message, created_at AS last_time,
+			COUNT(*) FILTER (WHERE receiver_id = ? AND NOT is_read) OVER (PARTITION BY other_id) AS unread_count
+		FROM (
+			SELECT *, CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS other_id
+			FROM private_messages
+			WHERE ? IN (sender_id, receiver_id)
+		) m
+		ORDER BY other_id, created_at DESC
+	`, userUUID, userUUID, userUUID)
+	query := repository.DB.Table("(?) AS c", latest).Joins("JOIN users u ON u.id = c.user_id")
+
 	var conversations []struct {
 		UserID      string    `json:"user_id"`
 		Nickname    string    `json:"nickname"`
@@ -108,22 +151,25 @@ func (h *MessageHandler) GetConversations(c *gin.Context) {
 		LastTime    time.Time `json:"last_time"`
 		UnreadCount int       `json:"unread_count"`
 	}
+	total, err := page.Find(query.Select("c.user_id, u.nickname, u.avatar_url, c.last_message, c.last_time, c.unread_count"), &conversations)
+	if err != nil {
+		response.Fail(c, "获取会话列表失败")
+		return
+	}
+	n, next := page.Next(len(conversations), func(i int) (interface{}, interface{}) {
+		return conversations[i].LastTime, conversations[i].UserID
+	})
+
+	response.Paged(c, conversations[:n], next, total)
+}
 
-	repository.DB.Raw(`
-		SELECT 
-			CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END as user_id,
-			u.nickname, u.avatar_url,
-			m.content as last_message,
-			m.created_at as last_time,
-			(SELECT COUNT(*) FROM private_messages WHERE ((sender_id = ? AND receiver_id = user_id) OR (sender_id = user_id AND receiver_id = ?)) AND is_read = FALSE AND sender_id != ?) as unread_count
-		FROM private_messages m
-		JOIN users u ON u.id = CASE WHEN m.sender_id = ? THEN m.receiver_id ELSE m.sender_id END
-		WHERE ? IN (m.sender_id, m.receiver_id)
-		GROUP BY user_id, u.nickname, u.avatar_url, m.content, m.created_at
-		ORDER BY last_time DESC
-	`, userUUID, userUUID, userUUID, userUUID, userUUID, userUUID).Scan(&conversations)
-
-	response.Success(c, conversations)
+var messagePage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
 }
 
 func (h *MessageHandler) GetMessages(c *gin.Context) {
@@ -140,23 +186,34 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), messagePage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	var messages []models.PrivateMessage
 	userUUID := uuid.MustParse(userID)
 
-	if err := repository.DB.Where(
+	query := repository.DB.Model(&models.PrivateMessage{}).Where(
 		"(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
 		userUUID, otherUUID, otherUUID, userUUID,
-	).Order("created_at DESC").Limit(100).Find(&messages).Error; err != nil {
+	)
+	total, err := page.Find(query, &messages)
+	if err != nil {
 		response.Fail(c, "获取消息失败")
 		return
 	}
+	n, next := page.Next(len(messages), func(i int) (interface{}, interface{}) {
+		return messages[i].CreatedAt, messages[i].ID
+	})
 
 	repository.DB.Model(&models.PrivateMessage{}).Where(
 		"sender_id = ? AND receiver_id = ? AND is_read = ?",
 		otherUUID, userUUID, false,
 	).Update("is_read", true)
 
-	response.Success(c, messages)
+	response.Paged(c, messages[:n], next, total)
 }
 
 func (h *MessageHandler) GetUnreadMessageCount(c *gin.Context) {
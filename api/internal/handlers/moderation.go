@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/pagination"
+	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	penaltyMute = "mute"
+	penaltyBan  = "ban"
+	// penaltyKick is a short ban of its own type, so that kicking a banned
+	// user never replaces or shortens their ban.
+	penaltyKick = "kick"
+
+	roomKickDuration   = 10 * time.Minute
+	maxPenaltyDuration = 30 * 24 * 3600
+)
+
+type roomRole int
+
+const (
+	roomRoleViewer roomRole = iota
+	roomRoleModerator
+	roomRoleOwner
+)
+
+type ModerationHandler struct {
+	centrifugoClient *centrifugo.Client
+}
+
+func NewModerationHandler(centrifugoClient *centrifugo.Client) *ModerationHandler {
+	return &ModerationHandler{centrifugoClient: centrifugoClient}
+}
+
+type AddModeratorRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type PenaltyRequest struct {
+	UserID   string `json:"user_id" binding:"required"`
+	Duration int    `json:"duration" binding:"min=0"` // seconds, 0 means permanent
+	Reason   string `json:"reason" binding:"max=200"`
+}
+
+type KickRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	Reason string `json:"reason" binding:"max=200"`
+}
+
+var moderatorPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "m.created_at"},
+	DefaultSort:  "created_at",
+	Key:          "m.user_id",
+}
+
+func (h *ModerationHandler) ListModerators(c *gin.Context) {
+	streamerID, ok := roomStreamerID(c.Param("id"))
+	if !ok {
+		response.BadRequest(c, "room not found")
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), moderatorPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Table("room_moderators m").
+		Joins("JOIN users u ON u.id = m.user_id").
+		Where("m.streamer_id = ?", streamerID)
+
+	var moderators []struct {
+		UserID    string    `json:"user_id"`
+		Username  string    `json:"username"`
+		Nickname  string    `json:"nickname"`
+		AvatarURL string    `json:"avatar_url"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	total, err := page.Find(query.Select("m.user_id, u.username, u.nickname, u.avatar_url, m.created_at"), &moderators)
+	if err != nil {
+		response.Fail(c, "failed to load moderators")
+		return
+	}
+	n, next := page.Next(len(moderators), func(i int) (interface{}, interface{}) {
+		return moderators[i].CreatedAt, moderators[i].UserID
+	})
+
+	response.Paged(c, moderators[:n], next, total)
+}
+
+func (h *ModerationHandler) AddModerator(c *gin.Context) {
+	streamerID, actorID, ok := h.authorize(c, roomRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req AddModeratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	targetID, err := uuid.Parse(req.UserID)
+	if err != nil || targetID == streamerID {
+		response.BadRequest(c, "invalid user_id")
+		return
+	}
+
+	var count int64
+	repository.DB.Model(&models.User{}).Where("id = ?", targetID).Count(&count)
+	if count == 0 {
+		response.BadRequest(c, "user not found")
+		return
+	}
+
+	moderator := models.RoomModerator{
+		StreamerID: streamerID,
+		UserID:     targetID,
+		CreatedBy:  actorID,
+	}
+	if err := repository.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&moderator).Error; err != nil {
+		response.Fail(c, "failed to add moderator")
+		return
+	}
+
+	recordModeration(streamerID, actorID, &targetID, "moderator_add", "", "")
+	h.publishModeration(c.Param("id"), targetID, "moderator_add", nil, "")
+
+	response.Success(c, gin.H{"message": "moderator added"})
+}
+
+func (h *ModerationHandler) RemoveModerator(c *gin.Context) {
+	streamerID, actorID, ok := h.authorize(c, roomRoleOwner)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		response.BadRequest(c, "invalid user_id")
+		return
+	}
+
+	result := repository.DB.Where("streamer_id = ? AND user_id = ?", streamerID, targetID).Delete(&models.RoomModerator{})
+	if result.Error != nil {
+		response.Fail(c, "failed to remove moderator")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "user is not a moderator")
+		return
+	}
+
+	recordModeration(streamerID, actorID, &targetID, "moderator_remove", "", "")
+	h.publishModeration(c.Param("id"), targetID, "moderator_remove", nil, "")
+
+	response.Success(c, gin.H{"message": "moderator removed"})
+}
+
+func (h *ModerationHandler) Mute(c *gin.Context) {
+	h.penalize(c, penaltyMute)
+}
+
+func (h *ModerationHandler) Ban(c *gin.Context) {
+	h.penalize(c, penaltyBan)
+}
+
+func (h *ModerationHandler) Unmute(c *gin.Context) {
+	h.revoke(c, penaltyMute)
+}
+
+func (h *ModerationHandler) Unban(c *gin.Context) {
+	h.revoke(c, penaltyBan)
+}
+
+// Kick removes the user from the room channel and bans them for a short
+// while so they cannot rejoin right away.
+func (h *ModerationHandler) Kick(c *gin.Context) {
+	streamerID, actorID, ok := h.authorize(c, roomRoleModerator)
+	if !ok {
+		return
+	}
+
+	var req KickRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	targetID, ok := h.checkTarget(c, streamerID, actorID, req.UserID)
+	if !ok {
+		return
+	}
+
+	expiresAt := time.Now().Add(roomKickDuration)
+	if err := applyRoomPenalty(streamerID, targetID, actorID, penaltyKick, req.Reason, &expiresAt); err != nil {
+		response.Fail(c, "failed to kick user")
+		return
+	}
+
+	recordModeration(streamerID, actorID, &targetID, "kick", req.Reason, "")
+	h.publishModeration(c.Param("id"), targetID, "kick", &expiresAt, req.Reason)
+	h.centrifugoClient.Unsubscribe(centrifugo.GetChannels(c.Param("id"))[0], targetID.String())
+
+	response.Success(c, gin.H{
+		"message":    "user kicked",
+		"expires_at": expiresAt,
+	})
+}
+
+var penaltyPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "p.created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "p.id",
+	Filters:      map[string][]string{"type": {penaltyMute, penaltyBan, penaltyKick}},
+}
+
+func (h *ModerationHandler) ListPenalties(c *gin.Context) {
+	streamerID, _, ok := h.authorize(c, roomRoleModerator)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), penaltyPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Table("room_penalties p").
+		Joins("JOIN users u ON u.id = p.user_id").
+		Where("p.streamer_id = ? AND p.revoked_at IS NULL", streamerID).
+		Where("(p.expires_at IS NULL OR p.expires_at > ?)", time.Now())
+	if t := page.Filter("type"); t != "" {
+		query = query.Where("p.type = ?", t)
+	}
+
+	var penalties []struct {
+		ID        string     `json:"id"`
+		UserID    string     `json:"user_id"`
+		Username  string     `json:"username"`
+		Nickname  string     `json:"nickname"`
+		Type      string     `json:"type"`
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		CreatedBy string     `json:"created_by"`
+		CreatedAt time.Time  `json:"created_at"`
+	}
+	total, err := page.Find(query.Select(`p.id, p.user_id, u.username, u.nickname, p.type, p.reason,
+		p.expires_at, p.created_by, p.created_at`), &penalties)
+	if err != nil {
+		response.Fail(c, "failed to load penalties")
+		return
+	}
+	n, next := page.Next(len(penalties), func(i int) (interface{}, interface{}) {
+		return penalties[i].CreatedAt, penalties[i].ID
+	})
+
+	response.Paged(c, penalties[:n], next, total)
+}
+
+// ListViewers lists the users currently in the room, from Centrifugo
+// presence. Anonymous connections are counted but not listed.
+func (h *ModerationHandler) ListViewers(c *gin.Context) {
+	if _, _, ok := h.authorize(c, roomRoleModerator); !ok {
+		return
+	}
+
+	presence, err := h.centrifugoClient.Presence(centrifugo.GetChannels(c.Param("id"))[0])
+	if err != nil {
+		response.Fail(c, "failed to load viewers")
+		return
+	}
+
+	viewers := make([]gin.H, 0, len(presence))
+	seen := make(map[string]bool, len(presence))
+	anonymous := 0
+	for _, info := range presence {
+		if info.User == "" {
+			anonymous++
+			continue
+		}
+		if seen[info.User] {
+			continue
+		}
+		seen[info.User] = true
+		viewers = append(viewers, gin.H{
+			"user_id":   info.User,
+			"conn_info": info.ConnInfo,
+		})
+	}
+
+	response.Success(c, gin.H{
+		"viewers":     viewers,
+		"connections": len(presence),
+		"anonymous":   anonymous,
+	})
+}
+
+var moderationLogPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
+	Filters:      map[string][]string{"action": nil},
+}
+
+func (h *ModerationHandler) ListModerationLogs(c *gin.Context) {
+	streamerID, _, ok := h.authorize(c, roomRoleModerator)
+	if !ok {
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), moderationLogPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.ModerationLog{}).Where("streamer_id = ?", streamerID)
+	if action := page.Filter("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var logs []models.ModerationLog
+	total, err := page.Find(query, &logs)
+	if err != nil {
+		response.Fail(c, "failed to load moderation logs")
+		return
+	}
+	n, next := page.Next(len(logs), func(i int) (interface{}, interface{}) {
+		return logs[i].CreatedAt, logs[i].ID
+	})
+
+	response.Paged(c, logs[:n], next, total)
+}
+
+func (h *ModerationHandler) penalize(c *gin.Context, penaltyType string) {
+	streamerID, actorID, ok := h.authorize(c, roomRoleModerator)
+	if !ok {
+		return
+	}
+
+	var req PenaltyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+	if req.Duration > maxPenaltyDuration {
+		response.BadRequest(c, "duration too long")
+		return
+	}
+
+	targetID, ok := h.checkTarget(c, streamerID, actorID, req.UserID)
+	if !ok {
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.Duration > 0 {
+		t := time.Now().Add(time.Duration(req.Duration) * time.Second)
+		expiresAt = &t
+	}
+
+	if err := applyRoomPenalty(streamerID, targetID, actorID, penaltyType, req.Reason, expiresAt); err != nil {
+		response.Fail(c, "failed to "+penaltyType+" user")
+		return
+	}
+
+	recordModeration(streamerID, actorID, &targetID, penaltyType, req.Reason, "")
+	h.publishModeration(c.Param("id"), targetID, penaltyType, expiresAt, req.Reason)
+	if penaltyType == penaltyBan {
+		h.centrifugoClient.Unsubscribe(centrifugo.GetChannels(c.Param("id"))[0], targetID.String())
+	}
+
+	response.Success(c, gin.H{
+		"message":    penaltyType + " applied",
+		"expires_at": expiresAt,
+	})
+}
+
+func (h *ModerationHandler) revoke(c *gin.Context, penaltyType string) {
+	streamerID, actorID, ok := h.authorize(c, roomRoleModerator)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		response.BadRequest(c, "invalid user_id")
+		return
+	}
+
+	result := repository.DB.Model(&models.RoomPenalty{}).
+		Where("streamer_id = ? AND user_id = ? AND type = ? AND revoked_at IS NULL", streamerID, targetID, penaltyType).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		response.Fail(c, "failed to update penalty")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "no active "+penaltyType+" for this user")
+		return
+	}
+
+	recordModeration(streamerID, actorID, &targetID, "un"+penaltyType, "", "")
+	h.publishModeration(c.Param("id"), targetID, "un"+penaltyType, nil, "")
+
+	response.Success(c, gin.H{"message": penaltyType + " revoked"})
+}
+
+// authorize resolves the room to its streamer and checks that the caller
+// holds at least the given role there.
+func (h *ModerationHandler) authorize(c *gin.Context, min roomRole) (uuid.UUID, uuid.UUID, bool) {
+	streamerID, ok := roomStreamerID(c.Param("id"))
+	if !ok {
+		response.BadRequest(c, "room not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		response.Unauthorized(c, "unauthorized")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if roomRoleOf(streamerID, actorID, c.GetString("user_role")) < min {
+		response.Forbidden(c, "permission denied")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return streamerID, actorID, true
+}
+
+// checkTarget rejects penalties against the owner and, unless the actor is
+// the owner, against other moderators.
+func (h *ModerationHandler) checkTarget(c *gin.Context, streamerID, actorID uuid.UUID, userID string) (uuid.UUID, bool) {
+	targetID, err := uuid.Parse(userID)
+	if err != nil || targetID == actorID {
+		response.BadRequest(c, "invalid user_id")
+		return uuid.Nil, false
+	}
+
+	targetRole := roomRoleOf(streamerID, targetID, "")
+	if targetRole == roomRoleOwner {
+		response.Forbidden(c, "cannot moderate the room owner")
+		return uuid.Nil, false
+	}
+	if targetRole == roomRoleModerator && roomRoleOf(streamerID, actorID, c.GetString("user_role")) < roomRoleOwner {
+		response.Forbidden(c, "cannot moderate another moderator")
+		return uuid.Nil, false
+	}
+
+	return targetID, true
+}
+
+func (h *ModerationHandler) publishModeration(roomID string, userID uuid.UUID, action string, expiresAt *time.Time, reason string) {
+	event := gin.H{
+		"type":      "moderation",
+		"timestamp": time.Now().UnixMilli(),
+		"data": gin.H{
+			"action":     action,
+			"room_id":    roomID,
+			"user_id":    userID.String(),
+			"expires_at": expiresAt,
+			"reason":     reason,
+		},
+	}
+	h.centrifugoClient.Publish(centrifugo.GetChannels(roomID)[0], event)
+	h.centrifugoClient.Publish(centrifugo.UserChannel(userID.String()), event)
+}
+
+func roomStreamerID(roomID string) (uuid.UUID, bool) {
+	var room models.LiveRoom
+	if err := repository.DB.Select("streamer_id").Where("id = ?", roomID).First(&room).Error; err != nil {
+		return uuid.Nil, false
+	}
+	return room.StreamerID, true
+}
+
+// roomRoleOf returns the user's role in a streamer's room. Site admins are
+// treated as owners everywhere.
+func roomRoleOf(streamerID, userID uuid.UUID, siteRole string) roomRole {
+	if userID == streamerID || siteRole == "admin" {
+		return roomRoleOwner
+	}
+
+	var count int64
+	repository.DB.Model(&models.RoomModerator{}).
+		Where("streamer_id = ? AND user_id = ?", streamerID, userID).
+		Count(&count)
+	if count > 0 {
+		return roomRoleModerator
+	}
+	return roomRoleViewer
+}
+
+// activeRoomPenalty returns the user's current penalty of any of the given
+// types in the streamer's room, or nil.
+func activeRoomPenalty(streamerID, userID uuid.UUID, types ...string) *models.RoomPenalty {
+	var penalty models.RoomPenalty
+	err := repository.DB.
+		Where("streamer_id = ? AND user_id = ? AND type IN ? AND revoked_at IS NULL", streamerID, userID, types).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("expires_at DESC NULLS FIRST").
+		First(&penalty).Error
+	if err != nil {
+		return nil
+	}
+	return &penalty
+}
+
+func penaltyMessage(p *models.RoomPenalty) string {
+	action := "muted in"
+	switch p.Type {
+	case penaltyBan:
+		action = "banned from"
+	case penaltyKick:
+		action = "kicked from"
+	}
+	if p.ExpiresAt == nil {
+		return "you are " + action + " this room"
+	}
+	return "you are " + action + " this room until " + p.ExpiresAt.Format(time.RFC3339)
+}
+
+// applyRoomPenalty replaces any active penalty of the same type so that the
+// latest decision wins, whether it is longer or shorter.
+func applyRoomPenalty(streamerID, userID, actorID uuid.UUID, penaltyType, reason string, expiresAt *time.Time) error {
+	now := time.Now()
+	if err := repository.DB.Model(&models.RoomPenalty{}).
+		Where("streamer_id = ? AND user_id = ? AND type = ? AND revoked_at IS NULL", streamerID, userID, penaltyType).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	return repository.DB.Create(&models.RoomPenalty{
+		StreamerID: streamerID,
+		UserID:     userID,
+		Type:       penaltyType,
+		Reason:     reason,
+		ExpiresAt:  expiresAt,
+		CreatedBy:  actorID,
+	}).Error
+}
+
+func recordModeration(streamerID, actorID uuid.UUID, targetID *uuid.UUID, action, reason, detail string) {
+	repository.DB.Create(&models.ModerationLog{
+		StreamerID: streamerID,
+		ActorID:    actorID,
+		TargetID:   targetID,
+		Action:     action,
+		Reason:     reason,
+		Detail:     detail,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/internal/leaderboard"
+	"github.com/huya_live/api/pkg/response"
+)
+
+type NobleTier struct {
+	Tier    int    `json:"tier"`
+	Name    string `json:"name"`
+	MaxRank int64  `json:"max_rank"`
+}
+
+// nobleTiers are earned by ranking on the monthly spending board, highest
+// tier first. Tier 0 means no title.
+var nobleTiers = []NobleTier{
+	{Tier: 6, Name: "国王", MaxRank: 1},
+	{Tier: 5, Name: "公爵", MaxRank: 3},
+	{Tier: 4, Name: "侯爵", MaxRank: 10},
+	{Tier: 3, Name: "伯爵", MaxRank: 30},
+	{Tier: 2, Name: "子爵", MaxRank: 100},
+	{Tier: 1, Name: "骑士", MaxRank: 300},
+}
+
+func nobleTierForRank(rank int64) NobleTier {
+	if rank <= 0 {
+		return NobleTier{}
+	}
+	for _, t := range nobleTiers {
+		if rank <= t.MaxRank {
+			return t
+		}
+	}
+	return NobleTier{}
+}
+
+// nobleTierName names a tier on the shared earned/purchased scale.
+func nobleTierName(tier int) string {
+	for _, t := range nobleTiers {
+		if t.Tier == tier {
+			return t.Name
+		}
+	}
+	return ""
+}
+
+// earnedNobleTier looks up the user's rank on this month's spending board.
+// Opting out of public rankings does not forfeit the title.
+func earnedNobleTier(ctx context.Context, userID string) (NobleTier, int64, int64) {
+	key, _ := leaderboard.PeriodKey(leaderboard.BoardGifter, leaderboard.PeriodMonthly, time.Now())
+	rank, score, err := leaderboard.Rank(ctx, key, userID)
+	if err != nil {
+		return NobleTier{}, 0, 0
+	}
+	return nobleTierForRank(rank), rank, score
+}
+
+func (h *LeaderboardHandler) GetNobleTiers(c *gin.Context) {
+	response.Success(c, nobleTiers)
+}
+
+// GetMyNoble returns the caller's noble title and how it was earned.
+func (h *LeaderboardHandler) GetMyNoble(c *gin.Context) {
+	tier, rank, score := earnedNobleTier(c.Request.Context(), c.GetString("user_id"))
+	response.Success(c, gin.H{
+		"tier":          tier.Tier,
+		"name":          tier.Name,
+		"monthly_rank":  rank,
+		"monthly_spent": score,
+	})
+}
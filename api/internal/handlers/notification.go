@@ -6,8 +6,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
 )
 
 type NotificationHandler struct{}
@@ -16,6 +20,19 @@ func NewNotificationHandler() *NotificationHandler {
 	return &NotificationHandler{}
 }
 
+var notificationPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
+	Filters: map[string][]string{
+		"is_read": {"true", "false"},
+		"type":    nil,
+	},
+}
+
 func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -23,13 +40,31 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), notificationPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if isRead := page.Filter("is_read"); isRead != "" {
+		query = query.Where("is_read = ?", isRead == "true")
+	}
+	if t := page.Filter("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+
 	var notifications []models.Notification
-	if err := repository.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(50).Find(&notifications).Error; err != nil {
+	total, err := page.Find(query, &notifications)
+	if err != nil {
 		response.Fail(c, "获取通知失败")
 		return
 	}
+	n, next := page.Next(len(notifications), func(i int) (interface{}, interface{}) {
+		return notifications[i].CreatedAt, notifications[i].ID
+	})
 
-	response.Success(c, notifications)
+	response.Paged(c, notifications[:n], next, total)
 }
 
 func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
@@ -105,3 +140,29 @@ func CreateNotification(userID uuid.UUID, notifType, title, content, link string
 	}
 	return repository.DB.Create(&notification).Error
 }
+
+// notifyUser stores a notification inside tx and queues the real-time push
+// to the user's channel with it.
+func notifyUser(tx *gorm.DB, userID uuid.UUID, notifType, title, content, link string) error {
+	notification := models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      notifType,
+		Title:     title,
+		Content:   content,
+		Link:      link,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	return outbox.Publish(tx, centrifugo.UserChannel(userID.String()), map[string]interface{}{
+		"type":       notifType,
+		"id":         notification.ID.String(),
+		"title":      title,
+		"content":    content,
+		"link":       link,
+		"created_at": notification.CreatedAt.Format(time.RFC3339),
+	})
+}
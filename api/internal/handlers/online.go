@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+)
+
+const (
+	onlineCountTTL = 5 * time.Second
+	// onlineCountWorkers bounds the concurrent presence calls one lookup
+	// makes; the feed and PeakOnlineJob ask for hundreds of rooms at once.
+	onlineCountWorkers = 8
+)
+
+type onlineCountEntry struct {
+	count     int
+	fetchedAt time.Time
+}
+
+var onlineCounts = struct {
+	sync.Mutex
+	entries map[string]onlineCountEntry
+}{entries: make(map[string]onlineCountEntry)}
+
+// roomOnlineCounts reports how many clients are subscribed to each room's
+// public channel. Counts are cached for a few seconds and fetched from
+// Centrifugo on a miss, at most onlineCountWorkers at a time; a room whose count cannot be fetched
+// reports 0. It never writes; PeakOnlineJob records new highs.
+func roomOnlineCounts(client *centrifugo.Client, roomIDs []string) map[string]int {
+	counts := make(map[string]int, len(roomIDs))
+	var missing []string
+
+	onlineCounts.Lock()
+	for _, id := range roomIDs {
+		if e, ok := onlineCounts.entries[id]; ok && time.Since(e.fetchedAt) < onlineCountTTL {
+			counts[id] = e.count
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	onlineCounts.Unlock()
+
+	if len(missing) == 0 {
+		return counts
+	}
+
+	ids := make(chan string)
+	go func() {
+		for _, id := range missing {
+			ids <- id
+		}
+		close(ids)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < onlineCountWorkers && i < len(missing); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				stats, err := client.PresenceStats(centrifugo.GetChannels(id)[0])
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				counts[id] = stats.NumClients
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	now := time.Now()
+	onlineCounts.Lock()
+	for _, id := range missing {
+		if count, ok := counts[id]; ok {
+			onlineCounts.entries[id] = onlineCountEntry{count: count, fetchedAt: now}
+		}
+	}
+	onlineCounts.Unlock()
+
+	return counts
+}
+
+// PeakOnlineJob returns the periodic job that samples the viewer count of
+// every live room and records new highs in peak_online.
+func PeakOnlineJob(client *centrifugo.Client) func() (string, error) {
+	return func() (string, error) {
+		var ids []string
+		if err := repository.DB.Model(&models.LiveRoom{}).Where("status = ?", "live").Pluck("id", &ids).Error; err != nil {
+			return "", err
+		}
+
+		updated := int64(0)
+		for id, count := range roomOnlineCounts(client, ids) {
+			if count == 0 {
+				continue
+			}
+			result := repository.DB.Model(&models.LiveRoom{}).
+				Where("id = ? AND peak_online < ?", id, count).
+				Update("peak_online", count)
+			if result.Error != nil {
+				return "", result.Error
+			}
+			updated += result.RowsAffected
+		}
+		return fmt.Sprintf("rooms=%d peaks_updated=%d", len(ids), updated), nil
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huya_live/api/pkg/centrifugo"
+)
+
+func TestRoomOnlineCountsBoundsConcurrency(t *testing.T) {
+	var inFlight, peak, calls int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		atomic.AddInt64(&calls, 1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprint(w, `{"result":{"num_clients":3,"num_users":2}}`)
+	}))
+	defer stub.Close()
+	client := centrifugo.NewClient(centrifugo.Config{URL: stub.URL, APIKey: "test"})
+
+	ids := make([]string, 5*onlineCountWorkers)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+	}
+	counts := roomOnlineCounts(client, ids)
+
+	if len(counts) != len(ids) {
+		t.Fatalf("got counts for %d rooms, want %d", len(counts), len(ids))
+	}
+	for id, count := range counts {
+		if count != 3 {
+			t.Errorf("room %s count = %d, want 3", id, count)
+		}
+	}
+	if got := atomic.LoadInt64(&calls); got != int64(len(ids)) {
+		t.Errorf("made %d presence calls, want %d", got, len(ids))
+	}
+	if got := atomic.LoadInt64(&peak); got > onlineCountWorkers {
+		t.Errorf("%d presence calls in flight at once, want at most %d", got, onlineCountWorkers)
+	}
+
+	// A second lookup within the TTL is served from the cache.
+	roomOnlineCounts(client, ids)
+	if got := atomic.LoadInt64(&calls); got != int64(len(ids)) {
+		t.Errorf("cached lookup made %d more presence calls", got-int64(len(ids)))
+	}
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/leaderboard"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/redis"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// queryTest runs handlers against a mocked Postgres, an in-memory Redis and
+// a stub Centrifugo, and counts every statement GORM sends.
+type queryTest struct {
+	mock       sqlmock.Sqlmock
+	queries    int64
+	centrifugo *centrifugo.Client
+}
+
+func newQueryTest(t *testing.T) *queryTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	// Statements are matched by order, not text; each test lists the
+	// result sets in the order the handler should ask for them.
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(string, string) error { return nil })))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+
+	qt := &queryTest{mock: mock}
+	// GORM also runs these callbacks in dry-run mode to render subqueries;
+	// only statements that reach the database count.
+	count := func(tx *gorm.DB) {
+		if !tx.DryRun {
+			atomic.AddInt64(&qt.queries, 1)
+		}
+	}
+	db.Callback().Query().Before("gorm:query").Register("test:count_query", count)
+	db.Callback().Row().Before("gorm:row").Register("test:count_row", count)
+	db.Callback().Raw().Before("gorm:raw").Register("test:count_raw", count)
+
+	prevDB := repository.DB
+	repository.DB = db
+	t.Cleanup(func() { repository.DB = prevDB })
+
+	mr := miniredis.RunT(t)
+	if err := redis.Init(mr.Addr(), "", 0); err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"result":{"num_clients":3,"num_users":2}}`)
+	}))
+	t.Cleanup(stub.Close)
+	qt.centrifugo = centrifugo.NewClient(centrifugo.Config{URL: stub.URL, APIKey: "test"})
+
+	return qt
+}
+
+// serve calls handler with a GET request for target and returns the number
+// of statements it ran.
+func (qt *queryTest) serve(t *testing.T, handler gin.HandlerFunc, target string, setup func(c *gin.Context)) int64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	if setup != nil {
+		setup(c)
+	}
+
+	before := atomic.LoadInt64(&qt.queries)
+	handler(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", target, w.Code, w.Body.String())
+	}
+	return atomic.LoadInt64(&qt.queries) - before
+}
+
+func (qt *queryTest) expectRows(columns []string, rows ...[]interface{}) {
+	result := sqlmock.NewRows(columns)
+	for _, row := range rows {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			values[i] = v
+		}
+		result.AddRow(values...)
+	}
+	qt.mock.ExpectQuery("").WillReturnRows(result)
+}
+
+func (qt *queryTest) expectCount(n int) {
+	qt.expectRows([]string{"count"}, []interface{}{n})
+}
+
+func (qt *queryTest) verify(t *testing.T) {
+	t.Helper()
+	if err := qt.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListRoomsQueryCount(t *testing.T) {
+	qt := newQueryTest(t)
+	h := NewLiveHandler(nil, qt.centrifugo)
+
+	now := time.Now()
+	columns := []string{"id", "title", "category", "status", "streamer_id", "streamer_name", "started_at", "peak_online", "total_views", "is_relay"}
+	var rows [][]interface{}
+	for i := 0; i < 5; i++ {
+		rows = append(rows, []interface{}{uuid.New(), fmt.Sprintf("room %d", i), "game", "live", uuid.New(), "streamer", now, 10, 100, false})
+	}
+	qt.expectCount(len(rows))
+	qt.expectRows(columns, rows...)
+
+	// A page of rooms is one count and one select, however many rooms it
+	// holds; streamer names and viewer counts are not looked up per row.
+	if got := qt.serve(t, h.ListRooms, "/api/live/rooms?limit=10", nil); got != 2 {
+		t.Errorf("ListRooms ran %d queries, want 2", got)
+	}
+	// The same page again is served from the cache, also when parameters
+	// outside the page spec differ.
+	for _, target := range []string{"/api/live/rooms?limit=10", "/api/live/rooms?_=1700000000&limit=10"} {
+		if got := qt.serve(t, h.ListRooms, target, nil); got != 0 {
+			t.Errorf("cached ListRooms %s ran %d queries, want 0", target, got)
+		}
+	}
+	qt.verify(t)
+}
+
+func TestGetGlobalLeaderboardQueryCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		setup  func(qt *queryTest)
+		want   int64
+	}{
+		{
+			name:   "lifetime",
+			target: "/api/leaderboard/global",
+			setup: func(qt *queryTest) {
+				var rows [][]interface{}
+				for i := 0; i < 5; i++ {
+					id := uuid.New()
+					rows = append(rows, []interface{}{id, int64(1000 - i), id, "streamer"})
+				}
+				qt.expectRows([]string{"user_id", "total_revenue", "User__id", "User__nickname"}, rows...)
+			},
+			want: 1,
+		},
+		{
+			name:   "weekly",
+			target: "/api/leaderboard/global?period=weekly",
+			setup: func(qt *queryTest) {
+				var rows [][]interface{}
+				for i := 0; i < 5; i++ {
+					streamerID := uuid.New()
+					if err := leaderboard.Record(context.Background(), uuid.NewString(), streamerID.String(), uuid.NewString(), int64(100+i), time.Now()); err != nil {
+						panic(err)
+					}
+					rows = append(rows, []interface{}{streamerID, "streamer"})
+				}
+				qt.expectRows([]string{"id", "nickname"}, rows...)
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt := newQueryTest(t)
+			tt.setup(qt)
+			h := NewLeaderboardHandler(qt.centrifugo)
+			if got := qt.serve(t, h.GetGlobalLeaderboard, tt.target, nil); got != tt.want {
+				t.Errorf("GetGlobalLeaderboard ran %d queries, want %d", got, tt.want)
+			}
+			qt.verify(t)
+		})
+	}
+}
+
+func TestGetRichListQueryCount(t *testing.T) {
+	qt := newQueryTest(t)
+
+	var users [][]interface{}
+	for i := 0; i < 5; i++ {
+		senderID := uuid.New()
+		users = append(users, []interface{}{senderID, "viewer", false})
+		if err := leaderboard.Record(context.Background(), uuid.NewString(), uuid.NewString(), senderID.String(), int64(300-i), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	qt.expectRows([]string{"id", "nickname", "hide_from_rankings"}, users...)
+
+	// The lifetime list reads the maintained Redis board; only the users
+	// behind it are loaded, nothing is summed per request.
+	h := NewLeaderboardHandler(qt.centrifugo)
+	if got := qt.serve(t, h.GetRichList, "/api/leaderboard/rich", nil); got != 1 {
+		t.Errorf("GetRichList ran %d queries, want 1", got)
+	}
+	qt.verify(t)
+}
+
+func TestGetRoomLeaderboardQueryCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		redis bool
+		want  int64
+	}{
+		// Scores come from Redis; only the users are loaded.
+		{name: "redis", redis: true, want: 1},
+		// Without the Redis board, scores are summed in one query.
+		{name: "fallback", redis: false, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt := newQueryTest(t)
+			roomID := uuid.NewString()
+
+			var users [][]interface{}
+			var scores [][]interface{}
+			for i := 0; i < 5; i++ {
+				senderID := uuid.New()
+				users = append(users, []interface{}{senderID, "viewer"})
+				scores = append(scores, []interface{}{senderID.String(), int64(500 - i)})
+				if tt.redis {
+					if err := leaderboard.Record(context.Background(), roomID, uuid.NewString(), senderID.String(), int64(500-i), time.Now()); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if !tt.redis {
+				// A wrong-type key makes the Redis read fail.
+				if err := redis.GetClient().Set(context.Background(), leaderboard.RoomKey(roomID), "x", 0).Err(); err != nil {
+					t.Fatal(err)
+				}
+				qt.expectRows([]string{"member", "total"}, scores...)
+			}
+			qt.expectRows([]string{"id", "nickname"}, users...)
+
+			h := NewLeaderboardHandler(qt.centrifugo)
+			got := qt.serve(t, h.GetRoomLeaderboard, "/api/leaderboard/rooms/"+roomID, func(c *gin.Context) {
+				c.Params = gin.Params{{Key: "room_id", Value: roomID}}
+			})
+			if got != tt.want {
+				t.Errorf("GetRoomLeaderboard ran %d queries, want %d", got, tt.want)
+			}
+			qt.verify(t)
+		})
+	}
+}
+
+func TestGetFollowingsQueryCount(t *testing.T) {
+	qt := newQueryTest(t)
+	userID := uuid.New()
+
+	now := time.Now()
+	columns := []string{"user_id", "streamer_id", "fan_level", "followed_at", "Streamer__id", "Streamer__nickname"}
+	var rows [][]interface{}
+	for i := 0; i < 5; i++ {
+		streamerID := uuid.New()
+		rows = append(rows, []interface{}{userID, streamerID, 1, now.Add(-time.Duration(i) * time.Hour), streamerID, "streamer"})
+	}
+	qt.expectCount(len(rows))
+	qt.expectRows(columns, rows...)
+
+	// Streamer profiles are joined in, not loaded per relation.
+	h := NewSocialHandler()
+	got := qt.serve(t, h.GetFollowings, "/api/social/followings", func(c *gin.Context) {
+		c.Set("user_id", userID.String())
+	})
+	if got != 2 {
+		t.Errorf("GetFollowings ran %d queries, want 2", got)
+	}
+	qt.verify(t)
+}
+
+func TestGetConversationsQueryCount(t *testing.T) {
+	qt := newQueryTest(t)
+	userID := uuid.New()
+
+	now := time.Now()
+	columns := []string{"user_id", "nickname", "avatar_url", "last_message", "last_time", "unread_count"}
+	var rows [][]interface{}
+	for i := 0; i < 5; i++ {
+		rows = append(rows, []interface{}{uuid.NewString(), "friend", "", "hi", now.Add(-time.Duration(i) * time.Minute), i})
+	}
+	qt.expectCount(len(rows))
+	qt.expectRows(columns, rows...)
+
+	// Unread counts come with the latest messages, not one query each.
+	h := NewMessageHandler(qt.centrifugo)
+	got := qt.serve(t, h.GetConversations, "/api/messages/conversations", func(c *gin.Context) {
+		c.Set("user_id", userID.String())
+	})
+	if got != 2 {
+		t.Errorf("GetConversations ran %d queries, want 2", got)
+	}
+	qt.verify(t)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -26,7 +27,7 @@ func NewRelayHandler() *RelayHandler {
 
 type CreateRelayRequest struct {
 	Name        string `json:"name" binding:"required,min=2,max=100"`
-	Description string `json:"description" max=500"`
+	Description string `json:"description" binding:"max=500"`
 	SourceURL   string `json:"source_url" binding:"required"`
 	SourceType  string `json:"source_type"`
 	Category    string `json:"category"`
@@ -35,8 +36,8 @@ type CreateRelayRequest struct {
 }
 
 type UpdateRelayRequest struct {
-	Name        string `json:"name" max=100"`
-	Description string `json:"description" max=500"`
+	Name        string `json:"name" binding:"omitempty,min=2,max=100"`
+	Description string `json:"description" binding:"max=500"`
 	Category    string `json:"category"`
 	CoverURL    string `json:"cover_url"`
 	AutoStart   *bool  `json:"auto_start"`
@@ -86,6 +87,12 @@ func (h *RelayHandler) CreateRelay(c *gin.Context) {
 		return
 	}
 
+	category, ok := resolveCategory(req.Category)
+	if !ok {
+		response.BadRequest(c, "unknown category")
+		return
+	}
+
 	channelName := generateRelayChannelName()
 	streamKey := generateRelayStreamKey()
 
@@ -99,7 +106,7 @@ func (h *RelayHandler) CreateRelay(c *gin.Context) {
 		StreamKey:     streamKey,
 		RelayProtocol: "rtmp",
 		Status:        "stopped",
-		Category:      req.Category,
+		Category:      category,
 		CoverURL:      req.CoverURL,
 		AutoStart:     req.AutoStart,
 	}
@@ -108,6 +115,7 @@ func (h *RelayHandler) CreateRelay(c *gin.Context) {
 		response.Fail(c, "failed to create relay stream")
 		return
 	}
+	invalidateRoomCaches()
 
 	if req.AutoStart {
 		go h.startRelay(relay.ID.String())
@@ -129,12 +137,56 @@ func (h *RelayHandler) CreateRelay(c *gin.Context) {
 	})
 }
 
+var relayPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"view_count": "view_count",
+		"name":       "name",
+	},
+	DefaultSort: "-created_at",
+	Key:         "id",
+	Filters: map[string][]string{
+		"status":   {"starting", "running", "stopped", "error"},
+		"category": nil,
+	},
+}
+
 func (h *RelayHandler) GetRelays(c *gin.Context) {
+	page, err := pagination.Parse(c.Request.URL.Query(), relayPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.RelayStream{})
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if category := page.Filter("category"); category != "" {
+		query = query.Where("category IN ?", categoryFilter(category))
+	}
+
 	var relays []models.RelayStream
-	repository.DB.Order("created_at DESC").Find(&relays)
+	total, err := page.Find(query, &relays)
+	if err != nil {
+		response.Fail(c, "failed to load relay streams")
+		return
+	}
+	n, next := page.Next(len(relays), func(i int) (interface{}, interface{}) {
+		switch page.Sort {
+		case "view_count":
+			return relays[i].ViewCount, relays[i].ID
+		case "name":
+			return relays[i].Name, relays[i].ID
+		}
+		return relays[i].CreatedAt, relays[i].ID
+	})
 
-	result := make([]RelayResponse, 0, len(relays))
-	for _, r := range relays {
+	result := make([]RelayResponse, 0, n)
+	for _, r := range relays[:n] {
 		result = append(result, RelayResponse{
 			ID:          r.ID.String(),
 			Name:        r.Name,
@@ -151,7 +203,7 @@ func (h *RelayHandler) GetRelays(c *gin.Context) {
 		})
 	}
 
-	response.Success(c, result)
+	response.Paged(c, result, next, total)
 }
 
 func (h *RelayHandler) GetRelay(c *gin.Context) {
@@ -202,7 +254,12 @@ func (h *RelayHandler) UpdateRelay(c *gin.Context) {
 		updates["description"] = req.Description
 	}
 	if req.Category != "" {
-		updates["category"] = req.Category
+		category, ok := resolveCategory(req.Category)
+		if !ok {
+			response.BadRequest(c, "unknown category")
+			return
+		}
+		updates["category"] = category
 	}
 	if req.CoverURL != "" {
 		updates["cover_url"] = req.CoverURL
@@ -213,6 +270,7 @@ func (h *RelayHandler) UpdateRelay(c *gin.Context) {
 
 	if len(updates) > 0 {
 		repository.DB.Model(&relay).Updates(updates)
+		invalidateRoomCaches()
 	}
 
 	response.Success(c, gin.H{
@@ -235,6 +293,7 @@ func (h *RelayHandler) DeleteRelay(c *gin.Context) {
 	}
 
 	repository.DB.Delete(&relay)
+	invalidateRoomCaches()
 
 	response.Success(c, gin.H{
 		"message": "relay stream deleted",
@@ -294,15 +353,18 @@ func (h *RelayHandler) startRelay(id string) {
 	}
 
 	repository.DB.Model(&relay).Update("status", "starting")
+	invalidateRoomCaches()
 
 	err := startRelayProcess(relay.SourceURL, relay.ChannelName)
 	if err != nil {
 		repository.DB.Model(&relay).Update("status", "error")
+		invalidateRoomCaches()
 		h.logRelayEvent(relay.ID.String(), "start_error", "", err.Error())
 		return
 	}
 
 	repository.DB.Model(&relay).Update("status", "running")
+	invalidateRoomCaches()
 	h.logRelayEvent(relay.ID.String(), "started", "", "")
 }
 
@@ -315,6 +377,7 @@ func (h *RelayHandler) stopRelay(id string) {
 	stopRelayProcess(relay.ChannelName)
 
 	repository.DB.Model(&relay).Update("status", "stopped")
+	invalidateRoomCaches()
 	h.logRelayEvent(relay.ID.String(), "stopped", "", "")
 }
 
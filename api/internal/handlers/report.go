@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -77,6 +78,26 @@ func (h *ReportHandler) CreateReport(c *gin.Context) {
 	response.Success(c, gin.H{"message": "举报已提交，感谢您的反馈"})
 }
 
+var myReportPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
+	Filters:      map[string][]string{"status": nil},
+}
+
+var pendingReportPage = pagination.Spec{
+	Mode:         pagination.Offset,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"created_at": "r.created_at"},
+	DefaultSort:  "-created_at",
+	Key:          "r.id",
+	Filters:      map[string][]string{"type": nil},
+}
+
 func (h *ReportHandler) GetMyReports(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -84,11 +105,29 @@ func (h *ReportHandler) GetMyReports(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), myReportPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	userUUID := uuid.MustParse(userID)
+	query := repository.DB.Model(&models.UserReport{}).Where("reporter_id = ?", userUUID)
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
 	var reports []models.UserReport
-	repository.DB.Where("reporter_id = ?", userUUID).Order("created_at DESC").Find(&reports)
+	total, err := page.Find(query, &reports)
+	if err != nil {
+		response.Fail(c, "获取举报记录失败")
+		return
+	}
+	n, next := page.Next(len(reports), func(i int) (interface{}, interface{}) {
+		return reports[i].CreatedAt, reports[i].ID
+	})
 
-	response.Success(c, reports)
+	response.Paged(c, reports[:n], next, total)
 }
 
 func (h *ReportHandler) GetPendingReports(c *gin.Context) {
@@ -99,6 +138,12 @@ func (h *ReportHandler) GetPendingReports(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), pendingReportPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	var reports []struct {
 		ID         string `json:"id"`
 		ReporterID string `json:"reporter_id"`
@@ -112,19 +157,24 @@ func (h *ReportHandler) GetPendingReports(c *gin.Context) {
 		CreatedAt  string `json:"created_at"`
 	}
 
-	repository.DB.Raw(`
-		SELECT 
-			r.id, r.reporter_id, u1.nickname as reporter,
-			r.reported_id, u2.nickname as reported,
-			r.room_id, r.type, r.reason, r.status, r.created_at
-		FROM user_reports r
-		JOIN users u1 ON u1.id = r.reporter_id
-		JOIN users u2 ON u2.id = r.reported_id
-		WHERE r.status = 'pending'
-		ORDER BY r.created_at DESC
-	`).Scan(&reports)
+	query := repository.DB.Table("user_reports r").
+		Joins("JOIN users u1 ON u1.id = r.reporter_id").
+		Joins("JOIN users u2 ON u2.id = r.reported_id").
+		Where("r.status = ?", "pending")
+	if t := page.Filter("type"); t != "" {
+		query = query.Where("r.type = ?", t)
+	}
+
+	total, err := page.Find(query.Select(`r.id, r.reporter_id, u1.nickname AS reporter,
+		r.reported_id, u2.nickname AS reported,
+		r.room_id, r.type, r.reason, r.status, r.created_at`), &reports)
+	if err != nil {
+		response.Fail(c, "获取举报列表失败")
+		return
+	}
+	n, next := page.Next(len(reports), nil)
 
-	response.Success(c, reports)
+	response.Paged(c, reports[:n], next, total)
 }
 
 type HandleReportRequest struct {
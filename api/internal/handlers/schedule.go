@@ -1,19 +1,33 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
+	"github.com/huya_live/api/pkg/rrule"
+	"gorm.io/gorm"
 )
 
-type ScheduleHandler struct{}
+const (
+	// A room opened this long before a schedule's start time still fulfils it.
+	scheduleEarlyWindow = 30 * time.Minute
+	// A schedule that has not gone live this long after its start time is missed.
+	scheduleLateWindow = 60 * time.Minute
+)
+
+type ScheduleHandler struct {
+	webURL string
+}
 
-func NewScheduleHandler() *ScheduleHandler {
-	return &ScheduleHandler{}
+func NewScheduleHandler(webURL string) *ScheduleHandler {
+	return &ScheduleHandler{webURL: webURL}
 }
 
 type CreateScheduleRequest struct {
@@ -22,6 +36,7 @@ type CreateScheduleRequest struct {
 	Category    string `json:"category"`
 	CoverURL    string `json:"cover_url"`
 	StartTime   string `json:"start_time" binding:"required"`
+	Recurrence  string `json:"recurrence"`
 }
 
 func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
@@ -48,15 +63,32 @@ func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
 		return
 	}
 
+	if req.Recurrence != "" {
+		if _, err := rrule.Parse(req.Recurrence); err != nil {
+			response.BadRequest(c, "重复规则无效，示例：FREQ=WEEKLY;BYDAY=MO,WE")
+			return
+		}
+	}
+
+	category, ok := resolveCategory(req.Category)
+	if !ok {
+		response.BadRequest(c, "分类不存在")
+		return
+	}
+
 	schedule := models.LiveSchedule{
 		ID:          uuid.New(),
 		StreamerID:  uuid.MustParse(userID),
 		Title:       req.Title,
 		Description: req.Description,
-		Category:    req.Category,
+		Category:    category,
 		CoverURL:    req.CoverURL,
 		StartTime:   startTime,
 		Status:      "scheduled",
+		Recurrence:  req.Recurrence,
+	}
+	if schedule.Recurrence != "" {
+		schedule.SeriesID = &schedule.ID
 	}
 
 	if err := repository.DB.Create(&schedule).Error; err != nil {
@@ -67,6 +99,18 @@ func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
 	response.Success(c, gin.H{"message": "创建成功", "data": schedule})
 }
 
+var mySchedulePage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"start_time": "start_time"},
+	DefaultSort:  "start_time",
+	Key:          "id",
+	Filters: map[string][]string{
+		"status": {"scheduled", "live", "completed", "cancelled", "missed"},
+	},
+}
+
 func (h *ScheduleHandler) GetMySchedules(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -74,35 +118,90 @@ func (h *ScheduleHandler) GetMySchedules(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.Parse(c.Request.URL.Query(), mySchedulePage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	userUUID := uuid.MustParse(userID)
+	query := repository.DB.Model(&models.LiveSchedule{}).Where("streamer_id = ?", userUUID)
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
 	var schedules []models.LiveSchedule
-	repository.DB.Where("streamer_id = ?", userUUID).Order("start_time ASC").Find(&schedules)
+	total, err := page.Find(query, &schedules)
+	if err != nil {
+		response.Fail(c, "获取直播预告失败")
+		return
+	}
+	n, next := page.Next(len(schedules), func(i int) (interface{}, interface{}) {
+		return schedules[i].StartTime, schedules[i].ID
+	})
+
+	response.Paged(c, schedules[:n], next, total)
+}
 
-	response.Success(c, schedules)
+var upcomingSchedulePage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"start_time": "s.start_time"},
+	DefaultSort:  "start_time",
+	Key:          "s.id",
+	Filters:      map[string][]string{"status": {"live", "scheduled"}},
 }
 
 func (h *ScheduleHandler) GetUpcomingSchedules(c *gin.Context) {
+	page, err := pagination.Parse(c.Request.URL.Query(), upcomingSchedulePage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Table("live_schedules s").
+		Joins("JOIN users u ON u.id = s.streamer_id").
+		Where("(s.status = 'live' OR (s.status = 'scheduled' AND s.start_time > ?))", time.Now().Add(-scheduleLateWindow))
+	if status := page.Filter("status"); status != "" {
+		query = query.Where("s.status = ?", status)
+	}
+
 	var schedules []struct {
-		ID           string `json:"id"`
-		StreamerID   string `json:"streamer_id"`
-		StreamerName string `json:"streamer_name"`
-		Title        string `json:"title"`
-		Category     string `json:"category"`
-		CoverURL     string `json:"cover_url"`
-		StartTime    string `json:"start_time"`
+		ID           string    `json:"id"`
+		StreamerID   string    `json:"streamer_id"`
+		StreamerName string    `json:"streamer_name"`
+		Title        string    `json:"title"`
+		Category     string    `json:"category"`
+		CoverURL     string    `json:"cover_url"`
+		StartTime    time.Time `json:"start_time"`
+		Status       string    `json:"status"`
+		RoomID       string    `json:"room_id"`
 	}
+	total, err := page.Find(query.Select(`s.id, s.streamer_id, u.nickname AS streamer_name,
+		s.title, s.category, s.cover_url, s.start_time,
+		s.status, COALESCE(s.room_id::text, '') AS room_id`), &schedules)
+	if err != nil {
+		response.Fail(c, "failed to load schedules")
+		return
+	}
+	n, next := page.Next(len(schedules), func(i int) (interface{}, interface{}) {
+		return schedules[i].StartTime, schedules[i].ID
+	})
 
-	repository.DB.Raw(`
-		SELECT s.id, s.streamer_id, u.nickname as streamer_name, 
-		       s.title, s.category, s.cover_url, s.start_time
-		FROM live_schedules s
-		JOIN users u ON u.id = s.streamer_id
-		WHERE s.start_time > NOW() AND s.status = 'scheduled'
-		ORDER BY s.start_time ASC
-		LIMIT 50
-	`).Scan(&schedules)
+	response.Paged(c, schedules[:n], next, total)
+}
 
-	response.Success(c, schedules)
+// UpdateScheduleRequest mirrors CreateScheduleRequest, except that the
+// recurrence rule is only changed when it is sent; an empty rule ends the
+// series.
+type UpdateScheduleRequest struct {
+	Title       string  `json:"title" binding:"required,max=200"`
+	Description string  `json:"description,max=1000"`
+	Category    string  `json:"category"`
+	CoverURL    string  `json:"cover_url"`
+	StartTime   string  `json:"start_time" binding:"required"`
+	Recurrence  *string `json:"recurrence"`
 }
 
 func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
@@ -115,7 +214,7 @@ func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
 	scheduleID := c.Param("id")
 	userUUID := uuid.MustParse(userID)
 
-	var req CreateScheduleRequest
+	var req UpdateScheduleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, "参数错误")
 		return
@@ -123,15 +222,47 @@ func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
 
 	startTime, _ := time.Parse(time.RFC3339, req.StartTime)
 
+	if req.Recurrence != nil && *req.Recurrence != "" {
+		if _, err := rrule.Parse(*req.Recurrence); err != nil {
+			response.BadRequest(c, "重复规则无效，示例：FREQ=WEEKLY;BYDAY=MO,WE")
+			return
+		}
+	}
+
+	var schedule models.LiveSchedule
+	if err := repository.DB.Where("id = ? AND streamer_id = ?", scheduleID, userUUID).First(&schedule).Error; err != nil {
+		response.Fail(c, "预告不存在")
+		return
+	}
+
+	// A schedule may keep a category that has since been retired; only a
+	// changed category has to be an active one.
+	category := schedule.Category
+	if req.Category != schedule.Category {
+		var ok bool
+		category, ok = resolveCategory(req.Category)
+		if !ok {
+			response.BadRequest(c, "分类不存在")
+			return
+		}
+	}
+
 	updates := map[string]interface{}{
 		"title":       req.Title,
 		"description": req.Description,
-		"category":    req.Category,
+		"category":    category,
 		"cover_url":   req.CoverURL,
 		"start_time":  startTime,
+		"sequence":    gorm.Expr("sequence + 1"),
+	}
+	if req.Recurrence != nil {
+		updates["recurrence"] = *req.Recurrence
+		if *req.Recurrence != "" && schedule.SeriesID == nil {
+			updates["series_id"] = schedule.ID
+		}
 	}
 
-	if err := repository.DB.Model(&models.LiveSchedule{}).Where("id = ? AND streamer_id = ?", scheduleID, userUUID).Updates(updates).Error; err != nil {
+	if err := repository.DB.Model(&schedule).Updates(updates).Error; err != nil {
 		response.Fail(c, "更新失败")
 		return
 	}
@@ -149,7 +280,35 @@ func (h *ScheduleHandler) CancelSchedule(c *gin.Context) {
 	scheduleID := c.Param("id")
 	userUUID := uuid.MustParse(userID)
 
-	if err := repository.DB.Model(&models.LiveSchedule{}).Where("id = ? AND streamer_id = ?", scheduleID, userUUID).Update("status", "cancelled").Error; err != nil {
+	if c.Query("series") == "true" {
+		var schedule models.LiveSchedule
+		if err := repository.DB.Where("id = ? AND streamer_id = ?", scheduleID, userUUID).First(&schedule).Error; err != nil {
+			response.Fail(c, "预告不存在")
+			return
+		}
+		if schedule.SeriesID != nil {
+			err := repository.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&models.LiveSchedule{}).Where("series_id = ?", *schedule.SeriesID).Update("recurrence", "").Error; err != nil {
+					return err
+				}
+				return tx.Model(&models.LiveSchedule{}).Where("series_id = ? AND status = ?", *schedule.SeriesID, "scheduled").Updates(map[string]interface{}{
+					"status":   "cancelled",
+					"sequence": gorm.Expr("sequence + 1"),
+				}).Error
+			})
+			if err != nil {
+				response.Fail(c, "取消失败")
+				return
+			}
+			response.Success(c, gin.H{"message": "已取消全部重复预告"})
+			return
+		}
+	}
+
+	if err := repository.DB.Model(&models.LiveSchedule{}).Where("id = ? AND streamer_id = ?", scheduleID, userUUID).Updates(map[string]interface{}{
+		"status":   "cancelled",
+		"sequence": gorm.Expr("sequence + 1"),
+	}).Error; err != nil {
 		response.Fail(c, "取消失败")
 		return
 	}
@@ -174,3 +333,155 @@ func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
 
 	response.Success(c, gin.H{"message": "删除成功"})
 }
+
+// linkScheduleToRoom marks the streamer's schedule closest to now as live when
+// they open a room inside the schedule window, and records it on the room.
+func linkScheduleToRoom(room *models.LiveRoom) *models.LiveSchedule {
+	now := time.Now()
+
+	var schedule models.LiveSchedule
+	if err := repository.DB.
+		Where("streamer_id = ? AND status = ? AND start_time BETWEEN ? AND ?",
+			room.StreamerID, "scheduled", now.Add(-scheduleLateWindow), now.Add(scheduleEarlyWindow)).
+		Order(fmt.Sprintf("ABS(EXTRACT(EPOCH FROM start_time) - %d) ASC", now.Unix())).
+		First(&schedule).Error; err != nil {
+		return nil
+	}
+
+	if err := repository.DB.Model(&schedule).Updates(map[string]interface{}{
+		"status":  "live",
+		"room_id": room.ID,
+	}).Error; err != nil {
+		return nil
+	}
+	repository.DB.Model(room).Update("schedule_id", schedule.ID)
+	room.ScheduleID = &schedule.ID
+
+	return &schedule
+}
+
+// completeScheduleForRoom marks the schedule a room fulfilled as completed.
+func completeScheduleForRoom(room *models.LiveRoom) {
+	if room.ScheduleID == nil {
+		return
+	}
+
+	repository.DB.Model(&models.LiveSchedule{}).
+		Where("id = ? AND status = ?", *room.ScheduleID, "live").
+		Updates(map[string]interface{}{
+			"status":   "completed",
+			"end_time": time.Now(),
+		})
+	extendScheduleSeries(*room.ScheduleID)
+}
+
+// AdvanceSchedules is the periodic schedule job: it marks schedules that
+// never went live as missed, completes schedules whose room has ended, and
+// creates the next occurrence of recurring series.
+func AdvanceSchedules() (string, error) {
+	now := time.Now()
+
+	missed := repository.DB.Model(&models.LiveSchedule{}).
+		Where("status = ? AND start_time < ?", "scheduled", now.Add(-scheduleLateWindow)).
+		Updates(map[string]interface{}{
+			"status":   "missed",
+			"sequence": gorm.Expr("sequence + 1"),
+		})
+	if missed.Error != nil {
+		return "", missed.Error
+	}
+
+	completed := repository.DB.Exec(`
+		UPDATE live_schedules s
+		SET status = 'completed', end_time = COALESCE(r.end_at, ?), updated_at = ?
+		FROM live_rooms r
+		WHERE s.status = 'live' AND r.id = s.room_id AND r.status <> 'live'
+	`, now, now)
+	if completed.Error != nil {
+		return "", completed.Error
+	}
+
+	var seriesIDs []uuid.UUID
+	if err := repository.DB.Raw(`
+		SELECT DISTINCT series_id FROM live_schedules
+		WHERE series_id IS NOT NULL AND recurrence <> ''
+		  AND series_id NOT IN (
+			SELECT series_id FROM live_schedules
+			WHERE series_id IS NOT NULL AND status IN ('scheduled', 'live')
+		  )
+	`).Scan(&seriesIDs).Error; err != nil {
+		return "", err
+	}
+
+	created := 0
+	for _, seriesID := range seriesIDs {
+		if extendScheduleSeries(seriesID) {
+			created++
+		}
+	}
+
+	return fmt.Sprintf("missed=%d completed=%d occurrences_created=%d",
+		missed.RowsAffected, completed.RowsAffected, created), nil
+}
+
+// extendScheduleSeries creates the next upcoming occurrence of the series the
+// given schedule belongs to, unless one is already scheduled or live.
+func extendScheduleSeries(scheduleID uuid.UUID) bool {
+	var current models.LiveSchedule
+	if err := repository.DB.First(&current, "id = ? OR series_id = ?", scheduleID, scheduleID).Error; err != nil || current.SeriesID == nil {
+		return false
+	}
+	seriesID := *current.SeriesID
+
+	var pending int64
+	repository.DB.Model(&models.LiveSchedule{}).
+		Where("series_id = ? AND status IN ?", seriesID, []string{"scheduled", "live"}).
+		Count(&pending)
+	if pending > 0 {
+		return false
+	}
+
+	var first, latest models.LiveSchedule
+	if err := repository.DB.Where("series_id = ?", seriesID).Order("start_time ASC").First(&first).Error; err != nil {
+		return false
+	}
+	if err := repository.DB.Where("series_id = ?", seriesID).Order("start_time DESC").First(&latest).Error; err != nil {
+		return false
+	}
+	if latest.Recurrence == "" {
+		return false
+	}
+
+	rule, err := rrule.Parse(latest.Recurrence)
+	if err != nil {
+		log.Printf("schedule series %s has invalid recurrence %q: %v", seriesID, latest.Recurrence, err)
+		return false
+	}
+
+	next, ok := rule.Next(first.StartTime, latest.StartTime)
+	for ok && next.Before(time.Now()) {
+		next, ok = rule.Next(first.StartTime, next)
+	}
+	if !ok {
+		return false
+	}
+
+	occurrence := models.LiveSchedule{
+		ID:          uuid.New(),
+		StreamerID:  latest.StreamerID,
+		Title:       latest.Title,
+		Description: latest.Description,
+		Category:    latest.Category,
+		CoverURL:    latest.CoverURL,
+		StartTime:   next,
+		Status:      "scheduled",
+		Recurrence:  latest.Recurrence,
+		SeriesID:    &seriesID,
+	}
+	if latest.EndTime != nil {
+		endTime := next.Add(latest.EndTime.Sub(latest.StartTime))
+		occurrence.EndTime = &endTime
+	}
+
+	return repository.DB.Create(&occurrence).Error == nil
+}
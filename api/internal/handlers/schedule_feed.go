@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/ical"
+	"github.com/huya_live/api/pkg/response"
+)
+
+const (
+	calendarFeedHistory     = 30 * 24 * time.Hour
+	calendarFeedLimit       = 500
+	calendarDefaultDuration = 2 * time.Hour
+)
+
+func (h *ScheduleHandler) GetStreamerCalendar(c *gin.Context) {
+	streamerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "主播ID无效")
+		return
+	}
+
+	var streamer models.User
+	if err := repository.DB.Select("id", "username", "nickname").First(&streamer, "id = ?", streamerID).Error; err != nil {
+		response.BadRequest(c, "主播不存在")
+		return
+	}
+
+	var schedules []models.LiveSchedule
+	repository.DB.Where("streamer_id = ? AND start_time > ?", streamerID, time.Now().Add(-calendarFeedHistory)).
+		Order("start_time ASC").
+		Limit(calendarFeedLimit).
+		Find(&schedules)
+
+	name := displayName(streamer)
+	h.writeCalendar(c, name+" 的直播预告", map[uuid.UUID]string{streamerID: name}, schedules)
+}
+
+func (h *ScheduleHandler) GetPersonalCalendar(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var feedToken models.CalendarFeedToken
+	if token == "" || repository.DB.Where("token = ?", token).First(&feedToken).Error != nil {
+		response.BadRequest(c, "订阅链接无效")
+		return
+	}
+
+	var followings []struct {
+		ID       uuid.UUID
+		Username string
+		Nickname string
+	}
+	repository.DB.Raw(`
+		SELECT u.id, u.username, u.nickname
+		FROM fan_relations f
+		JOIN users u ON u.id = f.streamer_id
+		WHERE f.user_id = ?
+	`, feedToken.UserID).Scan(&followings)
+
+	names := make(map[uuid.UUID]string, len(followings))
+	streamerIDs := make([]uuid.UUID, 0, len(followings))
+	for _, f := range followings {
+		names[f.ID] = displayName(models.User{Username: f.Username, Nickname: f.Nickname})
+		streamerIDs = append(streamerIDs, f.ID)
+	}
+
+	var schedules []models.LiveSchedule
+	if len(streamerIDs) > 0 {
+		repository.DB.Where("streamer_id IN ? AND start_time > ?", streamerIDs, time.Now().Add(-calendarFeedHistory)).
+			Order("start_time ASC").
+			Limit(calendarFeedLimit).
+			Find(&schedules)
+	}
+
+	h.writeCalendar(c, "我关注的直播预告", names, schedules)
+}
+
+func (h *ScheduleHandler) GetCalendarFeedToken(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Unauthorized(c, "未登录")
+		return
+	}
+
+	var feedToken models.CalendarFeedToken
+	if err := repository.DB.Where("user_id = ?", userID).First(&feedToken).Error; err != nil {
+		feedToken = models.CalendarFeedToken{
+			UserID: uuid.MustParse(userID),
+			Token:  generateCalendarToken(),
+		}
+		if err := repository.DB.Create(&feedToken).Error; err != nil {
+			response.Fail(c, "生成订阅链接失败")
+			return
+		}
+	}
+
+	response.Success(c, gin.H{
+		"token": feedToken.Token,
+		"url":   "/api/v1/calendar/" + feedToken.Token + ".ics",
+	})
+}
+
+func (h *ScheduleHandler) RotateCalendarFeedToken(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Unauthorized(c, "未登录")
+		return
+	}
+
+	var feedToken models.CalendarFeedToken
+	if err := repository.DB.Where("user_id = ?", userID).First(&feedToken).Error; err != nil {
+		feedToken = models.CalendarFeedToken{
+			UserID: uuid.MustParse(userID),
+			Token:  generateCalendarToken(),
+		}
+		if err := repository.DB.Create(&feedToken).Error; err != nil {
+			response.Fail(c, "重置订阅链接失败")
+			return
+		}
+	} else {
+		feedToken.Token = generateCalendarToken()
+		if err := repository.DB.Model(&feedToken).Update("token", feedToken.Token).Error; err != nil {
+			response.Fail(c, "重置订阅链接失败")
+			return
+		}
+	}
+
+	response.Success(c, gin.H{
+		"token": feedToken.Token,
+		"url":   "/api/v1/calendar/" + feedToken.Token + ".ics",
+	})
+}
+
+func (h *ScheduleHandler) writeCalendar(c *gin.Context, name string, streamerNames map[uuid.UUID]string, schedules []models.LiveSchedule) {
+	cal := ical.Calendar{
+		ProdID:          "-//huya_live//schedule//CN",
+		Name:            name,
+		RefreshInterval: time.Hour,
+		Events:          make([]ical.Event, 0, len(schedules)),
+	}
+
+	streamerIDs := make([]uuid.UUID, 0, len(streamerNames))
+	for id := range streamerNames {
+		streamerIDs = append(streamerIDs, id)
+	}
+	var liveRooms []models.LiveRoom
+	if len(streamerIDs) > 0 {
+		repository.DB.Select("id", "streamer_id").Where("streamer_id IN ? AND status = ?", streamerIDs, "live").Find(&liveRooms)
+	}
+	liveRoomByStreamer := make(map[uuid.UUID]uuid.UUID, len(liveRooms))
+	for _, room := range liveRooms {
+		liveRoomByStreamer[room.StreamerID] = room.ID
+	}
+
+	for _, s := range schedules {
+		end := s.StartTime.Add(calendarDefaultDuration)
+		if s.EndTime != nil && s.EndTime.After(s.StartTime) {
+			end = *s.EndTime
+		}
+
+		// A missed schedule did not happen, so clients should drop it too.
+		status := ical.StatusConfirmed
+		if s.Status == "cancelled" || s.Status == "missed" {
+			status = ical.StatusCancelled
+		}
+		modified := s.UpdatedAt
+		if modified.IsZero() {
+			modified = s.CreatedAt
+		}
+
+		summary := s.Title
+		if streamerName := streamerNames[s.StreamerID]; streamerName != "" {
+			summary = streamerName + "：" + s.Title
+		}
+
+		cal.Events = append(cal.Events, ical.Event{
+			UID:          s.ID.String() + "@huya_live",
+			Summary:      summary,
+			Description:  s.Description,
+			Categories:   s.Category,
+			URL:          h.scheduleURL(s, liveRoomByStreamer),
+			Status:       status,
+			Start:        s.StartTime,
+			End:          end,
+			Created:      s.CreatedAt,
+			LastModified: modified,
+			Sequence:     s.Sequence,
+		})
+	}
+
+	c.Header("Content-Disposition", "inline; filename=schedule.ics")
+	c.Data(200, "text/calendar; charset=utf-8", []byte(cal.String()))
+}
+
+// scheduleURL links an event to the room that fulfilled it, or to the
+// streamer's current room while they are live.
+func (h *ScheduleHandler) scheduleURL(s models.LiveSchedule, liveRoomByStreamer map[uuid.UUID]uuid.UUID) string {
+	if s.RoomID != nil {
+		return h.webURL + "/live/" + s.RoomID.String()
+	}
+	if roomID, ok := liveRoomByStreamer[s.StreamerID]; ok {
+		return h.webURL + "/live/" + roomID.String()
+	}
+	return h.webURL + "/schedules"
+}
+
+func generateCalendarToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func displayName(user models.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pinyin"
+	"github.com/huya_live/api/pkg/response"
+)
+
+const (
+	searchTypeRooms      = "rooms"
+	searchTypeStreamers  = "streamers"
+	searchTypeRelays     = "relays"
+	searchTypeSchedules  = "schedules"
+	searchTypeCategories = "categories"
+)
+
+var searchTypes = []string{searchTypeRooms, searchTypeStreamers, searchTypeRelays, searchTypeSchedules, searchTypeCategories}
+
+const searchMaxQueryLen = 50
+
+// likePattern turns q into an ILIKE substring pattern, escaping wildcards.
+func likePattern(q string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(q) + "%"
+}
+
+type searchQuery struct {
+	Q          string
+	Like       string
+	Pinyin     string // pinyin-initials prefix pattern, empty unless q is all letters
+	Categories []string
+	Limit      int
+}
+
+// args are the named parameters shared by every search statement.
+func (q searchQuery) args() map[string]interface{} {
+	return map[string]interface{}{
+		"q":          q.Q,
+		"like":       q.Like,
+		"prefix":     strings.TrimPrefix(q.Like, "%"),
+		"py":         q.Pinyin,
+		"categories": q.Categories,
+		"limit":      q.Limit,
+		"late":       time.Now().Add(-scheduleLateWindow),
+	}
+}
+
+type SearchRoom struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Category     string `json:"category"`
+	CoverURL     string `json:"cover_url"`
+	ChannelName  string `json:"channel_name"`
+	StreamerID   string `json:"streamer_id"`
+	StreamerName string `json:"streamer_name"`
+	Total        int64  `json:"-"`
+}
+
+type SearchStreamer struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Nickname      string `json:"nickname"`
+	AvatarURL     string `json:"avatar_url"`
+	Level         int    `json:"level"`
+	FollowerCount int    `json:"follower_count"`
+	IsLive        bool   `json:"is_live"`
+	Total         int64  `json:"-"`
+}
+
+type SearchRelay struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	CoverURL    string `json:"cover_url"`
+	ChannelName string `json:"channel_name"`
+	Total       int64  `json:"-"`
+}
+
+type SearchSchedule struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Category     string    `json:"category"`
+	CoverURL     string    `json:"cover_url"`
+	StartTime    time.Time `json:"start_time"`
+	Status       string    `json:"status"`
+	StreamerID   string    `json:"streamer_id"`
+	StreamerName string    `json:"streamer_name"`
+	Total        int64     `json:"-"`
+}
+
+type SearchHandler struct{}
+
+func NewSearchHandler() *SearchHandler {
+	return &SearchHandler{}
+}
+
+// Search matches q against live room titles, streamer nicknames, relay
+// names, upcoming schedules and categories. Results are ranked by prefix
+// match, then trigram word similarity; an all-letter query also matches the
+// pinyin initials of Chinese nicknames and category names. ?type= limits the
+// search to a comma-separated list of types, and facets hold the total
+// number of matches per type.
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		response.BadRequest(c, "请输入搜索内容")
+		return
+	}
+	if utf8.RuneCountInString(q) > searchMaxQueryLen {
+		response.BadRequest(c, "搜索内容过长")
+		return
+	}
+
+	types := searchTypes
+	if t := c.Query("type"); t != "" && t != "all" {
+		types = nil
+		for _, name := range strings.Split(t, ",") {
+			name = strings.TrimSpace(name)
+			if !containsString(searchTypes, name) {
+				response.BadRequest(c, "不支持的搜索类型: "+name)
+				return
+			}
+			types = append(types, name)
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	sq := searchQuery{Q: q, Like: likePattern(q), Limit: limit}
+	if pinyin.IsInitials(q) {
+		sq.Pinyin = strings.ToLower(q) + "%"
+	}
+
+	matchedCategories := searchCategories(q)
+	for _, cat := range matchedCategories {
+		sq.Categories = append(sq.Categories, cat.Name)
+	}
+
+	facets := make(map[string]int64, len(types))
+	results := make(map[string]interface{}, len(types))
+	// Each row carries COUNT(*) OVER(), the number of matches before LIMIT.
+	for _, t := range types {
+		facets[t] = 0
+		switch t {
+		case searchTypeRooms:
+			rooms, err := searchRooms(sq)
+			if err != nil {
+				response.Fail(c, "搜索失败")
+				return
+			}
+			if len(rooms) > 0 {
+				facets[t] = rooms[0].Total
+			}
+			results[t] = rooms
+		case searchTypeStreamers:
+			streamers, err := searchStreamers(sq)
+			if err != nil {
+				response.Fail(c, "搜索失败")
+				return
+			}
+			if len(streamers) > 0 {
+				facets[t] = streamers[0].Total
+			}
+			results[t] = streamers
+		case searchTypeRelays:
+			relays, err := searchRelays(sq)
+			if err != nil {
+				response.Fail(c, "搜索失败")
+				return
+			}
+			if len(relays) > 0 {
+				facets[t] = relays[0].Total
+			}
+			results[t] = relays
+		case searchTypeSchedules:
+			schedules, err := searchSchedules(sq)
+			if err != nil {
+				response.Fail(c, "搜索失败")
+				return
+			}
+			if len(schedules) > 0 {
+				facets[t] = schedules[0].Total
+			}
+			results[t] = schedules
+		case searchTypeCategories:
+			facets[t] = int64(len(matchedCategories))
+			if len(matchedCategories) > limit {
+				matchedCategories = matchedCategories[:limit]
+			}
+			results[t] = matchedCategories
+		}
+	}
+
+	response.Success(c, gin.H{
+		"query":   q,
+		"facets":  facets,
+		"results": results,
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// searchCategories matches active categories by name, slug or pinyin
+// initials from the cached category list, exact matches first.
+func searchCategories(q string) []CategoryResponse {
+	lower := strings.ToLower(q)
+	initials := pinyin.IsInitials(q)
+	snapshot := loadCategories()
+
+	var exact, partial []CategoryResponse
+	for _, cat := range snapshot.categories {
+		if !cat.IsActive {
+			continue
+		}
+		matched := strings.Contains(cat.Name, q) || strings.Contains(cat.Slug, lower) ||
+			(initials && strings.HasPrefix(pinyin.Initials(cat.Name), lower))
+		if !matched {
+			continue
+		}
+		item := CategoryResponse{
+			ID:        cat.ID,
+			ParentID:  cat.ParentID,
+			Name:      cat.Name,
+			Slug:      cat.Slug,
+			Icon:      cat.IconURL,
+			SortOrder: cat.SortOrder,
+			Rooms:     snapshot.roomCounts[cat.Name],
+			Relays:    snapshot.relayCount[cat.Name],
+		}
+		item.Count = item.Rooms + item.Relays
+		if cat.Name == q || cat.Slug == lower {
+			exact = append(exact, item)
+		} else {
+			partial = append(partial, item)
+		}
+	}
+	return append(append(make([]CategoryResponse, 0, len(exact)+len(partial)), exact...), partial...)
+}
+
+func searchRooms(q searchQuery) ([]SearchRoom, error) {
+	rooms := make([]SearchRoom, 0)
+	err := repository.DB.Raw(`
+		SELECT r.id, r.title, r.category, r.cover_url, r.channel_name, r.streamer_id,
+		       COALESCE(NULLIF(u.nickname, ''), u.username) AS streamer_name,
+		       COUNT(*) OVER() AS total
+		FROM live_rooms r
+		JOIN users u ON u.id = r.streamer_id
+		WHERE r.status = 'live'
+		  AND (r.title ILIKE @like OR @q <% r.title
+		       OR u.nickname ILIKE @like
+		       OR (@py <> '' AND u.nickname_pinyin LIKE @py)
+		       OR r.category IN @categories)
+		ORDER BY (CASE WHEN r.title ILIKE @prefix THEN 1 ELSE 0 END)
+		         + word_similarity(@q, r.title)
+		         + 0.5 * word_similarity(@q, COALESCE(u.nickname, ''))
+		         + (CASE WHEN @py <> '' AND u.nickname_pinyin LIKE @py THEN 0.5 ELSE 0 END) DESC,
+		         r.peak_online DESC
+		LIMIT @limit
+	`, q.args()).Scan(&rooms).Error
+	return rooms, err
+}
+
+func searchStreamers(q searchQuery) ([]SearchStreamer, error) {
+	streamers := make([]SearchStreamer, 0)
+	err := repository.DB.Raw(`
+		SELECT u.id, u.username, u.nickname, u.avatar_url, u.level, s.follower_count,
+		       EXISTS (SELECT 1 FROM live_rooms r WHERE r.streamer_id = u.id AND r.status = 'live') AS is_live,
+		       COUNT(*) OVER() AS total
+		FROM streamers s
+		JOIN users u ON u.id = s.user_id
+		WHERE u.status = 'active'
+		  AND (u.nickname ILIKE @like OR u.username ILIKE @like OR @q <% u.nickname
+		       OR (@py <> '' AND u.nickname_pinyin LIKE @py))
+		ORDER BY (CASE WHEN u.nickname ILIKE @prefix OR u.username ILIKE @prefix THEN 1 ELSE 0 END)
+		         + GREATEST(word_similarity(@q, u.nickname), word_similarity(@q, u.username))
+		         + (CASE WHEN @py <> '' AND u.nickname_pinyin LIKE @py THEN 0.5 ELSE 0 END) DESC,
+		         s.follower_count DESC
+		LIMIT @limit
+	`, q.args()).Scan(&streamers).Error
+	return streamers, err
+}
+
+func searchRelays(q searchQuery) ([]SearchRelay, error) {
+	relays := make([]SearchRelay, 0)
+	err := repository.DB.Raw(`
+		SELECT id, name, category, cover_url, channel_name,
+		       COUNT(*) OVER() AS total
+		FROM relay_streams
+		WHERE status = 'running'
+		  AND (name ILIKE @like OR @q <% name OR category IN @categories)
+		ORDER BY (CASE WHEN name ILIKE @prefix THEN 1 ELSE 0 END) + word_similarity(@q, name) DESC,
+		         view_count DESC
+		LIMIT @limit
+	`, q.args()).Scan(&relays).Error
+	return relays, err
+}
+
+func searchSchedules(q searchQuery) ([]SearchSchedule, error) {
+	schedules := make([]SearchSchedule, 0)
+	err := repository.DB.Raw(`
+		SELECT s.id, s.title, s.category, s.cover_url, s.start_time, s.status, s.streamer_id,
+		       COALESCE(NULLIF(u.nickname, ''), u.username) AS streamer_name,
+		       COUNT(*) OVER() AS total
+		FROM live_schedules s
+		JOIN users u ON u.id = s.streamer_id
+		WHERE (s.status = 'live' OR (s.status = 'scheduled' AND s.start_time > @late))
+		  AND (s.title ILIKE @like OR @q <% s.title
+		       OR u.nickname ILIKE @like
+		       OR (@py <> '' AND u.nickname_pinyin LIKE @py)
+		       OR s.category IN @categories)
+		ORDER BY (CASE WHEN s.title ILIKE @prefix THEN 1 ELSE 0 END)
+		         + word_similarity(@q, s.title)
+		         + 0.5 * word_similarity(@q, COALESCE(u.nickname, '')) DESC,
+		         s.start_time ASC
+		LIMIT @limit
+	`, q.args()).Scan(&schedules).Error
+	return schedules, err
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -98,16 +99,96 @@ func (h *SocialHandler) Unfollow(c *gin.Context) {
 	})
 }
 
+type NotifySettingRequest struct {
+	StreamerID string `json:"streamer_id" binding:"required"`
+	LiveNotify *bool  `json:"live_notify" binding:"required"`
+}
+
+func (h *SocialHandler) UpdateNotifySetting(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req NotifySettingRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	result := repository.DB.Model(&models.FanRelation{}).
+		Where("user_id = ? AND streamer_id = ?", userID, req.StreamerID).
+		Update("live_notify", *req.LiveNotify)
+	if result.Error != nil {
+		response.Fail(c, "failed to update notify setting")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "not following this streamer")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"streamer_id": req.StreamerID,
+		"live_notify": *req.LiveNotify,
+	})
+}
+
+var followingPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"followed_at":    "fan_relations.followed_at",
+		"loyalty_points": "fan_relations.loyalty_points",
+	},
+	DefaultSort: "-followed_at",
+	Key:         "fan_relations.streamer_id",
+}
+
+var followerPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts: map[string]string{
+		"followed_at":    "fan_relations.followed_at",
+		"loyalty_points": "fan_relations.loyalty_points",
+	},
+	DefaultSort: "-followed_at",
+	Key:         "fan_relations.user_id",
+}
+
+// fanRelationPosition is the cursor position of a relation under sort.
+func fanRelationPosition(sort string, rel models.FanRelation, key uuid.UUID) (interface{}, interface{}) {
+	if sort == "loyalty_points" {
+		return rel.LoyaltyPoints, key
+	}
+	return rel.FollowedAt, key
+}
+
 func (h *SocialHandler) GetFollowings(c *gin.Context) {
 	userID := c.GetString("user_id")
 
+	page, err := pagination.Parse(c.Request.URL.Query(), followingPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	var relations []models.FanRelation
-	repository.DB.Preload("Streamer").Where("user_id = ?", userID).Find(&relations)
+	query := repository.DB.Model(&models.FanRelation{}).Joins("Streamer").Where("fan_relations.user_id = ?", userID)
+	total, err := page.Find(query, &relations)
+	if err != nil {
+		response.Fail(c, "failed to load followings")
+		return
+	}
+	n, next := page.Next(len(relations), func(i int) (interface{}, interface{}) {
+		return fanRelationPosition(page.Sort, relations[i], relations[i].StreamerID)
+	})
 
-	result := make([]gin.H, 0)
-	for _, rel := range relations {
+	result := make([]gin.H, 0, n)
+	for _, rel := range relations[:n] {
 		var user models.User
-		repository.DB.Select("nickname", "avatar_url").First(&user, "id = ?", rel.StreamerID)
+		if rel.Streamer != nil {
+			user = *rel.Streamer
+		}
 
 		result = append(result, gin.H{
 			"streamer_id":    rel.StreamerID,
@@ -115,23 +196,40 @@ func (h *SocialHandler) GetFollowings(c *gin.Context) {
 			"avatar":         user.AvatarURL,
 			"fan_level":      rel.FanLevel,
 			"loyalty_points": rel.LoyaltyPoints,
+			"live_notify":    rel.LiveNotify,
 			"followed_at":    rel.FollowedAt,
 		})
 	}
 
-	response.Success(c, result)
+	response.Paged(c, result, next, total)
 }
 
 func (h *SocialHandler) GetFollowers(c *gin.Context) {
 	streamerID := c.Param("streamer_id")
 
+	page, err := pagination.Parse(c.Request.URL.Query(), followerPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	var relations []models.FanRelation
-	repository.DB.Preload("User").Where("streamer_id = ?", streamerID).Find(&relations)
+	query := repository.DB.Model(&models.FanRelation{}).Joins("User").Where("fan_relations.streamer_id = ?", streamerID)
+	total, err := page.Find(query, &relations)
+	if err != nil {
+		response.Fail(c, "failed to load followers")
+		return
+	}
+	n, next := page.Next(len(relations), func(i int) (interface{}, interface{}) {
+		return fanRelationPosition(page.Sort, relations[i], relations[i].UserID)
+	})
 
-	result := make([]gin.H, 0)
-	for _, rel := range relations {
+	result := make([]gin.H, 0, n)
+	for _, rel := range relations[:n] {
 		var user models.User
-		repository.DB.Select("nickname", "avatar_url").First(&user, "id = ?", rel.UserID)
+		if rel.User != nil {
+			user = *rel.User
+		}
 
 		result = append(result, gin.H{
 			"user_id":        rel.UserID,
@@ -142,5 +240,5 @@ func (h *SocialHandler) GetFollowers(c *gin.Context) {
 		})
 	}
 
-	response.Success(c, result)
+	response.Paged(c, result, next, total)
 }
@@ -11,10 +11,12 @@ import (
 	"github.com/huya_live/api/pkg/response"
 )
 
-type SRSHandler struct{}
+type SRSHandler struct {
+	liveNotifier *LiveNotifier
+}
 
-func NewSRSHandler() *SRSHandler {
-	return &SRSHandler{}
+func NewSRSHandler(liveNotifier *LiveNotifier) *SRSHandler {
+	return &SRSHandler{liveNotifier: liveNotifier}
 }
 
 type PublishCallbackRequest struct {
@@ -70,6 +72,15 @@ func (h *SRSHandler) OnPublish(c *gin.Context) {
 
 	streamer.Status = "live"
 	repository.DB.Save(&streamer)
+	invalidateRoomCaches()
+
+	// Without a live room yet, CreateRoom sends the notification once the
+	// room exists; notifying now would push an empty room and take the
+	// cooldown from the real one.
+	var room models.LiveRoom
+	if err := repository.DB.Where("streamer_id = ? AND status = ?", streamer.UserID, "live").First(&room).Error; err == nil {
+		h.liveNotifier.NotifyLive(streamer.UserID, room.ID.String(), room.Title)
+	}
 
 	c.JSON(200, PublishCallbackResponse{
 		Code:    0,
@@ -94,6 +105,7 @@ func (h *SRSHandler) OnUnpublish(c *gin.Context) {
 		if streamer.Status == "live" {
 			streamer.Status = "offline"
 			repository.DB.Save(&streamer)
+			invalidateRoomCaches()
 		}
 	}
 
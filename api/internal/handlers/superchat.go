@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/filter"
+	"github.com/huya_live/api/pkg/response"
+)
+
+type SuperChatTier struct {
+	Tier      int    `json:"tier"`
+	Price     int    `json:"price"`
+	PinFor    int    `json:"pin_seconds"`
+	MaxLength int    `json:"max_length"`
+	Color     string `json:"color"`
+}
+
+var superChatTiers = []SuperChatTier{
+	{Tier: 1, Price: 30, PinFor: 60, MaxLength: 40, Color: "#2A60B2"},
+	{Tier: 2, Price: 100, PinFor: 120, MaxLength: 60, Color: "#427D9E"},
+	{Tier: 3, Price: 500, PinFor: 300, MaxLength: 100, Color: "#E2B52B"},
+	{Tier: 4, Price: 1000, PinFor: 600, MaxLength: 150, Color: "#E09443"},
+	{Tier: 5, Price: 2000, PinFor: 1800, MaxLength: 200, Color: "#AB1A32"},
+}
+
+func superChatTier(tier int) (SuperChatTier, bool) {
+	for _, t := range superChatTiers {
+		if t.Tier == tier {
+			return t, true
+		}
+	}
+	return SuperChatTier{}, false
+}
+
+type SuperChatHandler struct {
+	centrifugoClient *centrifugo.Client
+}
+
+func NewSuperChatHandler(centrifugoClient *centrifugo.Client) *SuperChatHandler {
+	return &SuperChatHandler{centrifugoClient: centrifugoClient}
+}
+
+type SendSuperChatRequest struct {
+	RoomID  string `json:"room_id" binding:"required"`
+	Tier    int    `json:"tier" binding:"required"`
+	Content string `json:"content" binding:"required,min=1,max=200"`
+}
+
+func (h *SuperChatHandler) GetTiers(c *gin.Context) {
+	response.Success(c, superChatTiers)
+}
+
+// SendSuperChat charges the sender through the same ledger and revenue path
+// as gifts and pins the message in the room for the tier's duration.
+func (h *SuperChatHandler) SendSuperChat(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req SendSuperChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	tier, ok := superChatTier(req.Tier)
+	if !ok {
+		response.BadRequest(c, "invalid tier")
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		response.BadRequest(c, "content cannot be empty")
+		return
+	}
+	if len([]rune(content)) > tier.MaxLength {
+		response.BadRequest(c, "content too long for this tier")
+		return
+	}
+
+	filtered := filterContent(content, filter.SeverityMedium)
+	if filtered.Blocked {
+		response.BadRequest(c, "content contains sensitive words")
+		return
+	}
+	content = filtered.Text
+
+	var room models.LiveRoom
+	if err := repository.DB.Where("id = ? AND status = ?", req.RoomID, "live").First(&room).Error; err != nil {
+		response.BadRequest(c, "room not found or not live")
+		return
+	}
+
+	if room.StreamerID.String() == userID {
+		response.BadRequest(c, "cannot send super chat to yourself")
+		return
+	}
+
+	var user models.User
+	if err := repository.DB.First(&user, "id = ?", userID).Error; err != nil {
+		response.BadRequest(c, "user not found")
+		return
+	}
+
+	if penalty := activeRoomPenalty(room.StreamerID, user.ID, penaltyMute, penaltyBan, penaltyKick); penalty != nil {
+		response.Forbidden(c, penaltyMessage(penalty))
+		return
+	}
+
+	if user.CoinBalance < tier.Price {
+		response.BadRequest(c, "insufficient coins")
+		return
+	}
+
+	var streamer models.Streamer
+	if err := repository.DB.Where("user_id = ?", room.StreamerID).First(&streamer).Error; err != nil {
+		response.BadRequest(c, "streamer not found")
+		return
+	}
+
+	tx := repository.DB.Begin()
+	if tx.Error != nil {
+		response.Fail(c, "failed to start transaction")
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := debitCoins(tx, &user, tier.Price, "super_chat", "Super chat in room "+room.ID.String()); err != nil {
+		tx.Rollback()
+		if err == errInsufficientCoins {
+			response.BadRequest(c, "insufficient coins")
+			return
+		}
+		response.Fail(c, "failed to deduct coins")
+		return
+	}
+
+	now := time.Now()
+	superChat := models.SuperChat{
+		RoomID:      room.ID,
+		StreamerID:  room.StreamerID,
+		SenderID:    user.ID,
+		Tier:        tier.Tier,
+		CoinAmount:  tier.Price,
+		Content:     content,
+		PinnedUntil: now.Add(time.Duration(tier.PinFor) * time.Second),
+	}
+	if err := tx.Create(&superChat).Error; err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to record super chat")
+		return
+	}
+
+	loyaltyPoints, _ := loyaltyPointsFor(tx, user, tier.Price)
+	if err := creditStreamer(tx, &streamer, user.ID, tier.Price, loyaltyPoints); err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to update streamer revenue")
+		return
+	}
+
+	msg := superChatMessage(superChat, user, tier)
+	if err := outbox.Publish(tx, centrifugo.GetChannels(req.RoomID)[0], msg); err != nil {
+		tx.Rollback()
+		response.Fail(c, "failed to queue super chat event")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		response.Fail(c, "failed to commit transaction")
+		return
+	}
+	outbox.Wake()
+	recordLeaderboards(room.ID, room.StreamerID, user.ID, tier.Price, superChat.CreatedAt)
+
+	response.Success(c, gin.H{
+		"super_chat_id":     superChat.ID.String(),
+		"tier":              tier.Tier,
+		"cost":              tier.Price,
+		"pinned_until":      superChat.PinnedUntil,
+		"remaining_balance": user.CoinBalance,
+		"loyalty_points":    loyaltyPoints,
+	})
+}
+
+// GetPinnedSuperChats returns the room's super chats that are still pinned,
+// so late joiners see them too.
+func (h *SuperChatHandler) GetPinnedSuperChats(c *gin.Context) {
+	roomID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "invalid room id")
+		return
+	}
+
+	var superChats []models.SuperChat
+	repository.DB.Where("room_id = ? AND pinned_until > ?", roomID, time.Now()).
+		Order("created_at DESC").
+		Find(&superChats)
+
+	senderIDs := make([]uuid.UUID, 0, len(superChats))
+	for _, sc := range superChats {
+		senderIDs = append(senderIDs, sc.SenderID)
+	}
+	var senders []models.User
+	if len(senderIDs) > 0 {
+		repository.DB.Select("id", "username", "nickname", "avatar_url", "level").Where("id IN ?", senderIDs).Find(&senders)
+	}
+	senderByID := make(map[uuid.UUID]models.User, len(senders))
+	for _, u := range senders {
+		senderByID[u.ID] = u
+	}
+
+	result := make([]interface{}, 0, len(superChats))
+	for _, sc := range superChats {
+		tier, _ := superChatTier(sc.Tier)
+		result = append(result, superChatMessage(sc, senderByID[sc.SenderID], tier).Data)
+	}
+
+	response.Success(c, result)
+}
+
+func superChatMessage(sc models.SuperChat, sender models.User, tier SuperChatTier) centrifugo.SuperChatMessage {
+	msg := centrifugo.SuperChatMessage{
+		Type:      "super_chat",
+		Timestamp: sc.CreatedAt.UnixMilli(),
+	}
+	msg.Data.ID = sc.ID.String()
+	msg.Data.Sender.ID = sc.SenderID.String()
+	msg.Data.Sender.Nickname = displayName(sender)
+	msg.Data.Sender.Level = sender.Level
+	msg.Data.Sender.Avatar = sender.AvatarURL
+	msg.Data.Tier = sc.Tier
+	msg.Data.Amount = sc.CoinAmount
+	msg.Data.Content = sc.Content
+	msg.Data.Color = tier.Color
+	msg.Data.PinnedUntil = sc.PinnedUntil.UnixMilli()
+	return msg
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+)
+
+const systemConfigCacheTTL = 30 * time.Second
+
+var systemConfigCache = struct {
+	sync.RWMutex
+	values   map[string]string
+	loadedAt time.Time
+}{}
+
+// SystemConfigInt reads an integer system_configs value, falling back to def
+// when the key is missing or not a number. Values are cached briefly since
+// they are read on hot paths such as danmu rate limiting.
+func SystemConfigInt(key string, def int) int {
+	value, ok := systemConfigValue(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func systemConfigValue(key string) (string, bool) {
+	systemConfigCache.RLock()
+	if systemConfigCache.values != nil && time.Since(systemConfigCache.loadedAt) < systemConfigCacheTTL {
+		value, ok := systemConfigCache.values[key]
+		systemConfigCache.RUnlock()
+		return value, ok
+	}
+	systemConfigCache.RUnlock()
+
+	var configs []models.SystemConfig
+	if err := repository.DB.Find(&configs).Error; err != nil {
+		return "", false
+	}
+
+	values := make(map[string]string, len(configs))
+	for _, cfg := range configs {
+		values[cfg.Key] = cfg.Value
+	}
+
+	systemConfigCache.Lock()
+	systemConfigCache.values = values
+	systemConfigCache.loadedAt = time.Now()
+	systemConfigCache.Unlock()
+
+	value, ok := values[key]
+	return value, ok
+}
+
+func invalidateSystemConfig() {
+	systemConfigCache.Lock()
+	systemConfigCache.values = nil
+	systemConfigCache.Unlock()
+}
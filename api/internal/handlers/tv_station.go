@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -20,7 +21,7 @@ func NewPredefinedTVHandler() *PredefinedTVHandler {
 
 type AddPredefinedTVRequest struct {
 	Name        string `json:"name" binding:"required,min=2,max=100"`
-	Description string `json:"description" max=500"`
+	Description string `json:"description" binding:"max=500"`
 	SourceURL   string `json:"source_url" binding:"required"`
 	Category    string `json:"category"`
 	Country     string `json:"country"`
@@ -40,9 +41,48 @@ type TVStationResponse struct {
 	IsActive    bool   `json:"is_active"`
 }
 
+var tvStationPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Sorts:        map[string]string{"sort_order": "sort_order"},
+	DefaultSort:  "sort_order",
+	Key:          "id",
+	Filters: map[string][]string{
+		"category": nil,
+		"country":  nil,
+		"language": nil,
+	},
+}
+
 func (h *PredefinedTVHandler) GetTVStations(c *gin.Context) {
+	page, err := pagination.Parse(c.Request.URL.Query(), tvStationPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.PredefinedRelay{}).Where("is_active = ?", true)
+	if category := page.Filter("category"); category != "" {
+		query = query.Where("category IN ?", categoryFilter(category))
+	}
+	if country := page.Filter("country"); country != "" {
+		query = query.Where("country = ?", country)
+	}
+	if language := page.Filter("language"); language != "" {
+		query = query.Where("language = ?", language)
+	}
+
 	var stations []models.PredefinedRelay
-	repository.DB.Where("is_active = ?", true).Order("sort_order ASC").Find(&stations)
+	total, err := page.Find(query, &stations)
+	if err != nil {
+		response.Fail(c, "failed to load TV stations")
+		return
+	}
+	n, next := page.Next(len(stations), func(i int) (interface{}, interface{}) {
+		return stations[i].SortOrder, stations[i].ID
+	})
+	stations = stations[:n]
 
 	result := make([]TVStationResponse, 0, len(stations))
 	for _, s := range stations {
@@ -59,7 +99,7 @@ func (h *PredefinedTVHandler) GetTVStations(c *gin.Context) {
 		})
 	}
 
-	response.Success(c, result)
+	response.Paged(c, result, next, total)
 }
 
 func (h *PredefinedTVHandler) AddTVStation(c *gin.Context) {
@@ -69,11 +109,17 @@ func (h *PredefinedTVHandler) AddTVStation(c *gin.Context) {
 		return
 	}
 
+	category, ok := resolveCategory(req.Category)
+	if !ok {
+		response.BadRequest(c, "unknown category")
+		return
+	}
+
 	station := models.PredefinedRelay{
 		Name:        req.Name,
 		Description: req.Description,
 		SourceURL:   req.SourceURL,
-		Category:    req.Category,
+		Category:    category,
 		Country:     req.Country,
 		Language:    req.Language,
 		CoverURL:    req.CoverURL,
@@ -135,6 +181,9 @@ func (h *PredefinedTVHandler) CreateRelaysFromTVStations(c *gin.Context) {
 			created++
 		}
 	}
+	if created > 0 {
+		invalidateRoomCaches()
+	}
 
 	response.Success(c, gin.H{
 		"message": "TV stations converted to relay streams",
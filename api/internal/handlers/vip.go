@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/outbox"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"github.com/huya_live/api/pkg/ratelimit"
+	"github.com/huya_live/api/pkg/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSubscriptionDowngrade = errors.New("cannot downgrade an active subscription")
+
+// upgradeExpiry is when a subscription upgraded at now ends. The time left
+// at the old tier is converted to time at the new tier by monthly price, and
+// the period just bought is added on top, so nothing already paid is lost
+// and the upgrade itself is not free. VIP and channel subscriptions both
+// upgrade this way.
+func upgradeExpiry(now, expiresAt time.Time, oldPrice, newPrice int, period time.Duration) time.Time {
+	expiry := now.Add(period)
+	if left := expiresAt.Sub(now); left > 0 && oldPrice > 0 && newPrice > 0 {
+		expiry = expiry.Add(time.Duration(float64(left) * float64(oldPrice) / float64(newPrice)))
+	}
+	return expiry
+}
+
+const (
+	vipStatusActive  = "active"
+	vipStatusExpired = "expired"
+
+	vipPeriod         = 30 * 24 * time.Hour
+	vipReminderBefore = 3 * 24 * time.Hour
+	entranceCooldown  = 5 * time.Minute
+)
+
+// VIPTier is a purchasable noble tier. Tier numbers share the scale of the
+// earned noble tiers, so the higher of the two applies.
+type VIPTier struct {
+	Tier            int     `json:"tier"`
+	Name            string  `json:"name"`
+	MonthlyPrice    int     `json:"monthly_price"`
+	BonusMultiplier float64 `json:"bonus_multiplier"`
+	EntranceEffect  string  `json:"entrance_effect"`
+	SpecialColors   bool    `json:"special_colors"`
+}
+
+var vipTiers = []VIPTier{
+	{Tier: 1, Name: "骑士", MonthlyPrice: 300, BonusMultiplier: 1.05},
+	{Tier: 2, Name: "子爵", MonthlyPrice: 1000, BonusMultiplier: 1.1, EntranceEffect: "glow", SpecialColors: true},
+	{Tier: 3, Name: "伯爵", MonthlyPrice: 3000, BonusMultiplier: 1.2, EntranceEffect: "banner", SpecialColors: true},
+	{Tier: 4, Name: "侯爵", MonthlyPrice: 8000, BonusMultiplier: 1.3, EntranceEffect: "dragon", SpecialColors: true},
+}
+
+// vipGiftCategories maps exclusive Gift.Category values to the VIP tier
+// needed to send them.
+var vipGiftCategories = map[string]int{
+	"vip":   1,
+	"noble": 3,
+}
+
+func vipTierByNumber(tier int) (VIPTier, bool) {
+	for _, t := range vipTiers {
+		if t.Tier == tier {
+			return t, true
+		}
+	}
+	return VIPTier{}, false
+}
+
+// activeVIPTier returns the user's paid tier, or the zero tier if they have
+// no active subscription.
+func activeVIPTier(userID string) VIPTier {
+	var sub models.VIPSubscription
+	if err := repository.DB.Where("user_id = ? AND status = ? AND expires_at > ?", userID, vipStatusActive, time.Now()).
+		First(&sub).Error; err != nil {
+		return VIPTier{}
+	}
+	tier, _ := vipTierByNumber(sub.Tier)
+	return tier
+}
+
+// effectiveNobleTier is the higher of the earned and the purchased tier.
+func effectiveNobleTier(ctx context.Context, userID string) int {
+	earned, _, _ := earnedNobleTier(ctx, userID)
+	if vip := activeVIPTier(userID); vip.Tier > earned.Tier {
+		return vip.Tier
+	}
+	return earned.Tier
+}
+
+// noblePerks returns the user's effective noble tier and the perks that go
+// with it: those of the highest purchasable tier at or below it, so earned
+// tiers above the purchasable range keep the top perks.
+func noblePerks(ctx context.Context, userID string) (int, VIPTier) {
+	tier := effectiveNobleTier(ctx, userID)
+	var perks VIPTier
+	for _, t := range vipTiers {
+		if t.Tier <= tier {
+			perks = t
+		}
+	}
+	return tier, perks
+}
+
+type VIPHandler struct{}
+
+func NewVIPHandler() *VIPHandler {
+	return &VIPHandler{}
+}
+
+func (h *VIPHandler) GetTiers(c *gin.Context) {
+	response.Success(c, gin.H{
+		"tiers":           vipTiers,
+		"gift_categories": vipGiftCategories,
+		"period_days":     int(vipPeriod.Hours() / 24),
+	})
+}
+
+func (h *VIPHandler) GetMySubscription(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var sub models.VIPSubscription
+	if err := repository.DB.First(&sub, "user_id = ?", userID).Error; err != nil {
+		response.Success(c, gin.H{"subscription": nil, "noble": effectiveNobleTier(c.Request.Context(), userID)})
+		return
+	}
+
+	tier, _ := vipTierByNumber(sub.Tier)
+	response.Success(c, gin.H{
+		"subscription": sub,
+		"tier":         tier,
+		"active":       sub.Status == vipStatusActive && sub.ExpiresAt.After(time.Now()),
+		"noble":        effectiveNobleTier(c.Request.Context(), userID),
+	})
+}
+
+type SubscribeVIPRequest struct {
+	Tier      int   `json:"tier" binding:"required"`
+	AutoRenew *bool `json:"auto_renew"`
+}
+
+// Subscribe buys a month of a tier. Buying the current tier again extends
+// it; buying a higher tier upgrades immediately, keeping the prorated value
+// of the time left (see upgradeExpiry). Downgrades take effect by turning
+// off auto-renew and subscribing again after expiry.
+func (h *VIPHandler) Subscribe(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req SubscribeVIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	tier, ok := vipTierByNumber(req.Tier)
+	if !ok {
+		response.BadRequest(c, "unknown vip tier")
+		return
+	}
+
+	var sub models.VIPSubscription
+	var user models.User
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		found := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, "user_id = ?", userID).Error == nil
+		active := found && sub.Status == vipStatusActive && sub.ExpiresAt.After(now)
+
+		switch {
+		case active && sub.Tier == tier.Tier:
+			sub.ExpiresAt = sub.ExpiresAt.Add(vipPeriod)
+		case active && sub.Tier > tier.Tier:
+			return errSubscriptionDowngrade
+		case active:
+			current, _ := vipTierByNumber(sub.Tier)
+			sub.ExpiresAt = upgradeExpiry(now, sub.ExpiresAt, current.MonthlyPrice, tier.MonthlyPrice, vipPeriod)
+			sub.Tier = tier.Tier
+			sub.StartedAt = now
+		default:
+			sub.UserID = user.ID
+			sub.Tier = tier.Tier
+			sub.StartedAt = now
+			sub.ExpiresAt = now.Add(vipPeriod)
+			if !found {
+				sub.AutoRenew = true
+			}
+		}
+		sub.Status = vipStatusActive
+		sub.RemindedAt = nil
+		if req.AutoRenew != nil {
+			sub.AutoRenew = *req.AutoRenew
+		}
+
+		if _, err := debitCoins(tx, &user, tier.MonthlyPrice, "vip", fmt.Sprintf("VIP %s (%d days)", tier.Name, int(vipPeriod.Hours()/24))); err != nil {
+			return err
+		}
+		return tx.Save(&sub).Error
+	})
+	switch err {
+	case nil:
+	case errInsufficientCoins:
+		response.BadRequest(c, "insufficient coins")
+		return
+	case errSubscriptionDowngrade:
+		response.BadRequest(c, "cannot downgrade an active subscription; turn off auto-renew and subscribe after it expires")
+		return
+	default:
+		response.Fail(c, "failed to subscribe")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"subscription":      sub,
+		"tier":              tier,
+		"remaining_balance": user.CoinBalance,
+	})
+}
+
+func (h *VIPHandler) SetAutoRenew(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req struct {
+		AutoRenew *bool `json:"auto_renew" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "auto_renew is required")
+		return
+	}
+
+	result := repository.DB.Model(&models.VIPSubscription{}).
+		Where("user_id = ? AND status = ?", userID, vipStatusActive).
+		Updates(map[string]interface{}{"auto_renew": *req.AutoRenew, "reminded_at": nil})
+	if result.Error != nil {
+		response.Fail(c, "failed to update subscription")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.BadRequest(c, "no active subscription")
+		return
+	}
+
+	response.Success(c, gin.H{"auto_renew": *req.AutoRenew})
+}
+
+// RenewVIPSubscriptions is the scheduled billing run. It reminds users whose
+// subscription will lapse soon, charges auto-renewing subscriptions that are
+// due, and expires the rest.
+func RenewVIPSubscriptions() (string, error) {
+	now := time.Now()
+
+	var expiring []models.VIPSubscription
+	if err := repository.DB.Where("status = ? AND reminded_at IS NULL AND expires_at > ? AND expires_at <= ?",
+		vipStatusActive, now, now.Add(vipReminderBefore)).Find(&expiring).Error; err != nil {
+		return "", err
+	}
+	reminded := 0
+	for _, sub := range expiring {
+		if remindVIP(sub, now) {
+			reminded++
+		}
+	}
+
+	var due []models.VIPSubscription
+	if err := repository.DB.Where("status = ? AND expires_at <= ?", vipStatusActive, now).Find(&due).Error; err != nil {
+		return "", err
+	}
+	renewed, expired := 0, 0
+	for _, sub := range due {
+		ok, err := renewVIP(sub.UserID, now)
+		if err != nil {
+			log.Printf("vip renewal for user %s failed: %v", sub.UserID, err)
+			continue
+		}
+		if ok {
+			renewed++
+		} else {
+			expired++
+		}
+	}
+
+	if reminded+renewed+expired > 0 {
+		outbox.Wake()
+	}
+	return fmt.Sprintf("reminded %d, renewed %d, expired %d", reminded, renewed, expired), nil
+}
+
+// remindVIP warns about an upcoming expiry unless auto-renew will cover it.
+func remindVIP(sub models.VIPSubscription, now time.Time) bool {
+	tier, _ := vipTierByNumber(sub.Tier)
+
+	var user models.User
+	if err := repository.DB.Select("id", "coin_balance").First(&user, "id = ?", sub.UserID).Error; err != nil {
+		return false
+	}
+
+	content := fmt.Sprintf("你的%s贵族将于 %s 到期", tier.Name, sub.ExpiresAt.Format("2006-01-02 15:04"))
+	switch {
+	case !sub.AutoRenew:
+		content += "，续费即可保留特权"
+	case user.CoinBalance < tier.MonthlyPrice:
+		content += fmt.Sprintf("，自动续费需要 %d 金币，当前余额不足", tier.MonthlyPrice)
+	default:
+		return false
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := notifyUser(tx, sub.UserID, "vip_expiring", "贵族即将到期", content, "/vip"); err != nil {
+			return err
+		}
+		return tx.Model(&models.VIPSubscription{}).Where("user_id = ?", sub.UserID).Update("reminded_at", now).Error
+	})
+	return err == nil
+}
+
+// renewVIP charges one more period for a due subscription, or expires it if
+// auto-renew is off or the balance is short. It reports whether it renewed.
+func renewVIP(userID uuid.UUID, now time.Time) (bool, error) {
+	renewed := false
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		var sub models.VIPSubscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ? AND expires_at <= ?", userID, vipStatusActive, now).
+			First(&sub).Error; err != nil {
+			return nil
+		}
+		tier, _ := vipTierByNumber(sub.Tier)
+
+		if sub.AutoRenew {
+			user := models.User{ID: userID}
+			_, err := debitCoins(tx, &user, tier.MonthlyPrice, "vip", fmt.Sprintf("VIP %s auto-renewal", tier.Name))
+			if err == nil {
+				expiresAt := sub.ExpiresAt.Add(vipPeriod)
+				if expiresAt.Before(now) {
+					expiresAt = now.Add(vipPeriod)
+				}
+				renewed = true
+				if err := tx.Model(&sub).Updates(map[string]interface{}{
+					"expires_at":  expiresAt,
+					"reminded_at": nil,
+				}).Error; err != nil {
+					return err
+				}
+				return notifyUser(tx, userID, "vip_renewed", "贵族已续费",
+					fmt.Sprintf("已扣除 %d 金币，%s贵族有效期至 %s", tier.MonthlyPrice, tier.Name, expiresAt.Format("2006-01-02")), "/vip")
+			}
+			if err != errInsufficientCoins {
+				return err
+			}
+		}
+
+		if err := tx.Model(&sub).Update("status", vipStatusExpired).Error; err != nil {
+			return err
+		}
+		if sub.AutoRenew {
+			return notifyUser(tx, userID, "vip_renew_failed", "贵族续费失败",
+				fmt.Sprintf("金币余额不足 %d，%s贵族已到期", tier.MonthlyPrice, tier.Name), "/vip")
+		}
+		return notifyUser(tx, userID, "vip_expired", "贵族已到期", tier.Name+"贵族已到期，特权已失效", "/vip")
+	})
+	return renewed, err
+}
+
+// announceEntrance shows a noble's entrance effect to the room they joined.
+// Reconnects within entranceCooldown are not announced again.
+func announceEntrance(client *centrifugo.Client, roomID, userID string) {
+	tier, perks := noblePerks(context.Background(), userID)
+	if perks.EntranceEffect == "" {
+		return
+	}
+
+	if ok, _, err := ratelimit.Cooldown(context.Background(), "entrance:"+roomID+":"+userID, entranceCooldown); err == nil && !ok {
+		return
+	}
+
+	client.Publish(centrifugo.GetChannels(roomID)[0], gin.H{
+		"type":      "entrance",
+		"timestamp": time.Now().UnixMilli(),
+		"data": gin.H{
+			"user_id":  userID,
+			"nickname": getNicknameByID(uuid.MustParse(userID)),
+			"tier":     tier,
+			"name":     nobleTierName(tier),
+			"effect":   perks.EntranceEffect,
+		},
+	})
+}
+
+// vipBonus scales a loyalty multiplier by the sender's VIP tier.
+func vipBonus(userID string, multiplier float64) float64 {
+	if tier := activeVIPTier(userID); tier.BonusMultiplier > 0 {
+		return multiplier * tier.BonusMultiplier
+	}
+	return multiplier
+}
+
+func vipTierLabel(tier int) string {
+	if t, ok := vipTierByNumber(tier); ok {
+		return t.Name
+	}
+	return strconv.Itoa(tier)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpgradeExpiry(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	period := 30 * 24 * time.Hour
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		left      time.Duration
+		oldPrice  int
+		newPrice  int
+		wantAfter time.Duration
+	}{
+		{name: "half the time left at a third of the price", left: 15 * day, oldPrice: 1000, newPrice: 3000, wantAfter: period + 5*day},
+		{name: "whole period left at a tenth of the price", left: period, oldPrice: 100, newPrice: 1000, wantAfter: period + 3*day},
+		{name: "already expired", left: -day, oldPrice: 1000, newPrice: 3000, wantAfter: period},
+		{name: "unknown old tier", left: 15 * day, oldPrice: 0, newPrice: 3000, wantAfter: period},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := upgradeExpiry(now, now.Add(tt.left), tt.oldPrice, tt.newPrice, period)
+			if want := now.Add(tt.wantAfter); !got.Equal(want) {
+				t.Errorf("upgradeExpiry = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/huya_live/api/internal/models"
 	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/pagination"
 	"github.com/huya_live/api/pkg/response"
 )
 
@@ -94,17 +95,42 @@ func (h *WalletHandler) GetBalance(c *gin.Context) {
 	})
 }
 
+var transactionPage = pagination.Spec{
+	Mode:         pagination.Cursor,
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "created_at", "amount": "amount"},
+	DefaultSort:  "-created_at",
+	Key:          "id",
+	Filters:      map[string][]string{"type": nil},
+}
+
 func (h *WalletHandler) GetTransactionHistory(c *gin.Context) {
 	userID := c.GetString("user_id")
-	limit := 20
-	offset := 0
+
+	page, err := pagination.Parse(c.Request.URL.Query(), transactionPage)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	query := repository.DB.Model(&models.CoinTransaction{}).Where("user_id = ?", userID)
+	if t := page.Filter("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
 
 	var transactions []models.CoinTransaction
-	repository.DB.Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&transactions)
+	total, err := page.Find(query, &transactions)
+	if err != nil {
+		response.Fail(c, "failed to load transactions")
+		return
+	}
 
-	response.Success(c, transactions)
+	n, next := page.Next(len(transactions), func(i int) (interface{}, interface{}) {
+		if page.Sort == "amount" {
+			return transactions[i].Amount, transactions[i].ID
+		}
+		return transactions[i].CreatedAt, transactions[i].ID
+	})
+	response.Paged(c, transactions[:n], next, total)
 }
@@ -0,0 +1,171 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/huya_live/api/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Boards ranked by time window.
+const (
+	BoardStreamer = "streamer" // coins received
+	BoardGifter   = "gifter"   // coins spent
+)
+
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// Periods lists the windows every gift is counted in.
+var Periods = []string{PeriodDaily, PeriodWeekly, PeriodMonthly}
+
+// roomTTL keeps a session's contributor board around for replays after the
+// room goes offline.
+const roomTTL = 7 * 24 * time.Hour
+
+var ErrUnknownPeriod = errors.New("unknown leaderboard period")
+
+type Entry struct {
+	Member string
+	Score  int64
+}
+
+// RoomKey is the contributor board of one live session.
+func RoomKey(roomID string) string {
+	return "lb:room:" + roomID
+}
+
+// PeriodKey is the board for the window of period containing t. Each window
+// has its own key, so rotation is just a new key plus a TTL on the old one.
+func PeriodKey(board, period string, t time.Time) (string, error) {
+	switch period {
+	case PeriodDaily:
+		return fmt.Sprintf("lb:%s:daily:%s", board, t.Format("20060102")), nil
+	case PeriodWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("lb:%s:weekly:%dW%02d", board, year, week), nil
+	case PeriodMonthly:
+		return fmt.Sprintf("lb:%s:monthly:%s", board, t.Format("200601")), nil
+	}
+	return "", ErrUnknownPeriod
+}
+
+// LifetimeKey is the all-time board. Only gifters have one; lifetime
+// streamer rankings read streamers.total_revenue. It never expires.
+func LifetimeKey(board string) string {
+	return "lb:" + board + ":all"
+}
+
+// PeriodStart returns the first instant of the window of period containing t.
+func PeriodStart(period string, t time.Time) (time.Time, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case PeriodDaily:
+		return day, nil
+	case PeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset), nil
+	case PeriodMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, ErrUnknownPeriod
+}
+
+// periodTTL outlives the window by one more window so that "last period"
+// stays readable until it is replaced.
+func periodTTL(period string) time.Duration {
+	switch period {
+	case PeriodDaily:
+		return 2 * 24 * time.Hour
+	case PeriodWeekly:
+		return 14 * 24 * time.Hour
+	default:
+		return 62 * 24 * time.Hour
+	}
+}
+
+// Record counts amount coins sent by senderID to streamerID in roomID on the
+// session board, on every window of both the streamer and gifter boards and
+// on the lifetime gifter board.
+func Record(ctx context.Context, roomID, streamerID, senderID string, amount int64, at time.Time) error {
+	pipe := redis.GetClient().TxPipeline()
+
+	roomKey := RoomKey(roomID)
+	pipe.ZIncrBy(ctx, roomKey, float64(amount), senderID)
+	pipe.Expire(ctx, roomKey, roomTTL)
+
+	for _, period := range Periods {
+		for board, member := range map[string]string{BoardStreamer: streamerID, BoardGifter: senderID} {
+			key, _ := PeriodKey(board, period, at)
+			pipe.ZIncrBy(ctx, key, float64(amount), member)
+			pipe.Expire(ctx, key, periodTTL(period))
+		}
+	}
+	pipe.ZIncrBy(ctx, LifetimeKey(BoardGifter), float64(amount), senderID)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Top returns the n highest scores on key.
+func Top(ctx context.Context, key string, n int) ([]Entry, error) {
+	members, err := redis.ZRevRangeWithScores(ctx, key, 0, int64(n-1))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(members))
+	for _, m := range members {
+		entries = append(entries, Entry{Member: fmt.Sprint(m.Member), Score: int64(m.Score)})
+	}
+	return entries, nil
+}
+
+// Rank returns member's 1-based rank and score on key, or rank 0 when the
+// member is not on the board.
+func Rank(ctx context.Context, key, member string) (int64, int64, error) {
+	rank, err := redis.ZRevRank(ctx, key, member)
+	if err == goredis.Nil {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	score, err := redis.ZScore(ctx, key, member)
+	if err != nil && err != goredis.Nil {
+		return 0, 0, err
+	}
+	return rank + 1, int64(score), nil
+}
+
+// replace swaps key for a board built from scores, so readers never see a
+// half-rebuilt board. A zero ttl keeps the board without expiry.
+func replace(ctx context.Context, key string, scores map[string]int64, ttl time.Duration) error {
+	client := redis.GetClient()
+	if len(scores) == 0 {
+		return client.Del(ctx, key).Err()
+	}
+
+	tmp := key + ":rebuild"
+	members := make([]goredis.Z, 0, len(scores))
+	for member, score := range scores {
+		members = append(members, goredis.Z{Member: member, Score: float64(score)})
+	}
+
+	pipe := client.TxPipeline()
+	pipe.Del(ctx, tmp)
+	pipe.ZAdd(ctx, tmp, members...)
+	if ttl > 0 {
+		pipe.Expire(ctx, tmp, ttl)
+	}
+	pipe.Rename(ctx, tmp, key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
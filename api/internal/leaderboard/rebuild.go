@@ -0,0 +1,112 @@
+package leaderboard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// contributions is every coin that counts towards a leaderboard: gifts and
+// super chats.
+const contributions = `(
+	SELECT room_id, receiver_id AS streamer_id, sender_id, coin_amount, created_at FROM gift_transactions
+	UNION ALL
+	SELECT room_id, streamer_id, sender_id, coin_amount, created_at FROM super_chats
+) AS c`
+
+type scoreRow struct {
+	Member string
+	Total  int64
+}
+
+// Rebuild recomputes the current window of every period board, the lifetime
+// gifter board and the session board of every live room from Postgres. It
+// is used to recover after Redis loses data.
+func Rebuild(ctx context.Context, db *gorm.DB, now time.Time) (string, error) {
+	boards := 0
+	columns := map[string]string{BoardStreamer: "streamer_id", BoardGifter: "sender_id"}
+
+	for _, period := range Periods {
+		start, _ := PeriodStart(period, now)
+		for board, column := range columns {
+			scores, err := sumBy(db, column, "created_at >= ?", start)
+			if err != nil {
+				return "", err
+			}
+			key, _ := PeriodKey(board, period, now)
+			if err := replace(ctx, key, scores, periodTTL(period)); err != nil {
+				return "", err
+			}
+			boards++
+		}
+	}
+
+	scores, err := sumBy(db, "sender_id", "")
+	if err != nil {
+		return "", err
+	}
+	if err := replace(ctx, LifetimeKey(BoardGifter), scores, 0); err != nil {
+		return "", err
+	}
+	boards++
+
+	var roomIDs []string
+	if err := db.Table("live_rooms").Where("status = ?", "live").Pluck("id", &roomIDs).Error; err != nil {
+		return "", err
+	}
+	for _, roomID := range roomIDs {
+		scores, err := sumBy(db, "sender_id", "room_id = ?", roomID)
+		if err != nil {
+			return "", err
+		}
+		if err := replace(ctx, RoomKey(roomID), scores, roomTTL); err != nil {
+			return "", err
+		}
+		boards++
+	}
+
+	return fmt.Sprintf("rebuilt %d leaderboards", boards), nil
+}
+
+// sumBy totals contributions per column value, over the rows matching where
+// or over all of them when where is empty.
+func sumBy(db *gorm.DB, column, where string, args ...interface{}) (map[string]int64, error) {
+	query := db.Table(contributions).Select(column + "::text AS member, SUM(coin_amount) AS total")
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+
+	var rows []scoreRow
+	if err := query.Group(column).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		scores[r.Member] = r.Total
+	}
+	return scores, nil
+}
+
+// RoomTop ranks a room's gifters from Postgres, gifts and super chats alike.
+// It is the fallback when the Redis room board is unavailable.
+func RoomTop(db *gorm.DB, roomID string, limit int) ([]Entry, error) {
+	var rows []scoreRow
+	if err := db.Table(contributions).
+		Select("sender_id::text AS member, SUM(coin_amount) AS total").
+		Where("room_id = ?", roomID).
+		Group("sender_id").
+		Order("total DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, r := range rows {
+		entries = append(entries, Entry{Member: r.Member, Score: r.Total})
+	}
+	return entries, nil
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/pkg/ratelimit"
+	"github.com/huya_live/api/pkg/response"
+)
+
+// RateLimit applies a per-user token bucket to a route group. limit is
+// evaluated on every request so that admin config changes apply without a
+// restart. Anonymous callers are keyed by client IP.
+func RateLimit(name string, limit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetString("user_id")
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+
+		allowed, retryAfter, err := ratelimit.Allow(c.Request.Context(), name+":"+key, limit())
+		if err == nil && !allowed {
+			response.TooManyRequests(c, "too many requests, slow down", name+"_rate_limited", retryAfter)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,20 +6,24 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Username     string     `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"`
-	PasswordHash string     `gorm:"type:varchar(255);not null" json:"-"`
-	Nickname     string     `gorm:"type:varchar(100)" json:"nickname"`
-	AvatarURL    string     `gorm:"type:text" json:"avatar_url"`
-	Phone        string     `gorm:"type:varchar(20);uniqueIndex" json:"phone"`
-	Email        string     `gorm:"type:varchar(100);uniqueIndex" json:"email"`
-	Level        int        `gorm:"default:1" json:"level"`
-	Exp          int64      `gorm:"default:0" json:"exp"`
-	CoinBalance  int        `gorm:"default:0" json:"coin_balance"`
-	Status       string     `gorm:"type:varchar(20);default:'active'" json:"status"`
-	LastLoginAt  *time.Time `json:"last_login_at"`
-	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Username     string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"`
+	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
+	Nickname     string    `gorm:"type:varchar(100)" json:"nickname"`
+	// NicknamePinyin holds the pinyin initials of Nickname for search.
+	NicknamePinyin string `gorm:"type:varchar(100)" json:"-"`
+	AvatarURL      string `gorm:"type:text" json:"avatar_url"`
+	Phone          string `gorm:"type:varchar(20);uniqueIndex" json:"phone"`
+	Email          string `gorm:"type:varchar(100);uniqueIndex" json:"email"`
+	Level          int    `gorm:"default:1" json:"level"`
+	Exp            int64  `gorm:"default:0" json:"exp"`
+	CoinBalance    int    `gorm:"default:0" json:"coin_balance"`
+	Status         string `gorm:"type:varchar(20);default:'active'" json:"status"`
+	// HideFromRankings keeps the user off public spending rankings.
+	HideFromRankings bool       `gorm:"default:false" json:"hide_from_rankings"`
+	LastLoginAt      *time.Time `json:"last_login_at"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 type Streamer struct {
@@ -33,22 +37,31 @@ type Streamer struct {
 	FollowerCount     int        `gorm:"default:0" json:"follower_count"`
 	TotalLiveDuration int        `gorm:"default:0" json:"total_live_duration"`
 	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations are only loaded on request and add no FK constraints.
+	User *User `gorm:"foreignKey:UserID;-:migration" json:"user,omitempty"`
 }
 
 type LiveRoom struct {
-	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	StreamerID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"streamer_id"`
-	Title       string     `gorm:"type:varchar(200);not null" json:"title"`
-	Category    string     `gorm:"type:varchar(50)" json:"category"`
-	CoverURL    string     `gorm:"type:text" json:"cover_url"`
-	ChannelName string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"channel_name"`
-	Status      string     `gorm:"type:varchar(20);default:'ended'" json:"status"`
-	StartAt     *time.Time `json:"start_at"`
-	EndAt       *time.Time `json:"end_at"`
-	PeakOnline  int        `gorm:"default:0" json:"peak_online"`
-	TotalViews  int        `gorm:"default:0" json:"total_views"`
-	RecordURL   string     `gorm:"type:text" json:"record_url"`
-	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"streamer_id"`
+	Title           string     `gorm:"type:varchar(200);not null" json:"title"`
+	Category        string     `gorm:"type:varchar(50)" json:"category"`
+	CoverURL        string     `gorm:"type:text" json:"cover_url"`
+	ChannelName     string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"channel_name"`
+	Status          string     `gorm:"type:varchar(20);default:'ended'" json:"status"`
+	StartAt         *time.Time `json:"start_at"`
+	EndAt           *time.Time `json:"end_at"`
+	PeakOnline      int        `gorm:"default:0" json:"peak_online"`
+	TotalViews      int        `gorm:"default:0" json:"total_views"`
+	RecordURL       string     `gorm:"type:text" json:"record_url"`
+	ScheduleID      *uuid.UUID `gorm:"type:uuid;index" json:"schedule_id"`
+	SlowModeSeconds int        `gorm:"default:0" json:"slow_mode_seconds"`
+	SubscriberOnly  bool       `gorm:"default:false" json:"subscriber_only"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Streamer is the streaming user; load it with Joins("Streamer").
+	Streamer *User `gorm:"foreignKey:StreamerID;-:migration" json:"streamer,omitempty"`
 }
 
 type Gift struct {
@@ -98,8 +111,12 @@ type FanRelation struct {
 	BadgeName       string     `gorm:"type:varchar(20)" json:"badge_name"`
 	BadgeWorn       bool       `gorm:"default:true" json:"badge_worn"`
 	TotalGiftAmount int64      `gorm:"default:0" json:"total_gift_amount"`
+	LiveNotify      bool       `gorm:"default:true" json:"live_notify"`
 	FollowedAt      time.Time  `gorm:"autoCreateTime" json:"followed_at"`
 	LastGiftAt      *time.Time `json:"last_gift_at"`
+
+	User     *User `gorm:"foreignKey:UserID;-:migration" json:"user,omitempty"`
+	Streamer *User `gorm:"foreignKey:StreamerID;-:migration" json:"streamer,omitempty"`
 }
 
 type LevelConfig struct {
@@ -188,9 +205,21 @@ type LiveSchedule struct {
 	CoverURL     string     `gorm:"type:text" json:"cover_url"`
 	StartTime    time.Time  `gorm:"not null;index" json:"start_time"`
 	EndTime      *time.Time `json:"end_time"`
-	Status       string     `gorm:"type:varchar(20);default:'scheduled'" json:"status"`
+	Status       string     `gorm:"type:varchar(20);default:'scheduled';index" json:"status"`
+	RoomID       *uuid.UUID `gorm:"type:uuid" json:"room_id"`
+	Recurrence   string     `gorm:"type:varchar(200)" json:"recurrence"`
+	SeriesID     *uuid.UUID `gorm:"type:uuid;index" json:"series_id"`
 	ReminderSent bool       `gorm:"default:false" json:"reminder_sent"`
-	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	// Sequence counts edits calendar clients must apply (RFC 5545 SEQUENCE).
+	Sequence  int       `gorm:"not null;default:0" json:"sequence"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type CalendarFeedToken struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Token     string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"token"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 type RoomLike struct {
@@ -212,3 +241,150 @@ type ScheduledTask struct {
 	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// RoomModerator, RoomPenalty and ModerationLog are keyed by streamer rather
+// than LiveRoom, since a new LiveRoom row is created for every session.
+type RoomModerator struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_room_moderator" json:"streamer_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_room_moderator;index" json:"user_id"`
+	CreatedBy  uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type RoomPenalty struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID uuid.UUID  `gorm:"type:uuid;not null;index:idx_room_penalty" json:"streamer_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index:idx_room_penalty" json:"user_id"`
+	Type       string     `gorm:"type:varchar(20);not null" json:"type"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedBy  uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type ModerationLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID uuid.UUID  `gorm:"type:uuid;not null;index" json:"streamer_id"`
+	ActorID    uuid.UUID  `gorm:"type:uuid;not null" json:"actor_id"`
+	TargetID   *uuid.UUID `gorm:"type:uuid;index" json:"target_id"`
+	Action     string     `gorm:"type:varchar(30);not null" json:"action"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	Detail     string     `gorm:"type:text" json:"detail"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// DanmuRecord is a persisted danmu. OffsetMs is the time since the room went
+// live, which lines the message up with the recording for VOD replay.
+//
+// danmu_records is partitioned by month on created_at, which AutoMigrate
+// cannot create; its schema lives in repository.partitionDanmuRecords.
+type DanmuRecord struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	RoomID    uuid.UUID  `gorm:"type:uuid;not null" json:"room_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Nickname  string     `gorm:"type:varchar(50)" json:"nickname"`
+	Level     int        `json:"level"`
+	Content   string     `gorm:"type:varchar(200);not null" json:"content"`
+	Color     string     `gorm:"type:varchar(20)" json:"color"`
+	Mode      string     `gorm:"type:varchar(10);default:'scroll'" json:"mode"`
+	FontSize  int        `gorm:"default:25" json:"font_size"`
+	OffsetMs  int64      `json:"offset_ms"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	DeletedBy *uuid.UUID `gorm:"type:uuid" json:"deleted_by"`
+	CreatedAt time.Time  `gorm:"primaryKey" json:"created_at"`
+}
+
+type StreamerEmote struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_streamer_emote" json:"streamer_id"`
+	Code       string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_streamer_emote" json:"code"`
+	ImageURL   string    `gorm:"type:text;not null" json:"image_url"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type SuperChat struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RoomID      uuid.UUID `gorm:"type:uuid;not null;index:idx_super_chat_room,priority:1" json:"room_id"`
+	StreamerID  uuid.UUID `gorm:"type:uuid;not null;index" json:"streamer_id"`
+	SenderID    uuid.UUID `gorm:"type:uuid;not null;index" json:"sender_id"`
+	Tier        int       `gorm:"not null" json:"tier"`
+	CoinAmount  int       `gorm:"not null" json:"coin_amount"`
+	Content     string    `gorm:"type:varchar(200);not null" json:"content"`
+	PinnedUntil time.Time `gorm:"not null;index:idx_super_chat_room,priority:2" json:"pinned_until"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// OutboxEvent is a Centrifugo publication written in the same transaction
+// as the change it announces and delivered later by the outbox worker.
+type OutboxEvent struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Channels      string     `gorm:"type:text;not null" json:"channels"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"type:varchar(20);default:'pending';index:idx_outbox_pending,priority:1" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_pending,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// VIPSubscription is a user's paid noble tier. Each user has at most one;
+// upgrading replaces the tier and restarts the billing period.
+type VIPSubscription struct {
+	UserID     uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Tier       int        `gorm:"not null" json:"tier"`
+	Status     string     `gorm:"type:varchar(20);default:'active';index:idx_vip_due,priority:1" json:"status"`
+	AutoRenew  bool       `gorm:"not null" json:"auto_renew"`
+	StartedAt  time.Time  `gorm:"not null" json:"started_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index:idx_vip_due,priority:2" json:"expires_at"`
+	RemindedAt *time.Time `json:"reminded_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ChannelSubscription is a paid monthly subscription to one streamer's
+// channel. Months counts every paid period, for loyalty badges.
+type ChannelSubscription struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StreamerID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_channel_sub,priority:1" json:"streamer_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_channel_sub,priority:2;index" json:"user_id"`
+	Tier       int        `gorm:"not null" json:"tier"`
+	Status     string     `gorm:"type:varchar(20);default:'active';index:idx_channel_sub_due,priority:1" json:"status"`
+	AutoRenew  bool       `gorm:"not null" json:"auto_renew"`
+	Months     int        `gorm:"default:0" json:"months"`
+	StartedAt  time.Time  `gorm:"not null" json:"started_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index:idx_channel_sub_due,priority:2" json:"expires_at"`
+	RemindedAt *time.Time `json:"reminded_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// StreamerRevenue is the revenue-share ledger: one row per paid item, split
+// between the streamer and the platform.
+type StreamerRevenue struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	StreamerID    uuid.UUID `gorm:"type:uuid;not null;index:idx_streamer_revenue,priority:1" json:"streamer_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Source        string    `gorm:"type:varchar(20);not null" json:"source"`
+	SourceID      string    `gorm:"type:varchar(64)" json:"source_id"`
+	GrossAmount   int       `gorm:"not null" json:"gross_amount"`
+	StreamerShare int       `gorm:"not null" json:"streamer_share"`
+	PlatformShare int       `gorm:"not null" json:"platform_share"`
+	CreatedAt     time.Time `gorm:"autoCreateTime;index:idx_streamer_revenue,priority:2" json:"created_at"`
+}
+
+// Category classifies rooms, relays and schedules, which store the
+// category's name. Categories nest one level deep.
+type Category struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ParentID  *int      `gorm:"index" json:"parent_id"`
+	Name      string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	Slug      string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"slug"`
+	IconURL   string    `gorm:"type:text" json:"icon_url"`
+	SortOrder int       `gorm:"default:0" json:"sort_order"`
+	IsActive  bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,264 @@
+package outbox
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/centrifugo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+
+	maxAttempts  = 10
+	batchSize    = 100
+	pollInterval = time.Second
+	maxBackoff   = 5 * time.Minute
+	// claimLease is how long claimed events stay hidden from other workers.
+	claimLease = 2 * time.Minute
+	sentRetain = 7 * 24 * time.Hour
+)
+
+var (
+	published = expvar.NewInt("outbox_published")
+	failed    = expvar.NewInt("outbox_failed")
+	dead      = expvar.NewInt("outbox_dead")
+)
+
+func init() {
+	expvar.Publish("outbox_pending", expvar.Func(func() interface{} {
+		if repository.DB == nil {
+			return 0
+		}
+		var count int64
+		repository.DB.Model(&models.OutboxEvent{}).Where("status = ?", StatusPending).Count(&count)
+		return count
+	}))
+}
+
+var wake = make(chan struct{}, 1)
+
+// Publish queues data for channel inside tx. The event is only delivered if
+// tx commits; call Wake afterwards to deliver it without waiting for the
+// next poll.
+func Publish(tx *gorm.DB, channel string, data interface{}) error {
+	return Broadcast(tx, []string{channel}, data)
+}
+
+// Broadcast queues data for several channels as a single event.
+func Broadcast(tx *gorm.DB, channels []string, data interface{}) error {
+	channelsJSON, err := json.Marshal(channels)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEvent{
+		Channels:      string(channelsJSON),
+		Payload:       string(payload),
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Wake nudges the worker to deliver pending events now.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+type Worker struct {
+	client *centrifugo.Client
+}
+
+func NewWorker(client *centrifugo.Client) *Worker {
+	return &Worker{client: client}
+}
+
+func (w *Worker) Start() {
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			for w.deliverBatch() == batchSize {
+			}
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// deliverBatch claims due events and delivers them, returning how many it
+// delivered. Claiming is a short SKIP LOCKED transaction that leases the
+// events by pushing next_attempt_at out by claimLease, so several API
+// instances can run workers side by side without holding row locks while
+// Centrifugo is slow. An event whose result is never recorded, e.g. because
+// the process died, is picked up again once its lease runs out.
+func (w *Worker) deliverBatch() int {
+	events, err := claim()
+	if err != nil {
+		log.Printf("outbox: failed to claim events: %v", err)
+		return 0
+	}
+
+	start := time.Now()
+	for i := range events {
+		// Stop well inside the lease so that no other worker re-claims an
+		// event that is still being sent.
+		if time.Since(start) > claimLease/2 {
+			release(events[i:])
+			return i
+		}
+		if !w.deliver(&events[i]) {
+			// Centrifugo is probably down; hand the rest back rather than
+			// waiting out a timeout for each of them.
+			release(events[i+1:])
+			return i
+		}
+	}
+	return len(events)
+}
+
+func claim() ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+	return events, err
+}
+
+// release makes claimed but unattempted events due again.
+func release(events []models.OutboxEvent) {
+	if len(events) == 0 {
+		return
+	}
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if err := repository.DB.Model(&models.OutboxEvent{}).
+		Where("id IN ? AND status = ?", ids, StatusPending).
+		Update("next_attempt_at", time.Now()).Error; err != nil {
+		log.Printf("outbox: failed to release %d events: %v", len(ids), err)
+	}
+}
+
+// deliver sends one claimed event and records the outcome. It reports
+// whether the send succeeded.
+func (w *Worker) deliver(event *models.OutboxEvent) bool {
+	err := w.send(event)
+	if err == nil {
+		published.Add(1)
+		record(event, map[string]interface{}{
+			"status":   StatusSent,
+			"attempts": event.Attempts + 1,
+			"sent_at":  time.Now(),
+		})
+		return true
+	}
+
+	failed.Add(1)
+	attempts := event.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      err.Error(),
+		"next_attempt_at": time.Now().Add(backoff(attempts)),
+	}
+	if attempts >= maxAttempts {
+		dead.Add(1)
+		updates["status"] = StatusDead
+		log.Printf("outbox: event %d moved to dead letters after %d attempts: %v", event.ID, attempts, err)
+	}
+	record(event, updates)
+	return false
+}
+
+// record stores the outcome of an attempt. If that fails the event is sent
+// again after its lease, so delivery stays at least once.
+func record(event *models.OutboxEvent, updates map[string]interface{}) {
+	if err := repository.DB.Model(event).Where("status = ?", StatusPending).Updates(updates).Error; err != nil {
+		log.Printf("outbox: failed to record attempt for event %d: %v", event.ID, err)
+	}
+}
+
+func (w *Worker) send(event *models.OutboxEvent) error {
+	var channels []string
+	if err := json.Unmarshal([]byte(event.Channels), &channels); err != nil {
+		return err
+	}
+	payload := json.RawMessage(event.Payload)
+
+	if len(channels) == 1 {
+		return w.client.Publish(channels[0], payload)
+	}
+	return w.client.Broadcast(channels, payload)
+}
+
+func backoff(attempts int) time.Duration {
+	d := time.Second << uint(attempts-1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// Retry puts a dead event back in the queue with a fresh attempt budget.
+func Retry(id int64) (bool, error) {
+	result := repository.DB.Model(&models.OutboxEvent{}).
+		Where("id = ? AND status = ?", id, StatusDead).
+		Updates(map[string]interface{}{
+			"status":          StatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		Wake()
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Cleanup drops delivered events past their retention. It is registered as
+// a scheduled job.
+func Cleanup() (string, error) {
+	result := repository.DB.Where("status = ? AND sent_at < ?", StatusSent, time.Now().Add(-sentRetain)).
+		Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return fmt.Sprintf("removed %d delivered events", result.RowsAffected), nil
+}
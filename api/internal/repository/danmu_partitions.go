@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DanmuPartitionsAhead is how many months of danmu_records partitions are
+// kept ready after the current one.
+const DanmuPartitionsAhead = 2
+
+const danmuPartitionPrefix = "danmu_records_p"
+
+// danmuColumns lists the danmu_records columns in table order, for copying
+// rows out of the unpartitioned table.
+const danmuColumns = "id, room_id, user_id, nickname, level, content, color, mode, font_size, offset_ms, deleted_at, deleted_by, created_at"
+
+// partitionDanmuRecords (re)creates danmu_records as a table partitioned by
+// month on created_at, so retention drops whole partitions instead of
+// deleting rows. Rows of an existing unpartitioned table are copied over.
+// Postgres requires the partition key in the primary key, hence (id,
+// created_at).
+func partitionDanmuRecords(tx *gorm.DB) error {
+	var kind string
+	if err := tx.Raw("SELECT COALESCE((SELECT relkind::text FROM pg_class WHERE oid = to_regclass('danmu_records')), '')").
+		Row().Scan(&kind); err != nil {
+		return err
+	}
+	if kind == "p" {
+		return nil
+	}
+
+	legacy := kind == "r"
+	var statements []string
+	if legacy {
+		statements = append(statements,
+			"ALTER TABLE danmu_records RENAME TO danmu_records_unpartitioned",
+			"ALTER TABLE danmu_records_unpartitioned RENAME CONSTRAINT danmu_records_pkey TO danmu_records_unpartitioned_pkey",
+			"DROP INDEX IF EXISTS idx_danmu_room_time",
+			"DROP INDEX IF EXISTS idx_danmu_records_user_id",
+			"DROP INDEX IF EXISTS idx_danmu_records_offset_ms",
+		)
+	}
+	statements = append(statements, `CREATE TABLE danmu_records (
+			id uuid NOT NULL,
+			room_id uuid NOT NULL,
+			user_id uuid NOT NULL,
+			nickname varchar(50),
+			level bigint,
+			content varchar(200) NOT NULL,
+			color varchar(20),
+			mode varchar(10) DEFAULT 'scroll',
+			font_size bigint DEFAULT 25,
+			offset_ms bigint,
+			deleted_at timestamptz,
+			deleted_by uuid,
+			created_at timestamptz NOT NULL,
+			PRIMARY KEY (id, created_at)
+		) PARTITION BY RANGE (created_at)`,
+		"CREATE INDEX idx_danmu_room_time ON danmu_records (room_id, created_at, id)",
+		"CREATE INDEX idx_danmu_room_offset ON danmu_records (room_id, offset_ms, id)",
+		"CREATE INDEX idx_danmu_records_user_id ON danmu_records (user_id)",
+	)
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	from := now
+	if legacy {
+		var oldest *time.Time
+		if err := tx.Raw("SELECT MIN(created_at) FROM danmu_records_unpartitioned").Row().Scan(&oldest); err != nil {
+			return err
+		}
+		if oldest != nil && oldest.Before(from) {
+			from = *oldest
+		}
+	}
+	if err := EnsureDanmuPartitions(tx, from, now.AddDate(0, DanmuPartitionsAhead, 0)); err != nil {
+		return err
+	}
+
+	if legacy {
+		if err := tx.Exec("INSERT INTO danmu_records (" + danmuColumns + ") SELECT " + danmuColumns + " FROM danmu_records_unpartitioned").Error; err != nil {
+			return err
+		}
+		return tx.Exec("DROP TABLE danmu_records_unpartitioned").Error
+	}
+	return nil
+}
+
+// EnsureDanmuPartitions creates the monthly danmu_records partitions (UTC)
+// covering from through to. Existing partitions are left alone.
+func EnsureDanmuPartitions(db *gorm.DB, from, to time.Time) error {
+	month := monthStart(from)
+	last := monthStart(to)
+	for !month.After(last) {
+		next := month.AddDate(0, 1, 0)
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s PARTITION OF danmu_records FOR VALUES FROM ('%s') TO ('%s')",
+			danmuPartitionPrefix, month.Format("200601"),
+			month.Format(time.RFC3339), next.Format(time.RFC3339))
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+		month = next
+	}
+	return nil
+}
+
+// DropDanmuPartitionsBefore drops every danmu_records partition that only
+// holds rows older than cutoff and returns how many it dropped.
+func DropDanmuPartitionsBefore(db *gorm.DB, cutoff time.Time) (int, error) {
+	var names []string
+	if err := db.Raw(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'danmu_records'::regclass
+	`).Scan(&names).Error; err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	for _, name := range names {
+		month, err := time.Parse("200601", strings.TrimPrefix(name, danmuPartitionPrefix))
+		if err != nil || !strings.HasPrefix(name, danmuPartitionPrefix) {
+			continue
+		}
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		if err := db.Exec("DROP TABLE IF EXISTS " + name).Error; err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -6,12 +6,15 @@ import (
 	"github.com/huya_live/api/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func InitDB(cfg config.DatabaseConfig) error {
+// Connect opens the database without migrating or seeding it, for tools
+// that run against an already initialised schema.
+func Connect(cfg config.DatabaseConfig) error {
 	var err error
 	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
@@ -19,6 +22,13 @@ func InitDB(cfg config.DatabaseConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return nil
+}
+
+func InitDB(cfg config.DatabaseConfig) error {
+	if err := Connect(cfg); err != nil {
+		return err
+	}
 
 	if err := DB.AutoMigrate(
 		&models.User{},
@@ -37,16 +47,43 @@ func InitDB(cfg config.DatabaseConfig) error {
 		&models.UserReport{},
 		&models.GiftInventory{},
 		&models.LiveSchedule{},
+		&models.CalendarFeedToken{},
 		&models.RoomLike{},
 		&models.ScheduledTask{},
+		&models.RoomModerator{},
+		&models.RoomPenalty{},
+		&models.ModerationLog{},
+		&models.StreamerEmote{},
+		&models.SuperChat{},
+		&models.OutboxEvent{},
+		&models.VIPSubscription{},
+		&models.ChannelSubscription{},
+		&models.StreamerRevenue{},
+		&models.Category{},
 	); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := runMigrations(); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	if err := createRelaySearchIndex(); err != nil {
+		return fmt.Errorf("failed to create search indexes: %w", err)
+	}
+
 	if err := seedData(); err != nil {
 		return fmt.Errorf("failed to seed data: %w", err)
 	}
 
+	if err := seedSystemConfigs(); err != nil {
+		return fmt.Errorf("failed to seed system configs: %w", err)
+	}
+
+	if err := seedCategories(); err != nil {
+		return fmt.Errorf("failed to seed categories: %w", err)
+	}
+
 	if err := SeedTestData(); err != nil {
 		return fmt.Errorf("failed to seed test data: %w", err)
 	}
@@ -76,17 +113,6 @@ func seedData() error {
 		return err
 	}
 
-	configs := []models.SystemConfig{
-		{Key: "danmu_rate_limit", Value: "20", Description: "每分钟弹幕数限制"},
-		{Key: "gift_rate_limit", Value: "30", Description: "每分钟礼物数限制"},
-		{Key: "coin_recharge_min", Value: "10", Description: "最小充值金额"},
-		{Key: "stream_key_expire_days", Value: "30", Description: "推流密钥有效期（天）"},
-	}
-
-	if err := DB.Create(&configs).Error; err != nil {
-		return err
-	}
-
 	sensitiveWords := []models.SensitiveWord{
 		{Word: "赌博", Type: "blacklist", Severity: "high"},
 		{Word: "色情", Type: "blacklist", Severity: "high"},
@@ -116,6 +142,68 @@ func seedData() error {
 	return nil
 }
 
+// seedSystemConfigs inserts config keys that are missing, so that keys added
+// after a database was first seeded still show up in the admin settings
+// instead of silently falling back to code defaults. Existing values are
+// left alone.
+func seedSystemConfigs() error {
+	configs := []models.SystemConfig{
+		{Key: "danmu_rate_limit", Value: "20", Description: "每分钟弹幕数限制"},
+		{Key: "danmu_rate_limit_new_user", Value: "6", Description: "新用户/低等级用户每分钟弹幕数限制"},
+		{Key: "danmu_duplicate_window", Value: "10", Description: "重复弹幕拦截窗口（秒）"},
+		{Key: "danmu_retention_days", Value: "90", Description: "弹幕历史保留天数"},
+		{Key: "danmu_recall_window", Value: "120", Description: "弹幕撤回时限（秒）"},
+		{Key: "danmu_special_color_level", Value: "10", Description: "自定义弹幕颜色所需等级"},
+		{Key: "gift_rate_limit", Value: "30", Description: "每分钟礼物数限制"},
+		{Key: "channel_sub_streamer_share", Value: "50", Description: "频道订阅收入主播分成比例（%）"},
+		{Key: "gift_banner_threshold", Value: "10000", Description: "触发全站礼物横幅的金币数（0为关闭）"},
+		{Key: "coin_recharge_min", Value: "10", Description: "最小充值金额"},
+		{Key: "stream_key_expire_days", Value: "30", Description: "推流密钥有效期（天）"},
+	}
+
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&configs).Error
+}
+
+// seedCategories runs separately from seedData so that existing databases
+// get the categories that used to be hard-coded.
+func seedCategories() error {
+	var count int64
+	DB.Model(&models.Category{}).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	names := []struct{ name, slug string }{
+		{"娱乐", "entertainment"},
+		{"游戏", "games"},
+		{"美食", "food"},
+		{"音乐", "music"},
+		{"舞蹈", "dance"},
+		{"户外", "outdoor"},
+		{"科技", "tech"},
+		{"体育", "sports"},
+		{"汽车", "cars"},
+		{"时尚", "fashion"},
+		{"教育", "education"},
+		{"财经", "finance"},
+		{"新闻", "news"},
+		{"综合", "general"},
+		{"测试", "test"},
+	}
+
+	categories := make([]models.Category, 0, len(names))
+	for i, n := range names {
+		categories = append(categories, models.Category{
+			Name:      n.name,
+			Slug:      n.slug,
+			IconURL:   "/categories/" + n.slug + ".png",
+			SortOrder: i + 1,
+			IsActive:  true,
+		})
+	}
+	return DB.Create(&categories).Error
+}
+
 func ptrInt64(v int64) *int64 {
 	return &v
 }
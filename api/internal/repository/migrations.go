@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is a schema change that AutoMigrate cannot express. Each one
+// runs once, inside a transaction, and is recorded in schema_migrations.
+type migration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// migrations run in order after AutoMigrate. Append only; never rename or
+// reorder an entry that has shipped.
+var migrations = []migration{
+	{"20261019_partition_danmu_records", partitionDanmuRecords},
+	{"20261019_search_indexes", createSearchIndexes},
+	{"20261019_backfill_nickname_pinyin", backfillNicknamePinyin},
+}
+
+type schemaMigration struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+func runMigrations() error {
+	if err := DB.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	var applied []string
+	if err := DB.Model(&schemaMigration{}).Pluck("name", &applied).Error; err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		done[name] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.name] {
+			continue
+		}
+		log.Printf("running migration %s", m.name)
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Name: m.name}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
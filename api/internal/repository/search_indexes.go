@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/pkg/pinyin"
+	"gorm.io/gorm"
+)
+
+// createSearchIndexes adds trigram indexes so that substring and fuzzy
+// matching on titles and names can use an index. Trigrams also work for
+// Chinese text, which the built-in full-text parsers do not segment.
+func createSearchIndexes(tx *gorm.DB) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_live_rooms_title_trgm ON live_rooms USING gin (title gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_users_nickname_trgm ON users USING gin (nickname gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_users_nickname_pinyin ON users (nickname_pinyin varchar_pattern_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_live_schedules_title_trgm ON live_schedules USING gin (title gin_trgm_ops)",
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRelaySearchIndex adds the trigram index on relay names. It runs on
+// every startup rather than as a migration because relay_streams is owned by
+// the relay service and may only appear after this service has migrated.
+func createRelaySearchIndex() error {
+	if !DB.Migrator().HasTable("relay_streams") {
+		return nil
+	}
+	var exists bool
+	if err := DB.Raw("SELECT to_regclass('idx_relay_streams_name_trgm') IS NOT NULL").Row().Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return DB.Exec("CREATE INDEX IF NOT EXISTS idx_relay_streams_name_trgm ON relay_streams USING gin (name gin_trgm_ops)").Error
+}
+
+// backfillNicknamePinyin fills NicknamePinyin for users created before it
+// existed.
+func backfillNicknamePinyin(tx *gorm.DB) error {
+	var users []models.User
+	if err := tx.Select("id", "nickname").
+		Where("nickname <> '' AND (nickname_pinyin IS NULL OR nickname_pinyin = '')").
+		Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		initials := pinyin.Initials(u.Nickname)
+		if initials == "" {
+			continue
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", u.ID).Update("nickname_pinyin", initials).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/pkg/pinyin"
 )
 
 func SeedTestData() error {
@@ -68,6 +69,7 @@ func SeedTestData() error {
 	}
 
 	for i := range users {
+		users[i].NicknamePinyin = pinyin.Initials(users[i].Nickname)
 		users[i].CreatedAt = time.Now()
 		users[i].UpdatedAt = time.Now()
 	}
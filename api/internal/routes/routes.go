@@ -2,13 +2,16 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/huya_live/api/internal/config"
 	"github.com/huya_live/api/internal/handlers"
 	"github.com/huya_live/api/internal/middleware"
 	"github.com/huya_live/api/pkg/centrifugo"
 	"github.com/huya_live/api/pkg/jwt"
 )
 
-func SetupRouter() *gin.Engine {
+// SetupRouter wires every handler to centrifugoClient, which cmd/main.go
+// shares with the outbox worker and the scheduled jobs.
+func SetupRouter(cfg *config.Config, centrifugoClient *centrifugo.Client) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
 	r.Use(func(c *gin.Context) {
@@ -21,33 +24,50 @@ func SetupRouter() *gin.Engine {
 		604800,
 	)
 
-	centrifugoClient := centrifugo.NewClient("http://localhost:8000", "your_centrifugo_api_key")
-
 	healthHandler := handlers.NewHealthHandler()
 	authHandler := handlers.NewAuthHandler(jwtManager)
-	liveHandler := handlers.NewLiveHandler()
+	liveNotifier := handlers.NewLiveNotifier(centrifugoClient)
+
+	liveHandler := handlers.NewLiveHandler(liveNotifier, centrifugoClient)
 	streamerHandler := handlers.NewStreamerHandler()
-	srsHandler := handlers.NewSRSHandler()
-	centrifugoHandler := handlers.NewCentrifugoHandler()
-	danmuHandler := handlers.NewDanmuHandler()
-	giftHandler := handlers.NewGiftHandler()
+	srsHandler := handlers.NewSRSHandler(liveNotifier)
+	centrifugoHandler := handlers.NewCentrifugoHandler(centrifugoClient, cfg.Centrifugo.WSURL, cfg.Centrifugo.TokenTTL)
+	danmuHandler := handlers.NewDanmuHandler(centrifugoClient)
+	giftHandler := handlers.NewGiftHandler(centrifugoClient)
 	walletHandler := handlers.NewWalletHandler()
 	socialHandler := handlers.NewSocialHandler()
 	relayHandler := handlers.NewRelayHandler()
 	tvHandler := handlers.NewPredefinedTVHandler()
-	leaderboardHandler := handlers.NewLeaderboardHandler()
+	leaderboardHandler := handlers.NewLeaderboardHandler(centrifugoClient)
 	notificationHandler := handlers.NewNotificationHandler()
 	messageHandler := handlers.NewMessageHandler(centrifugoClient)
 	historyHandler := handlers.NewHistoryHandler()
 	reportHandler := handlers.NewReportHandler()
 	giftInventoryHandler := handlers.NewGiftInventoryHandler()
 	likeHandler := handlers.NewLikeHandler()
-	scheduleHandler := handlers.NewScheduleHandler()
+	scheduleHandler := handlers.NewScheduleHandler(cfg.Server.WebURL)
 	passwordHandler := handlers.NewPasswordHandler()
-	adminHandler := handlers.NewAdminHandler()
+	adminHandler := handlers.NewAdminHandler(centrifugoClient)
+	moderationHandler := handlers.NewModerationHandler(centrifugoClient)
+	superChatHandler := handlers.NewSuperChatHandler(centrifugoClient)
+	vipHandler := handlers.NewVIPHandler()
+	channelSubHandler := handlers.NewChannelSubHandler()
+	categoryHandler := handlers.NewCategoryHandler()
+	searchHandler := handlers.NewSearchHandler()
+	feedHandler := handlers.NewFeedHandler(centrifugoClient)
+	centrifugoProxyHandler := handlers.NewCentrifugoProxyHandler(jwtManager, danmuHandler, cfg.Centrifugo.ProxySecret)
 
 	r.GET("/health", healthHandler.HealthCheck)
 
+	centrifugoProxy := r.Group("/api/centrifugo")
+	centrifugoProxy.Use(centrifugoProxyHandler.VerifySecret)
+	{
+		centrifugoProxy.POST("/connect", centrifugoProxyHandler.Connect)
+		centrifugoProxy.POST("/subscribe", centrifugoProxyHandler.Subscribe)
+		centrifugoProxy.POST("/publish", centrifugoProxyHandler.Publish)
+		centrifugoProxy.POST("/rpc", centrifugoProxyHandler.RPC)
+	}
+
 	api := r.Group("/api/v1")
 	{
 		auth := api.Group("/auth")
@@ -61,6 +81,7 @@ func SetupRouter() *gin.Engine {
 		{
 			users.GET("/profile", middleware.JWTRequired(jwtManager), authHandler.GetProfile)
 			users.PUT("/profile", middleware.JWTRequired(jwtManager), authHandler.UpdateProfile)
+			users.GET("/subscriptions", middleware.JWTRequired(jwtManager), channelSubHandler.ListMySubscriptions)
 		}
 
 		live := api.Group("/live")
@@ -75,6 +96,22 @@ func SetupRouter() *gin.Engine {
 			streamers.POST("/apply", middleware.JWTRequired(jwtManager), streamerHandler.Apply)
 			streamers.GET("/me", middleware.JWTRequired(jwtManager), streamerHandler.GetInfo)
 			streamers.POST("/refresh-key", middleware.JWTRequired(jwtManager), streamerHandler.RefreshStreamKey)
+			streamers.GET("/:id/schedule.ics", scheduleHandler.GetStreamerCalendar)
+			streamers.GET("/:id/emotes", danmuHandler.ListEmotes)
+			streamers.GET("/subscription-tiers", channelSubHandler.GetTiers)
+			streamers.POST("/:id/subscribe", middleware.JWTRequired(jwtManager), channelSubHandler.Subscribe)
+			streamers.PUT("/:id/subscription/auto-renew", middleware.JWTRequired(jwtManager), channelSubHandler.SetAutoRenew)
+			streamers.GET("/me/subscribers", middleware.JWTRequired(jwtManager), channelSubHandler.ListSubscribers)
+			streamers.GET("/me/revenue", middleware.JWTRequired(jwtManager), channelSubHandler.ListRevenue)
+		}
+
+		api.GET("/rooms/:id/danmu", danmuHandler.GetDanmuHistory)
+		api.GET("/rooms/:id/superchats", superChatHandler.GetPinnedSuperChats)
+
+		superChat := api.Group("/superchat")
+		{
+			superChat.GET("/tiers", superChatHandler.GetTiers)
+			superChat.POST("/send", middleware.JWTRequired(jwtManager), superChatHandler.SendSuperChat)
 		}
 
 		rooms := api.Group("/rooms")
@@ -83,25 +120,50 @@ func SetupRouter() *gin.Engine {
 			rooms.POST("", liveHandler.CreateRoom)
 			rooms.PUT("/:id", liveHandler.UpdateRoom)
 			rooms.POST("/:id/end", liveHandler.EndRoom)
+			rooms.PUT("/:id/chat-settings", danmuHandler.UpdateChatSettings)
+			rooms.GET("/:id/moderators", moderationHandler.ListModerators)
+			rooms.POST("/:id/moderators", moderationHandler.AddModerator)
+			rooms.DELETE("/:id/moderators/:user_id", moderationHandler.RemoveModerator)
+			rooms.GET("/:id/penalties", moderationHandler.ListPenalties)
+			rooms.GET("/:id/viewers", moderationHandler.ListViewers)
+			rooms.POST("/:id/mutes", moderationHandler.Mute)
+			rooms.DELETE("/:id/mutes/:user_id", moderationHandler.Unmute)
+			rooms.POST("/:id/bans", moderationHandler.Ban)
+			rooms.DELETE("/:id/bans/:user_id", moderationHandler.Unban)
+			rooms.POST("/:id/kick", moderationHandler.Kick)
+			rooms.GET("/:id/moderation-logs", moderationHandler.ListModerationLogs)
 		}
 
 		centrifugo := api.Group("/centrifugo")
 		{
 			centrifugo.GET("/token", middleware.JWTRequired(jwtManager), centrifugoHandler.GetToken)
+			centrifugo.GET("/subscription-token", middleware.JWTRequired(jwtManager), centrifugoHandler.GetSubscriptionToken)
 		}
 
 		danmu := api.Group("/danmu")
 		danmu.Use(middleware.JWTRequired(jwtManager))
 		{
 			danmu.POST("/send", danmuHandler.SendDanmu)
+			danmu.DELETE("/:id", danmuHandler.DeleteDanmu)
+			danmu.POST("/emotes", danmuHandler.CreateEmote)
+			danmu.DELETE("/emotes/:id", danmuHandler.DeleteEmote)
 		}
 
 		gifts := api.Group("/gifts")
 		gifts.Use(middleware.JWTRequired(jwtManager))
+		gifts.Use(middleware.RateLimit("gift", func() int { return handlers.SystemConfigInt("gift_rate_limit", 30) }))
 		{
 			gifts.POST("/send", giftHandler.SendGift)
 		}
 
+		vip := api.Group("/vip")
+		{
+			vip.GET("/tiers", vipHandler.GetTiers)
+			vip.GET("/me", middleware.JWTRequired(jwtManager), vipHandler.GetMySubscription)
+			vip.POST("/subscribe", middleware.JWTRequired(jwtManager), vipHandler.Subscribe)
+			vip.PUT("/auto-renew", middleware.JWTRequired(jwtManager), vipHandler.SetAutoRenew)
+		}
+
 		wallet := api.Group("/wallet")
 		wallet.Use(middleware.JWTRequired(jwtManager))
 		{
@@ -116,6 +178,7 @@ func SetupRouter() *gin.Engine {
 			social.POST("/unfollow", middleware.JWTRequired(jwtManager), socialHandler.Unfollow)
 			social.GET("/followings", middleware.JWTRequired(jwtManager), socialHandler.GetFollowings)
 			social.GET("/followers/:streamer_id", socialHandler.GetFollowers)
+			social.PUT("/notify-setting", middleware.JWTRequired(jwtManager), socialHandler.UpdateNotifySetting)
 		}
 
 		relay := api.Group("/relay")
@@ -140,12 +203,20 @@ func SetupRouter() *gin.Engine {
 		{
 			leaderboard.GET("/rooms/:room_id", leaderboardHandler.GetRoomLeaderboard)
 			leaderboard.GET("/global", leaderboardHandler.GetGlobalLeaderboard)
+			leaderboard.GET("/gifters", leaderboardHandler.GetGifterLeaderboard)
+			leaderboard.GET("/me", middleware.JWTRequired(jwtManager), leaderboardHandler.GetMyRank)
+			leaderboard.GET("/noble/tiers", leaderboardHandler.GetNobleTiers)
+			leaderboard.GET("/noble/me", middleware.JWTRequired(jwtManager), leaderboardHandler.GetMyNoble)
 			leaderboard.GET("/rich", leaderboardHandler.GetRichList)
 		}
 
+		api.GET("/categories", categoryHandler.ListCategories)
+		api.GET("/search", searchHandler.Search)
+		api.GET("/feed", middleware.JWTRequired(jwtManager), feedHandler.GetFeed)
+
 		extra := api.Group("/extra")
 		{
-			extra.GET("/categories", leaderboardHandler.GetCategories)
+			extra.GET("/categories", categoryHandler.ListCategories)
 			extra.GET("/online-count", leaderboardHandler.GetOnlineCount)
 		}
 
@@ -215,6 +286,13 @@ func SetupRouter() *gin.Engine {
 			schedules.PUT("/:id", scheduleHandler.UpdateSchedule)
 			schedules.POST("/:id/cancel", scheduleHandler.CancelSchedule)
 			schedules.DELETE("/:id", scheduleHandler.DeleteSchedule)
+			schedules.GET("/feed", scheduleHandler.GetCalendarFeedToken)
+			schedules.POST("/feed/rotate", scheduleHandler.RotateCalendarFeedToken)
+		}
+
+		calendar := api.Group("/calendar")
+		{
+			calendar.GET("/:token", scheduleHandler.GetPersonalCalendar)
 		}
 
 		extraSchedules := api.Group("/extra/schedules")
@@ -244,9 +322,18 @@ func SetupRouter() *gin.Engine {
 			admin.DELETE("/gifts/:id", adminHandler.DeleteGift)
 			admin.GET("/sensitive-words", adminHandler.GetSensitiveWords)
 			admin.POST("/sensitive-words", adminHandler.AddSensitiveWord)
+			admin.PUT("/sensitive-words/:id", adminHandler.UpdateSensitiveWord)
 			admin.DELETE("/sensitive-words/:id", adminHandler.DeleteSensitiveWord)
 			admin.GET("/config", adminHandler.GetSystemConfig)
 			admin.PUT("/config", adminHandler.UpdateSystemConfig)
+			admin.POST("/announcements", adminHandler.CreateAnnouncement)
+			admin.GET("/metrics", adminHandler.GetMetrics)
+			admin.GET("/outbox/dead", adminHandler.GetDeadOutboxEvents)
+			admin.POST("/outbox/:id/retry", adminHandler.RetryOutboxEvent)
+			admin.GET("/categories", categoryHandler.AdminListCategories)
+			admin.POST("/categories", categoryHandler.CreateCategory)
+			admin.PUT("/categories/:id", categoryHandler.UpdateCategory)
+			admin.DELETE("/categories/:id", categoryHandler.DeleteCategory)
 		}
 	}
 
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/huya_live/api/internal/models"
+	"github.com/huya_live/api/internal/repository"
+	"github.com/huya_live/api/pkg/redis"
+	"gorm.io/gorm/clause"
+)
+
+type JobFunc func() (string, error)
+
+type job struct {
+	name     string
+	taskType string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler runs periodic background jobs. Every run is recorded in the
+// scheduled_tasks table, and jobs disabled there are skipped. A short Redis
+// lock keeps multiple API instances from running the same job concurrently.
+type Scheduler struct {
+	jobs []job
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+func (s *Scheduler) Register(name, taskType string, interval time.Duration, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, taskType: taskType, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		task := models.ScheduledTask{
+			Name:      j.name,
+			Type:      j.taskType,
+			CronExpr:  "@every " + j.interval.String(),
+			IsEnabled: true,
+		}
+		repository.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"type", "cron_expr"}),
+		}).Create(&task)
+
+		go s.loop(j)
+	}
+}
+
+func (s *Scheduler) loop(j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.runOnce(j)
+	}
+}
+
+func (s *Scheduler) runOnce(j job) {
+	var task models.ScheduledTask
+	if err := repository.DB.Where("name = ?", j.name).First(&task).Error; err == nil && !task.IsEnabled {
+		return
+	}
+
+	lockTTL := j.interval - time.Second
+	if lockTTL < time.Second {
+		lockTTL = time.Second
+	}
+	ok, err := redis.SetNX(context.Background(), "scheduler_lock:"+j.name, time.Now().Unix(), lockTTL)
+	if err == nil && !ok {
+		return
+	}
+
+	startedAt := time.Now()
+	result, runErr := safeRun(j.run)
+	if runErr != nil {
+		result = "error: " + runErr.Error()
+		log.Printf("scheduled task %s failed: %v", j.name, runErr)
+	}
+
+	nextRunAt := startedAt.Add(j.interval)
+	repository.DB.Model(&models.ScheduledTask{}).Where("name = ?", j.name).Updates(map[string]interface{}{
+		"last_run_at": startedAt,
+		"next_run_at": nextRunAt,
+		"last_result": result,
+	})
+}
+
+func safeRun(run JobFunc) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run()
+}
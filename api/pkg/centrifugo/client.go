@@ -12,6 +12,12 @@ import (
 	"time"
 )
 
+// GlobalChannel carries site-wide announcements and gift banners. Every
+// client may subscribe; only the server publishes.
+const GlobalChannel = "global"
+
+const maxBroadcastChannels = 1000
+
 type Config struct {
 	URL     string
 	APIKey  string
@@ -19,14 +25,33 @@ type Config struct {
 	Timeout time.Duration
 }
 
-type PublishRequest struct {
-	Method string      `json:"method"`
-	Params interface{} `json:"params"`
+// APIResponse is the reply of a server API call: a result on success, an
+// error object otherwise.
+type APIResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *APIError       `json:"error"`
+}
+
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type ClientInfo struct {
+	User     string          `json:"user"`
+	Client   string          `json:"client"`
+	ConnInfo json.RawMessage `json:"conn_info,omitempty"`
+	ChanInfo json.RawMessage `json:"chan_info,omitempty"`
 }
 
-type PublishResponse struct {
-	Result interface{} `json:"result"`
-	Error  interface{} `json:"error"`
+type PresenceStats struct {
+	NumClients int `json:"num_clients"`
+	NumUsers   int `json:"num_users"`
+}
+
+type Publication struct {
+	Data   json.RawMessage `json:"data"`
+	Offset uint64          `json:"offset"`
 }
 
 type Client struct {
@@ -34,44 +59,190 @@ type Client struct {
 	client *http.Client
 }
 
-func NewClient(url, apiKey string) *Client {
-	if apiKey == "" {
-		apiKey = "your_centrifugo_api_key"
+func NewClient(config Config) *Client {
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
 	}
 	return &Client{
-		config: Config{
-			URL:     url,
-			APIKey:  apiKey,
-			Timeout: 5 * time.Second,
-		},
+		config: config,
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout: config.Timeout,
 		},
 	}
 }
 
 func (c *Client) Publish(channel string, data interface{}) error {
-	reqBody := PublishRequest{
-		Method: "publish",
-		Params: map[string]interface{}{
-			"channel": channel,
-			"data":    data,
-		},
+	_, err := c.call("publish", map[string]interface{}{
+		"channel": channel,
+		"data":    data,
+	})
+	return err
+}
+
+// Broadcast publishes the same data to many channels. Large channel lists
+// are split into batches of maxBroadcastChannels, one request each.
+func (c *Client) Broadcast(channels []string, data interface{}) error {
+	for len(channels) > 0 {
+		n := len(channels)
+		if n > maxBroadcastChannels {
+			n = maxBroadcastChannels
+		}
+		if _, err := c.call("broadcast", map[string]interface{}{
+			"channels": channels[:n],
+			"data":     data,
+		}); err != nil {
+			return err
+		}
+		channels = channels[n:]
+	}
+	return nil
+}
+
+// Message is one publication of a PublishBatch call.
+type Message struct {
+	Channel string
+	Data    interface{}
+}
+
+// PublishBatch publishes different data to different channels. Messages are
+// sent as batch commands, maxBroadcastChannels per request; the first
+// failed reply is returned.
+func (c *Client) PublishBatch(messages []Message) error {
+	for len(messages) > 0 {
+		n := len(messages)
+		if n > maxBroadcastChannels {
+			n = maxBroadcastChannels
+		}
+		commands := make([]map[string]interface{}, 0, n)
+		for _, m := range messages[:n] {
+			commands = append(commands, map[string]interface{}{
+				"publish": map[string]interface{}{
+					"channel": m.Channel,
+					"data":    m.Data,
+				},
+			})
+		}
+
+		var result struct {
+			Replies []struct {
+				Error *APIError `json:"error"`
+			} `json:"replies"`
+		}
+		if err := c.post("batch", map[string]interface{}{"commands": commands}, &result); err != nil {
+			return err
+		}
+		for _, reply := range result.Replies {
+			if reply.Error != nil {
+				return fmt.Errorf("centrifugo error %d: %s", reply.Error.Code, reply.Error.Message)
+			}
+		}
+		messages = messages[n:]
+	}
+	return nil
+}
+
+// Unsubscribe removes a user's subscription to channel on every connection.
+func (c *Client) Unsubscribe(channel, userID string) error {
+	_, err := c.call("unsubscribe", map[string]interface{}{
+		"channel": channel,
+		"user":    userID,
+	})
+	return err
+}
+
+// Disconnect closes all connections of a user.
+func (c *Client) Disconnect(userID string) error {
+	_, err := c.call("disconnect", map[string]interface{}{
+		"user": userID,
+	})
+	return err
+}
+
+// Presence returns the clients currently subscribed to channel, keyed by
+// client ID.
+func (c *Client) Presence(channel string) (map[string]ClientInfo, error) {
+	raw, err := c.call("presence", map[string]interface{}{
+		"channel": channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Presence map[string]ClientInfo `json:"presence"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode presence: %w", err)
+	}
+	return result.Presence, nil
+}
+
+func (c *Client) PresenceStats(channel string) (PresenceStats, error) {
+	var stats PresenceStats
+	raw, err := c.call("presence_stats", map[string]interface{}{
+		"channel": channel,
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return stats, fmt.Errorf("failed to decode presence stats: %w", err)
+	}
+	return stats, nil
+}
+
+// History returns up to limit of the channel's most recent publications,
+// oldest first.
+func (c *Client) History(channel string, limit int) ([]Publication, error) {
+	raw, err := c.call("history", map[string]interface{}{
+		"channel": channel,
+		"limit":   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Publications []Publication `json:"publications"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode history: %w", err)
+	}
+	return result.Publications, nil
+}
+
+// call invokes a server API method through its own endpoint, POST
+// /api/<method> with the params as the body, which Centrifugo supports since
+// v5. The older single /api endpoint with the method in the body is legacy.
+func (c *Client) call(method string, params interface{}) (json.RawMessage, error) {
+	var result APIResponse
+	if err := c.post(method, params, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("centrifugo error %d: %s", result.Error.Code, result.Error.Message)
 	}
 
-	body, err := json.Marshal(reqBody)
+	return result.Result, nil
+}
+
+// post sends params to /api/<method> and decodes the reply into out.
+func (c *Client) post(method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.config.URL+"/api", bytes.NewBuffer(body))
+	req, err := http.NewRequest("POST", c.config.URL+"/api/"+method, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.config.APIKey != "" && c.config.APIKey != "your_centrifugo_api_key" {
-		req.Header.Set("Authorization", "apikey "+c.config.APIKey)
+	if c.config.APIKey != "" {
+		req.Header.Set("X-API-Key", c.config.APIKey)
 	}
 
 	resp, err := c.client.Do(req)
@@ -85,30 +256,43 @@ func (c *Client) Publish(channel string, data interface{}) error {
 		return fmt.Errorf("centrifugo returned status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	var result PublishResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
+	return nil
+}
 
-	if result.Error != nil {
-		return fmt.Errorf("centrifugo error: %v", result.Error)
+// GenerateConnectionToken returns an HS256 connection JWT. info is attached
+// to the client's presence and publications and may be nil.
+func (c *Client) GenerateConnectionToken(userID string, expireAt int64, info interface{}) string {
+	claims := map[string]interface{}{
+		"sub": userID,
+		"exp": expireAt,
+	}
+	if info != nil {
+		claims["info"] = info
 	}
+	return c.signToken(claims)
+}
 
-	return nil
+// GenerateSubscriptionToken returns an HS256 token that lets userID
+// subscribe to a protected channel.
+func (c *Client) GenerateSubscriptionToken(userID, channel string, expireAt int64) string {
+	return c.signToken(map[string]interface{}{
+		"sub":     userID,
+		"channel": channel,
+		"exp":     expireAt,
+	})
 }
 
-func (c *Client) GenerateConnectionToken(userID string, expireAt int64) string {
+func (c *Client) signToken(claims map[string]interface{}) string {
 	header := map[string]interface{}{
 		"alg": "HS256",
 		"typ": "JWT",
 	}
-	payload := map[string]interface{}{
-		"sub": userID,
-		"exp": expireAt,
-	}
 
 	headerJSON, _ := json.Marshal(header)
-	payloadJSON, _ := json.Marshal(payload)
+	payloadJSON, _ := json.Marshal(claims)
 
 	headerB64 := base64URLEncode(headerJSON)
 	payloadB64 := base64URLEncode(payloadJSON)
@@ -128,10 +312,22 @@ func base64URLEncode(data []byte) string {
 	return base64.RawURLEncoding.EncodeToString(data)
 }
 
+// UserChannel is the personal channel for notifications and private
+// messages. Only its owner may subscribe.
+func UserChannel(userID string) string {
+	return "user:" + userID
+}
+
+// StreamerChannel is a room's private channel for its streamer and
+// moderators. Subscribing needs a subscription token.
+func StreamerChannel(roomID string) string {
+	return "streamer:" + roomID
+}
+
 func GetChannels(roomID string) []string {
 	return []string{
 		fmt.Sprintf("room_%s", roomID),
-		fmt.Sprintf("room_%s:streamer", roomID),
+		StreamerChannel(roomID),
 	}
 }
 
@@ -139,17 +335,33 @@ type DanmuMessage struct {
 	Type      string `json:"type"`
 	Timestamp int64  `json:"timestamp"`
 	Data      struct {
-		ID       string   `json:"id"`
-		UserID   string   `json:"user_id"`
-		Nickname string   `json:"nickname"`
-		Level    int      `json:"level"`
-		Avatar   string   `json:"avatar"`
-		Content  string   `json:"content"`
-		Color    string   `json:"color"`
-		Badges   []string `json:"badges"`
+		ID        string         `json:"id"`
+		UserID    string         `json:"user_id"`
+		Nickname  string         `json:"nickname"`
+		Level     int            `json:"level"`
+		Avatar    string         `json:"avatar"`
+		Content   string         `json:"content"`
+		Color     string         `json:"color"`
+		Mode      string         `json:"mode"`
+		FontSize  int            `json:"font_size"`
+		Badges    []string       `json:"badges"`
+		SubTier   int            `json:"sub_tier,omitempty"`
+		SubMonths int            `json:"sub_months,omitempty"`
+		Emotes    []DanmuEmote   `json:"emotes,omitempty"`
+		Mentions  []DanmuMention `json:"mentions,omitempty"`
 	} `json:"data"`
 }
 
+type DanmuEmote struct {
+	Code string `json:"code"`
+	URL  string `json:"url"`
+}
+
+type DanmuMention struct {
+	UserID   string `json:"user_id"`
+	Nickname string `json:"nickname"`
+}
+
 type GiftMessage struct {
 	Type      string `json:"type"`
 	Timestamp int64  `json:"timestamp"`
@@ -171,6 +383,41 @@ type GiftMessage struct {
 	} `json:"data"`
 }
 
+// BannerMessage announces an expensive gift on the global channel and in
+// every live room.
+type BannerMessage struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Data      struct {
+		RoomID       string `json:"room_id"`
+		SenderName   string `json:"sender_name"`
+		StreamerName string `json:"streamer_name"`
+		GiftName     string `json:"gift_name"`
+		GiftIcon     string `json:"gift_icon"`
+		Count        int    `json:"count"`
+		TotalValue   int    `json:"total_value"`
+	} `json:"data"`
+}
+
+type SuperChatMessage struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Data      struct {
+		ID     string `json:"id"`
+		Sender struct {
+			ID       string `json:"id"`
+			Nickname string `json:"nickname"`
+			Level    int    `json:"level"`
+			Avatar   string `json:"avatar"`
+		} `json:"sender"`
+		Tier        int    `json:"tier"`
+		Amount      int    `json:"amount"`
+		Content     string `json:"content"`
+		Color       string `json:"color"`
+		PinnedUntil int64  `json:"pinned_until"`
+	} `json:"data"`
+}
+
 type OnlineCountMessage struct {
 	Type      string `json:"type"`
 	Timestamp int64  `json:"timestamp"`
@@ -0,0 +1,233 @@
+package filter
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Severity int
+
+const (
+	SeverityLow Severity = iota + 1
+	SeverityMedium
+	SeverityHigh
+)
+
+func ParseSeverity(s string) Severity {
+	switch strings.ToLower(s) {
+	case "low":
+		return SeverityLow
+	case "high":
+		return SeverityHigh
+	default:
+		return SeverityMedium
+	}
+}
+
+// Word is a filter entry. Mask words are always replaced with '*'; other
+// words block the text when their severity reaches the caller's threshold
+// and are masked otherwise.
+type Word struct {
+	Text     string
+	Mask     bool
+	Severity Severity
+}
+
+type Match struct {
+	Word  Word
+	Start int // rune offset into the original text
+	End   int // exclusive
+}
+
+type Result struct {
+	Matches  []Match
+	Blocked  bool
+	Severity Severity
+	Text     string // original text with maskable matches replaced by '*'
+}
+
+type node struct {
+	children map[rune]int
+	fail     int
+	outputs  []int
+}
+
+type automaton struct {
+	nodes   []node
+	words   []Word
+	lengths []int
+}
+
+// Filter is an Aho-Corasick matcher over normalized text. Normalization folds
+// case, full-width forms and traditional Chinese, and ignores separators so
+// that "賭 博" or "ＤＵ-ＢＯ" still match their plain forms.
+type Filter struct {
+	mu sync.RWMutex
+	ac *automaton
+}
+
+func New() *Filter {
+	return &Filter{ac: build(nil)}
+}
+
+// Build replaces the word list. It is safe to call while Check is running.
+func (f *Filter) Build(words []Word) {
+	ac := build(words)
+	f.mu.Lock()
+	f.ac = ac
+	f.mu.Unlock()
+}
+
+func (f *Filter) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.ac.words)
+}
+
+func (f *Filter) Check(text string, blockAt Severity) Result {
+	f.mu.RLock()
+	ac := f.ac
+	f.mu.RUnlock()
+
+	original := []rune(text)
+	normalized := make([]rune, 0, len(original))
+	positions := make([]int, 0, len(original))
+	for i, r := range original {
+		n, ok := normalize(r)
+		if !ok {
+			continue
+		}
+		normalized = append(normalized, n)
+		positions = append(positions, i)
+	}
+
+	result := Result{Text: text}
+	state := 0
+	for i, r := range normalized {
+		state = ac.step(state, r)
+		for _, wi := range ac.nodes[state].outputs {
+			start := positions[i-ac.lengths[wi]+1]
+			end := positions[i] + 1
+			result.Matches = append(result.Matches, Match{Word: ac.words[wi], Start: start, End: end})
+		}
+	}
+
+	if len(result.Matches) == 0 {
+		return result
+	}
+
+	masked := make([]rune, len(original))
+	copy(masked, original)
+	for _, m := range result.Matches {
+		if m.Word.Severity > result.Severity {
+			result.Severity = m.Word.Severity
+		}
+		if !m.Word.Mask && m.Word.Severity >= blockAt {
+			result.Blocked = true
+		}
+		for i := m.Start; i < m.End; i++ {
+			if _, ok := normalize(original[i]); ok {
+				masked[i] = '*'
+			}
+		}
+	}
+	result.Text = string(masked)
+
+	return result
+}
+
+func (ac *automaton) step(state int, r rune) int {
+	for {
+		if next, ok := ac.nodes[state].children[r]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = ac.nodes[state].fail
+	}
+}
+
+func build(words []Word) *automaton {
+	ac := &automaton{nodes: []node{{children: map[rune]int{}}}}
+
+	for _, w := range words {
+		key := normalizeWord(w.Text)
+		if key == "" {
+			continue
+		}
+		wi := len(ac.words)
+		ac.words = append(ac.words, w)
+		ac.lengths = append(ac.lengths, len([]rune(key)))
+
+		state := 0
+		for _, r := range key {
+			next, ok := ac.nodes[state].children[r]
+			if !ok {
+				next = len(ac.nodes)
+				ac.nodes = append(ac.nodes, node{children: map[rune]int{}})
+				ac.nodes[state].children[r] = next
+			}
+			state = next
+		}
+		ac.nodes[state].outputs = append(ac.nodes[state].outputs, wi)
+	}
+
+	queue := make([]int, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].children {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.nodes[state].children {
+			fail := ac.nodes[state].fail
+			for {
+				if next, ok := ac.nodes[fail].children[r]; ok && next != child {
+					ac.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					ac.nodes[child].fail = 0
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			ac.nodes[child].outputs = append(ac.nodes[child].outputs, ac.nodes[ac.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+
+	return ac
+}
+
+func normalizeWord(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if n, ok := normalize(r); ok {
+			b.WriteRune(n)
+		}
+	}
+	return b.String()
+}
+
+// normalize maps a rune to its canonical form and reports false for
+// separators that should be skipped while matching.
+func normalize(r rune) (rune, bool) {
+	switch {
+	case r == '　':
+		return 0, false
+	case r >= '！' && r <= '～':
+		r -= 0xFEE0
+	}
+
+	if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsControl(r) {
+		return 0, false
+	}
+
+	if s, ok := traditionalToSimplified[r]; ok {
+		r = s
+	}
+	return unicode.ToLower(r), true
+}
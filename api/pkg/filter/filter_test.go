@@ -0,0 +1,105 @@
+package filter
+
+import "testing"
+
+var testWords = []Word{
+	{Text: "赌博", Severity: SeverityHigh},
+	{Text: "dubo", Severity: SeverityHigh},
+	{Text: "加微信", Severity: SeverityMedium},
+	{Text: "傻", Mask: true, Severity: SeverityLow},
+	{Text: "ab", Severity: SeverityLow},
+	{Text: "bc", Severity: SeverityLow},
+}
+
+func TestCheck(t *testing.T) {
+	f := New()
+	f.Build(testWords)
+
+	tests := []struct {
+		name     string
+		text     string
+		blockAt  Severity
+		matches  int
+		blocked  bool
+		severity Severity
+		want     string
+	}{
+		{name: "clean", text: "主播好厉害", blockAt: SeverityLow, want: "主播好厉害"},
+		{name: "plain match", text: "来赌博吗", blockAt: SeverityMedium, matches: 1, blocked: true, severity: SeverityHigh, want: "来**吗"},
+		{name: "traditional with separator", text: "賭 博", blockAt: SeverityMedium, matches: 1, blocked: true, severity: SeverityHigh, want: "* *"},
+		{name: "full-width and case", text: "ＤＵ-ＢＯ", blockAt: SeverityHigh, matches: 1, blocked: true, severity: SeverityHigh, want: "**-**"},
+		{name: "below threshold is masked", text: "快加微信", blockAt: SeverityHigh, matches: 1, severity: SeverityMedium, want: "快***"},
+		{name: "at threshold blocks", text: "快加微信", blockAt: SeverityMedium, matches: 1, blocked: true, severity: SeverityMedium, want: "快***"},
+		{name: "mask words never block", text: "傻瓜", blockAt: SeverityLow, matches: 1, severity: SeverityLow, want: "*瓜"},
+		{name: "overlapping words", text: "xabcx", blockAt: SeverityHigh, matches: 2, severity: SeverityLow, want: "x***x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Check(tt.text, tt.blockAt)
+			if len(got.Matches) != tt.matches {
+				t.Errorf("Check(%q) matched %d words, want %d: %+v", tt.text, len(got.Matches), tt.matches, got.Matches)
+			}
+			if got.Blocked != tt.blocked {
+				t.Errorf("Check(%q) blocked = %v, want %v", tt.text, got.Blocked, tt.blocked)
+			}
+			if got.Severity != tt.severity {
+				t.Errorf("Check(%q) severity = %v, want %v", tt.text, got.Severity, tt.severity)
+			}
+			if got.Text != tt.want {
+				t.Errorf("Check(%q) text = %q, want %q", tt.text, got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchOffsets(t *testing.T) {
+	f := New()
+	f.Build(testWords)
+
+	got := f.Check("一起 賭-博", SeverityHigh)
+	if len(got.Matches) != 1 {
+		t.Fatalf("matched %d words, want 1", len(got.Matches))
+	}
+	// Offsets are runes into the original text and span the separator.
+	if m := got.Matches[0]; m.Start != 3 || m.End != 6 {
+		t.Errorf("match spans [%d, %d), want [3, 6)", m.Start, m.End)
+	}
+}
+
+func TestBuildReplacesWords(t *testing.T) {
+	f := New()
+	if f.Len() != 0 {
+		t.Fatalf("new filter has %d words", f.Len())
+	}
+
+	// Words that normalize to nothing are dropped.
+	f.Build([]Word{{Text: "赌博", Severity: SeverityHigh}, {Text: " - "}})
+	if f.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", f.Len())
+	}
+
+	f.Build([]Word{{Text: "加微信", Severity: SeverityMedium}})
+	if got := f.Check("赌博", SeverityLow); len(got.Matches) != 0 {
+		t.Errorf("old word still matches after Build: %+v", got.Matches)
+	}
+	if got := f.Check("加微信", SeverityLow); !got.Blocked {
+		t.Error("new word does not match after Build")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := map[string]Severity{
+		"low":    SeverityLow,
+		"LOW":    SeverityLow,
+		"medium": SeverityMedium,
+		"high":   SeverityHigh,
+		"":       SeverityMedium,
+		"severe": SeverityMedium,
+	}
+	for in, want := range tests {
+		if got := ParseSeverity(in); got != want {
+			t.Errorf("ParseSeverity(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package filter
+
+import "strings"
+
+// traditionalToSimplified folds common traditional characters, including the
+// ones most often used to dodge filters, onto their simplified forms.
+var traditionalToSimplified = buildVariantTable(
+	"賭赌 騙骗 詐诈 槍枪 彈弹 藥药 黃黄 錢钱 貸贷 債债 獎奖 贏赢 " +
+		"輸输 莊庄 盤盘 號号 碼码 買买 賣卖 價价 幣币 銀银 賬账 帳帐 " +
+		"戶户 網网 這这 個个 們们 來来 說说 時时 會会 對对 為为 國国 " +
+		"動动 發发 進进 過过 還还 從从 後后 開开 關关 頭头 實实 現现 " +
+		"點点 樣样 學学 經经 無无 問问 長长 東东 車车 當当 嗎吗 讓让 " +
+		"愛爱 見见 聽听 話话 邊边 裡里 裏里 氣气 電电 機机 場场 幾几 " +
+		"總总 應应 麼么 義义 體体 書书 變变 門门 間间 聲声 認认 識识 " +
+		"讀读 寫写 語语 請请 謝谢 計计 設设 論论 隊队 陽阳 陰阴 難难 " +
+		"風风 飛飞 馬马 魚鱼 鳥鸟 龍龙 黨党 產产 業业 歲岁 兒儿 倫伦 " +
+		"傳传 偽伪 殺杀 滅灭 獨独 鬥斗 亂乱 權权 選选 軍军 華华 團团 " +
+		"圖图 圍围 處处 夢梦 媽妈 爺爷 親亲 孫孙 婦妇 審审 導导 將将 " +
+		"屍尸 層层 島岛 帶带 師师 幫帮 廣广 張张 強强 歸归 復复 徵征 " +
+		"憂忧 懷怀 態态 戰战 擊击 據据 掃扫 換换 撥拨 數数 斷断 舊旧 " +
+		"術术 極极 標标 樂乐 歡欢 殘残 毀毁 漢汉 湯汤 溝沟 滿满 濕湿 " +
+		"災灾 燒烧 熱热 爭争 狀状 獄狱 環环 瑪玛 畫画 療疗 癡痴 監监 " +
+		"盜盗 礦矿 禮礼 禍祸 種种 穩稳 窮穷 筆笔 簽签 糧粮 紅红 約约 " +
+		"級级 紀纪 純纯 納纳 紙纸 組组 細细 終终 結结 給给 絕绝 統统 " +
+		"絲丝 綠绿 線线 練练 編编 緣缘 績绩 繼继 續续 罰罚 職职 聯联 " +
+		"腦脑 臉脸 興兴 舉举 藝艺 蘭兰 蟲虫 衛卫 裝装 規规 視视 覺觉 " +
+		"觀观 訂订 記记 訊讯 許许 評评 試试 詩诗 該该 誠诚 誤误 調调 " +
+		"談谈 證证 護护 讚赞 豬猪 貓猫 負负 財财 貨货 貧贫 費费 資资 " +
+		"賀贺 賽赛 贈赠 趙赵 跡迹 蹤踪 軟软 載载 輕轻 輪轮 轉转 辦办 " +
+		"農农 連连 遊游 運运 遠远 適适 鄉乡 醫医 釣钓 鐵铁 鎖锁 鏡镜 " +
+		"閃闪 閱阅 陸陆 隨随 險险 雖虽 雙双 雜杂 雞鸡 離离 靈灵 頁页 " +
+		"項项 順顺 預预 領领 題题 額额 顏颜 願愿 類类 顧顾 顯显 飯饭 " +
+		"飲饮 養养 館馆 驗验 驚惊 髮发 鬧闹 麥麦 齊齐 齒齿 龜龟",
+)
+
+func buildVariantTable(pairs string) map[rune]rune {
+	table := make(map[rune]rune)
+	for _, pair := range strings.Fields(pairs) {
+		runes := []rune(pair)
+		if len(runes) == 2 && runes[0] != runes[1] {
+			table[runes[0]] = runes[1]
+		}
+	}
+	return table
+}
@@ -0,0 +1,150 @@
+package ical
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+
+	maxLineOctets = 75
+	dateTimeUTC   = "20060102T150405Z"
+)
+
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Categories   string
+	URL          string
+	Status       string
+	Start        time.Time
+	End          time.Time
+	Created      time.Time
+	LastModified time.Time
+	Sequence     int
+}
+
+// Calendar renders an RFC 5545 VCALENDAR with one VEVENT per Event.
+type Calendar struct {
+	ProdID          string
+	Name            string
+	Description     string
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+func (c *Calendar) String() string {
+	var b strings.Builder
+	w := &writer{b: &b}
+
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.prop("PRODID", c.ProdID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.text("X-WR-CALNAME", c.Name)
+		w.text("NAME", c.Name)
+	}
+	if c.Description != "" {
+		w.text("X-WR-CALDESC", c.Description)
+	}
+	if c.RefreshInterval > 0 {
+		w.line("REFRESH-INTERVAL;VALUE=DURATION:" + formatDuration(c.RefreshInterval))
+		w.line("X-PUBLISHED-TTL:" + formatDuration(c.RefreshInterval))
+	}
+
+	stamp := time.Now().UTC().Format(dateTimeUTC)
+	for _, e := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.prop("UID", e.UID)
+		w.prop("DTSTAMP", stamp)
+		w.prop("DTSTART", e.Start.UTC().Format(dateTimeUTC))
+		if !e.End.IsZero() {
+			w.prop("DTEND", e.End.UTC().Format(dateTimeUTC))
+		}
+		w.text("SUMMARY", e.Summary)
+		if e.Description != "" {
+			w.text("DESCRIPTION", e.Description)
+		}
+		if e.Categories != "" {
+			w.text("CATEGORIES", e.Categories)
+		}
+		if e.URL != "" {
+			w.prop("URL;VALUE=URI", e.URL)
+		}
+		if e.Status != "" {
+			w.prop("STATUS", e.Status)
+		}
+		if !e.Created.IsZero() {
+			w.prop("CREATED", e.Created.UTC().Format(dateTimeUTC))
+		}
+		if !e.LastModified.IsZero() {
+			w.prop("LAST-MODIFIED", e.LastModified.UTC().Format(dateTimeUTC))
+		}
+		if e.Sequence > 0 {
+			w.prop("SEQUENCE", strconv.Itoa(e.Sequence))
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return b.String()
+}
+
+type writer struct {
+	b *strings.Builder
+}
+
+func (w *writer) prop(name, value string) {
+	w.line(name + ":" + value)
+}
+
+func (w *writer) text(name, value string) {
+	w.line(name + ":" + escapeText(value))
+}
+
+// line writes a content line terminated by CRLF, folding it so that no
+// physical line exceeds 75 octets and no UTF-8 sequence is split.
+func (w *writer) line(s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return r.Replace(s)
+}
+
+func formatDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return "PT" + strconv.Itoa(int(d/time.Hour)) + "H"
+	}
+	return "PT" + strconv.Itoa(int(d/time.Minute)) + "M"
+}
@@ -0,0 +1,258 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Mode selects how a list endpoint pages. Feeds use keyset cursors so new
+// rows never shift a page; admin tables use offsets so they can jump.
+type Mode int
+
+const (
+	Cursor Mode = iota
+	Offset
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Spec is the contract of one list endpoint.
+type Spec struct {
+	Mode         Mode
+	DefaultLimit int
+	MaxLimit     int
+	// Sorts maps each public sort name to its SQL column. Sort columns must
+	// not be NULL, or keyset cursors skip rows.
+	Sorts map[string]string
+	// DefaultSort is a key of Sorts, prefixed with "-" for descending.
+	DefaultSort string
+	// Key is a unique column that breaks ties between equal sort values.
+	Key string
+	// Filters lists the accepted filter parameters with their allowed
+	// values; a nil slice accepts any non-empty value.
+	Filters map[string][]string
+}
+
+// Params is a validated page request.
+type Params struct {
+	Limit   int
+	Offset  int
+	Sort    string
+	Desc    bool
+	Filters map[string]string
+
+	spec   Spec
+	column string
+	after  *position
+}
+
+// position is the decoded form of a cursor: the sort and last row of the
+// previous page for keyset mode, or the next offset for offset mode.
+type position struct {
+	Sort   string `json:"s,omitempty"`
+	Value  string `json:"v,omitempty"`
+	Key    string `json:"k,omitempty"`
+	Offset int    `json:"o,omitempty"`
+}
+
+// Parse validates limit, cursor, sort, offset/page and the spec's filters
+// from query. Missing parameters fall back to the spec's defaults; a limit
+// above MaxLimit is clamped.
+func Parse(query url.Values, spec Spec) (Params, error) {
+	p := Params{Limit: spec.DefaultLimit, Filters: make(map[string]string), spec: spec}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return p, fmt.Errorf("invalid limit")
+		}
+		p.Limit = limit
+	}
+	if p.Limit > spec.MaxLimit {
+		p.Limit = spec.MaxLimit
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	p.Desc = strings.HasPrefix(sort, "-")
+	p.Sort = strings.TrimPrefix(sort, "-")
+	column, ok := spec.Sorts[p.Sort]
+	if !ok {
+		return p, fmt.Errorf("unsupported sort: %s", p.Sort)
+	}
+	p.column = column
+
+	if v := query.Get("cursor"); v != "" {
+		pos, err := decode(v)
+		if err != nil {
+			return p, err
+		}
+		if spec.Mode == Cursor {
+			if pos.Sort != sort {
+				return p, fmt.Errorf("cursor does not match sort %s", sort)
+			}
+			p.after = pos
+		} else {
+			p.Offset = pos.Offset
+		}
+	} else if spec.Mode == Offset {
+		if v := query.Get("offset"); v != "" {
+			offset, err := strconv.Atoi(v)
+			if err != nil || offset < 0 {
+				return p, fmt.Errorf("invalid offset")
+			}
+			p.Offset = offset
+		} else if v := query.Get("page"); v != "" {
+			page, err := strconv.Atoi(v)
+			if err != nil || page <= 0 {
+				return p, fmt.Errorf("invalid page")
+			}
+			p.Offset = (page - 1) * p.Limit
+		}
+	}
+
+	for name, allowed := range spec.Filters {
+		v := strings.TrimSpace(query.Get(name))
+		if v == "" {
+			continue
+		}
+		if allowed != nil && !contains(allowed, v) {
+			return p, fmt.Errorf("invalid %s: %s", name, v)
+		}
+		p.Filters[name] = v
+	}
+
+	return p, nil
+}
+
+// Filter returns the value of an accepted filter, or "" when it was not set.
+func (p Params) Filter(name string) string {
+	return p.Filters[name]
+}
+
+// Key identifies this page request for caching. It is built from the
+// validated values only, so equivalent queries share a key and parameters
+// outside the spec cannot add new ones.
+func (p Params) Key() string {
+	order := p.Sort
+	if p.Desc {
+		order = "-" + order
+	}
+	key := fmt.Sprintf("sort=%s&limit=%d&offset=%d", order, p.Limit, p.Offset)
+	if p.after != nil {
+		key += "&after=" + encode(*p.after)
+	}
+
+	names := make([]string, 0, len(p.Filters))
+	for name := range p.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key += "&" + name + "=" + url.QueryEscape(p.Filters[name])
+	}
+	return key
+}
+
+// Apply orders query by the requested sort and restricts it to this page.
+// It fetches one row past the limit so Next can tell whether another page
+// follows.
+func (p Params) Apply(query *gorm.DB) *gorm.DB {
+	dir, cmp := "ASC", ">"
+	if p.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if p.after != nil {
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", p.column, p.spec.Key, cmp), p.after.Value, p.after.Key)
+	}
+	query = query.Order(fmt.Sprintf("%s %s, %s %s", p.column, dir, p.spec.Key, dir)).Limit(p.Limit + 1)
+	if p.spec.Mode == Offset && p.Offset > 0 {
+		query = query.Offset(p.Offset)
+	}
+	return query
+}
+
+// Find counts every row query matches, then loads this page into dest.
+func (p Params) Find(query *gorm.DB, dest interface{}) (int64, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, p.Apply(query.Session(&gorm.Session{})).Find(dest).Error
+}
+
+// Next takes the number of rows Apply loaded and returns how many belong on
+// this page together with the cursor of the following page, which is empty
+// on the last page. row returns the sort value and key of the i-th row; it
+// is only called in cursor mode and may be nil for offset specs.
+func (p Params) Next(n int, row func(i int) (value, key interface{})) (int, string) {
+	if n <= p.Limit {
+		return n, ""
+	}
+
+	if p.spec.Mode == Offset {
+		return p.Limit, encode(position{Offset: p.Offset + p.Limit})
+	}
+
+	value, key := row(p.Limit - 1)
+	sort := p.Sort
+	if p.Desc {
+		sort = "-" + sort
+	}
+	return p.Limit, encode(position{Sort: sort, Value: format(value), Key: format(key)})
+}
+
+func encode(pos position) string {
+	b, _ := json.Marshal(pos)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) (*position, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var pos position
+	if err := json.Unmarshal(b, &pos); err != nil || pos.Offset < 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &pos, nil
+}
+
+// format renders a sort value so Postgres can compare it with the column.
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package pagination
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var cursorSpec = Spec{
+	Mode:         Cursor,
+	DefaultLimit: 20,
+	MaxLimit:     50,
+	Sorts:        map[string]string{"created": "created_at", "name": "name"},
+	DefaultSort:  "-created",
+	Key:          "id",
+	Filters:      map[string][]string{"status": {"live", "offline"}, "category": nil},
+}
+
+var offsetSpec = Spec{
+	Mode:         Offset,
+	DefaultLimit: 10,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created": "created_at"},
+	DefaultSort:  "-created",
+	Key:          "id",
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Spec
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			spec:  cursorSpec,
+			query: "",
+			want:  Params{Limit: 20, Sort: "created", Desc: true},
+		},
+		{
+			name:  "limit is clamped",
+			spec:  cursorSpec,
+			query: "limit=500",
+			want:  Params{Limit: 50, Sort: "created", Desc: true},
+		},
+		{
+			name:  "ascending sort",
+			spec:  cursorSpec,
+			query: "sort=name&limit=5",
+			want:  Params{Limit: 5, Sort: "name"},
+		},
+		{
+			name:  "filters",
+			spec:  cursorSpec,
+			query: "status=live&category=%20game%20",
+			want:  Params{Limit: 20, Sort: "created", Desc: true, Filters: map[string]string{"status": "live", "category": "game"}},
+		},
+		{
+			name:  "offset",
+			spec:  offsetSpec,
+			query: "offset=30",
+			want:  Params{Limit: 10, Offset: 30, Sort: "created", Desc: true},
+		},
+		{
+			name:  "page",
+			spec:  offsetSpec,
+			query: "page=3&limit=25",
+			want:  Params{Limit: 25, Offset: 50, Sort: "created", Desc: true},
+		},
+		{
+			name:  "offset cursor wins over page",
+			spec:  offsetSpec,
+			query: "cursor=" + encode(position{Offset: 40}) + "&page=2",
+			want:  Params{Limit: 10, Offset: 40, Sort: "created", Desc: true},
+		},
+		{
+			name:  "offset is ignored in cursor mode",
+			spec:  cursorSpec,
+			query: "offset=30",
+			want:  Params{Limit: 20, Sort: "created", Desc: true},
+		},
+		{name: "zero limit", spec: cursorSpec, query: "limit=0", wantErr: true},
+		{name: "non-numeric limit", spec: cursorSpec, query: "limit=ten", wantErr: true},
+		{name: "unknown sort", spec: cursorSpec, query: "sort=-balance", wantErr: true},
+		{name: "filter outside allowed values", spec: cursorSpec, query: "status=banned", wantErr: true},
+		{name: "negative offset", spec: offsetSpec, query: "offset=-1", wantErr: true},
+		{name: "zero page", spec: offsetSpec, query: "page=0", wantErr: true},
+		{name: "malformed cursor", spec: cursorSpec, query: "cursor=!!!", wantErr: true},
+		{name: "cursor for another sort", spec: cursorSpec, query: "sort=name&cursor=" + encode(position{Sort: "-created", Value: "x", Key: "y"}), wantErr: true},
+		{name: "negative offset cursor", spec: offsetSpec, query: "cursor=" + encode(position{Offset: -5}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("bad test query: %v", err)
+			}
+			got, err := Parse(query, tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.query, err)
+			}
+			if got.Limit != tt.want.Limit || got.Offset != tt.want.Offset || got.Sort != tt.want.Sort || got.Desc != tt.want.Desc {
+				t.Errorf("Parse(%q) = limit %d offset %d sort %q desc %v, want limit %d offset %d sort %q desc %v",
+					tt.query, got.Limit, got.Offset, got.Sort, got.Desc,
+					tt.want.Limit, tt.want.Offset, tt.want.Sort, tt.want.Desc)
+			}
+			if len(got.Filters) != len(tt.want.Filters) {
+				t.Fatalf("Parse(%q) filters = %v, want %v", tt.query, got.Filters, tt.want.Filters)
+			}
+			for name, v := range tt.want.Filters {
+				if got.Filter(name) != v {
+					t.Errorf("Filter(%q) = %q, want %q", name, got.Filter(name), v)
+				}
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	created := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	rows := func(i int) (interface{}, interface{}) { return created.Add(-time.Duration(i) * time.Minute), i }
+
+	tests := []struct {
+		name      string
+		spec      Spec
+		query     string
+		loaded    int
+		wantCount int
+		wantNext  bool
+	}{
+		{name: "short page", spec: cursorSpec, query: "limit=5", loaded: 3, wantCount: 3},
+		{name: "exact page", spec: cursorSpec, query: "limit=5", loaded: 5, wantCount: 5},
+		{name: "more rows", spec: cursorSpec, query: "limit=5", loaded: 6, wantCount: 5, wantNext: true},
+		{name: "offset more rows", spec: offsetSpec, query: "limit=5&offset=10", loaded: 6, wantCount: 5, wantNext: true},
+		{name: "offset last page", spec: offsetSpec, query: "limit=5&offset=10", loaded: 2, wantCount: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			p, err := Parse(query, tt.spec)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			count, next := p.Next(tt.loaded, rows)
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			if (next != "") != tt.wantNext {
+				t.Fatalf("next = %q, want next page %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+// TestNextCursorRoundTrip checks that a cursor from Next parses back into
+// the position after the last row of the page.
+func TestNextCursorRoundTrip(t *testing.T) {
+	created := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	p, err := Parse(url.Values{"limit": {"2"}}, cursorSpec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	_, next := p.Next(3, func(i int) (interface{}, interface{}) {
+		return created.Add(-time.Duration(i) * time.Hour), "row-" + string(rune('a'+i))
+	})
+
+	q, err := Parse(url.Values{"limit": {"2"}, "cursor": {next}}, cursorSpec)
+	if err != nil {
+		t.Fatalf("Parse(next cursor): %v", err)
+	}
+	if q.after == nil {
+		t.Fatal("cursor did not set a keyset position")
+	}
+	if want := created.Add(-time.Hour).Format(time.RFC3339Nano); q.after.Value != want {
+		t.Errorf("cursor value = %q, want %q", q.after.Value, want)
+	}
+	if q.after.Key != "row-b" {
+		t.Errorf("cursor key = %q, want %q", q.after.Key, "row-b")
+	}
+
+	// A cursor is bound to the sort it was issued for.
+	if _, err := Parse(url.Values{"sort": {"created"}, "cursor": {next}}, cursorSpec); err == nil {
+		t.Error("cursor issued for -created was accepted for created")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{name: "parameter order", a: "status=live&sort=name", b: "sort=name&status=live", equal: true},
+		{name: "unknown parameters", a: "status=live", b: "status=live&_=123&utm=x", equal: true},
+		{name: "defaults spelled out", a: "", b: "sort=-created&limit=20", equal: true},
+		{name: "clamped limit", a: "limit=50", b: "limit=5000", equal: true},
+		{name: "trimmed filter", a: "category=game", b: "category=%20game%20", equal: true},
+		{name: "filter value", a: "status=live", b: "status=offline"},
+		{name: "sort direction", a: "sort=name", b: "sort=-name"},
+		{name: "limit", a: "limit=10", b: "limit=20"},
+		{name: "cursor", a: "", b: "cursor=" + encode(position{Sort: "-created", Value: "2026-10-19T08:30:00Z", Key: "42"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make([]string, 2)
+			for i, raw := range []string{tt.a, tt.b} {
+				query, _ := url.ParseQuery(raw)
+				p, err := Parse(query, cursorSpec)
+				if err != nil {
+					t.Fatalf("Parse(%q): %v", raw, err)
+				}
+				keys[i] = p.Key()
+			}
+			if (keys[0] == keys[1]) != tt.equal {
+				t.Errorf("Key(%q) = %q, Key(%q) = %q, want equal %v", tt.a, keys[0], tt.b, keys[1], tt.equal)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run db: %v", err)
+	}
+
+	cursor := encode(position{Sort: "-created", Value: "2026-10-19T08:30:00Z", Key: "42"})
+	tests := []struct {
+		name  string
+		spec  Spec
+		query string
+		want  []string
+	}{
+		{
+			name:  "first page",
+			spec:  cursorSpec,
+			query: "limit=5",
+			want:  []string{"ORDER BY created_at DESC, id DESC", "LIMIT 6"},
+		},
+		{
+			name:  "keyset page",
+			spec:  cursorSpec,
+			query: "limit=5&cursor=" + cursor,
+			want:  []string{"(created_at, id) < ($1, $2)", "ORDER BY created_at DESC, id DESC", "LIMIT 6"},
+		},
+		{
+			name:  "ascending",
+			spec:  cursorSpec,
+			query: "sort=name",
+			want:  []string{"ORDER BY name ASC, id ASC", "LIMIT 21"},
+		},
+		{
+			name:  "offset page",
+			spec:  offsetSpec,
+			query: "page=2",
+			want:  []string{"ORDER BY created_at DESC, id DESC", "LIMIT 11", "OFFSET 10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			p, err := Parse(query, tt.spec)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var rows []map[string]interface{}
+			stmt := p.Apply(db.Table("rooms")).Find(&rows).Statement
+			sql := stmt.SQL.String()
+			for _, fragment := range tt.want {
+				if !strings.Contains(sql, fragment) {
+					t.Errorf("SQL %q does not contain %q", sql, fragment)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pinyin
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// initialRanges are the GB2312 codes at which each pinyin initial starts.
+// Level-1 hanzi (0xB0A1-0xD7F9) are ordered by pinyin, so the initial of a
+// character is the last range starting at or before its code. Level-2 hanzi
+// are ordered by radical and have no initial here.
+var initialRanges = []struct {
+	code    uint16
+	initial byte
+}{
+	{0xB0A1, 'a'}, {0xB0C5, 'b'}, {0xB2C1, 'c'}, {0xB4EE, 'd'}, {0xB6EA, 'e'},
+	{0xB7A2, 'f'}, {0xB8C1, 'g'}, {0xB9FE, 'h'}, {0xBBF7, 'j'}, {0xBFA6, 'k'},
+	{0xC0AC, 'l'}, {0xC2E8, 'm'}, {0xC4C3, 'n'}, {0xC5B6, 'o'}, {0xC5BE, 'p'},
+	{0xC6DA, 'q'}, {0xC8BB, 'r'}, {0xC8F6, 's'}, {0xCBFA, 't'}, {0xCDDA, 'w'},
+	{0xCEF4, 'x'}, {0xD1B9, 'y'}, {0xD4D1, 'z'},
+}
+
+const level1End = 0xD7F9
+
+// Initials returns the pinyin initials of s, e.g. "主播小姐姐" -> "zbxjj".
+// Latin letters and digits are kept lowercased; other characters, including
+// hanzi without a known initial, are dropped.
+func Initials(s string) string {
+	var b strings.Builder
+	encoder := simplifiedchinese.GBK.NewEncoder()
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII:
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(unicode.ToLower(r))
+			}
+		case unicode.Is(unicode.Han, r):
+			if initial := hanInitial(encoder, r); initial != 0 {
+				b.WriteByte(initial)
+			}
+		}
+	}
+	return b.String()
+}
+
+func hanInitial(encoder *encoding.Encoder, r rune) byte {
+	gbk, err := encoder.String(string(r))
+	if err != nil || len(gbk) != 2 {
+		return 0
+	}
+	code := uint16(gbk[0])<<8 | uint16(gbk[1])
+	if code < initialRanges[0].code || code > level1End {
+		return 0
+	}
+	initial := initialRanges[0].initial
+	for _, rg := range initialRanges {
+		if code < rg.code {
+			break
+		}
+		initial = rg.initial
+	}
+	return initial
+}
+
+// IsInitials reports whether q looks like a pinyin-initials query: only
+// ASCII letters.
+func IsInitials(q string) bool {
+	if q == "" {
+		return false
+	}
+	for _, r := range q {
+		if r >= unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
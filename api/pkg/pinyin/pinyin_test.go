@@ -0,0 +1,48 @@
+package pinyin
+
+import "testing"
+
+func TestInitials(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"主播小姐姐", "zbxjj"},
+		{"测试用户1", "csyh1"},
+		{"管理员", "gly"},
+		{"阿", "a"},
+		{"座", "z"},
+		{"Hello World!", "helloworld"},
+		{"Ｐ主播", "zb"},
+		{"小明🎮", "xm"},
+		// Level-2 and traditional hanzi have no initial in GB2312 order.
+		{"亍", ""},
+		{"賭", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Initials(tt.in); got != tt.want {
+			t.Errorf("Initials(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsInitials(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"zbxjj", true},
+		{"ZB", true},
+		{"", false},
+		{"zb1", false},
+		{"zb xj", false},
+		{"主播", false},
+		{"ｚｂ", false},
+	}
+	for _, tt := range tests {
+		if got := IsInitials(tt.in); got != tt.want {
+			t.Errorf("IsInitials(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
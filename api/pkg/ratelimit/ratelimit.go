@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/huya_live/api/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// tokenBucket refills `rate` tokens per second up to `burst` and takes one
+// token per call. It uses the Redis clock so every API instance agrees.
+var tokenBucket = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, retry}
+`)
+
+// Allow takes one token from the bucket at key. perMinute sets both the
+// refill rate and the burst size. When the bucket is empty it returns false
+// and how long until a token becomes available.
+func Allow(ctx context.Context, key string, perMinute int) (bool, time.Duration, error) {
+	if perMinute <= 0 {
+		return true, 0, nil
+	}
+
+	res, err := tokenBucket.Run(ctx, redis.GetClient(), []string{"ratelimit:" + key},
+		float64(perMinute)/60, perMinute).Int64Slice()
+	if err != nil {
+		return true, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// Cooldown lets one call through per window for key, which is how slow mode
+// and duplicate suppression work. A rejected call gets the remaining window.
+func Cooldown(ctx context.Context, key string, window time.Duration) (bool, time.Duration, error) {
+	if window <= 0 {
+		return true, 0, nil
+	}
+
+	client := redis.GetClient()
+	ok, err := client.SetNX(ctx, "cooldown:"+key, 1, window).Result()
+	if err != nil {
+		return true, 0, err
+	}
+	if ok {
+		return true, 0, nil
+	}
+
+	ttl, err := client.PTTL(ctx, "cooldown:"+key).Result()
+	if err != nil || ttl < 0 {
+		ttl = window
+	}
+	return false, ttl, nil
+}
+
+// CooldownRemaining reports how long the cooldown for key still runs, zero
+// when it is not set. It does not start one.
+func CooldownRemaining(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := redis.GetClient().PTTL(ctx, "cooldown:"+key).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// ReleaseCooldown ends the cooldown for key early, e.g. when the call it
+// let through failed afterwards.
+func ReleaseCooldown(ctx context.Context, key string) error {
+	return redis.GetClient().Del(ctx, "cooldown:"+key).Err()
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/huya_live/api/pkg/redis"
+)
+
+// useRedis points the redis package at an in-memory server and returns a
+// key prefix unique to the test.
+func useRedis(t *testing.T) string {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := redis.Init(mr.Addr(), "", 0); err != nil {
+		t.Fatalf("connect to Redis: %v", err)
+	}
+	return "test:" + uuid.NewString() + ":"
+}
+
+// TestDisabled checks that a zero or negative limit never reaches Redis.
+func TestDisabled(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() (bool, time.Duration, error)
+	}{
+		{"allow zero", func() (bool, time.Duration, error) { return Allow(ctx, "k", 0) }},
+		{"allow negative", func() (bool, time.Duration, error) { return Allow(ctx, "k", -1) }},
+		{"cooldown zero", func() (bool, time.Duration, error) { return Cooldown(ctx, "k", 0) }},
+		{"cooldown negative", func() (bool, time.Duration, error) { return Cooldown(ctx, "k", -time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, wait, err := tt.call()
+			if !ok || wait != 0 || err != nil {
+				t.Errorf("got (%v, %v, %v), want (true, 0, nil)", ok, wait, err)
+			}
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	prefix := useRedis(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		perMinute int
+		calls     int
+		allowed   int
+	}{
+		{name: "within burst", perMinute: 5, calls: 5, allowed: 5},
+		{name: "burst exhausted", perMinute: 5, calls: 8, allowed: 5},
+		{name: "single token", perMinute: 1, calls: 3, allowed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := prefix + tt.name
+			allowed := 0
+			var lastWait time.Duration
+			for i := 0; i < tt.calls; i++ {
+				ok, wait, err := Allow(ctx, key, tt.perMinute)
+				if err != nil {
+					t.Fatalf("Allow: %v", err)
+				}
+				if ok {
+					allowed++
+				} else {
+					lastWait = wait
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d of %d calls, want %d", allowed, tt.calls, tt.allowed)
+			}
+			refill := time.Minute / time.Duration(tt.perMinute)
+			if tt.calls > tt.allowed && (lastWait <= 0 || lastWait > refill) {
+				t.Errorf("retry after %v, want within (0, %v]", lastWait, refill)
+			}
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	prefix := useRedis(t)
+	ctx := context.Background()
+	window := 10 * time.Second
+
+	ok, _, err := Cooldown(ctx, prefix+"slow", window)
+	if err != nil || !ok {
+		t.Fatalf("first call = (%v, %v), want allowed", ok, err)
+	}
+	ok, wait, err := Cooldown(ctx, prefix+"slow", window)
+	if err != nil || ok {
+		t.Fatalf("second call = (%v, %v), want rejected", ok, err)
+	}
+	if wait <= 0 || wait > window {
+		t.Errorf("retry after %v, want within (0, %v]", wait, window)
+	}
+
+	if ok, _, err := Cooldown(ctx, prefix+"other", window); err != nil || !ok {
+		t.Errorf("other key = (%v, %v), want allowed", ok, err)
+	}
+}
+
+func TestCooldownRemainingAndRelease(t *testing.T) {
+	prefix := useRedis(t)
+	ctx := context.Background()
+	key := prefix + "dup"
+	window := 10 * time.Second
+
+	if wait, err := CooldownRemaining(ctx, key); err != nil || wait != 0 {
+		t.Fatalf("unset cooldown = (%v, %v), want (0, nil)", wait, err)
+	}
+	// Looking did not start the cooldown.
+	if ok, _, err := Cooldown(ctx, key, window); err != nil || !ok {
+		t.Fatalf("first call = (%v, %v), want allowed", ok, err)
+	}
+	if wait, err := CooldownRemaining(ctx, key); err != nil || wait <= 0 || wait > window {
+		t.Errorf("running cooldown = (%v, %v), want within (0, %v]", wait, err, window)
+	}
+
+	if err := ReleaseCooldown(ctx, key); err != nil {
+		t.Fatalf("ReleaseCooldown: %v", err)
+	}
+	if ok, _, err := Cooldown(ctx, key, window); err != nil || !ok {
+		t.Errorf("after release = (%v, %v), want allowed", ok, err)
+	}
+}
@@ -35,6 +35,10 @@ func Set(ctx context.Context, key string, value interface{}, expiration time.Dur
 	return client.Set(ctx, key, value, expiration).Err()
 }
 
+func SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func Del(ctx context.Context, keys ...string) error {
 	return client.Del(ctx, keys...).Err()
 }
@@ -70,3 +74,11 @@ func PFAdd(ctx context.Context, key string, els ...interface{}) error {
 func PFCount(ctx context.Context, keys ...string) (int64, error) {
 	return client.PFCount(ctx, keys...).Result()
 }
+
+func ZRevRank(ctx context.Context, key, member string) (int64, error) {
+	return client.ZRevRank(ctx, key, member).Result()
+}
+
+func ZScore(ctx context.Context, key, member string) (float64, error) {
+	return client.ZScore(ctx, key, member).Result()
+}
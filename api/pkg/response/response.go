@@ -2,7 +2,10 @@ package response
 
 import (
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Response struct {
@@ -19,6 +22,18 @@ func Success(c *gin.Context, data interface{}) {
 	})
 }
 
+// Page is the envelope every list endpoint returns. NextCursor is empty on
+// the last page; Total counts all matching items, not just this page.
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+	Total      int64       `json:"total"`
+}
+
+func Paged(c *gin.Context, items interface{}, nextCursor string, total int64) {
+	Success(c, Page{Items: items, NextCursor: nextCursor, Total: total})
+}
+
 func Fail(c *gin.Context, message string) {
 	c.JSON(http.StatusOK, Response{
 		Code:    -1,
@@ -46,3 +61,19 @@ func BadRequest(c *gin.Context, message string) {
 		Message: message,
 	})
 }
+
+// TooManyRequests rejects a rate-limited call. reason is a stable machine
+// readable code; retryAfter is also sent as a Retry-After header.
+func TooManyRequests(c *gin.Context, message, reason string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, Response{
+		Code:    429,
+		Message: message,
+		Data: gin.H{
+			"reason":         reason,
+			"retry_after":    seconds,
+			"retry_after_ms": retryAfter.Milliseconds(),
+		},
+	})
+}
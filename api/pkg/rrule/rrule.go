@@ -0,0 +1,158 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule is the subset of an RFC 5545 RRULE that streamer timetables need:
+// FREQ=DAILY|WEEKLY with optional INTERVAL, BYDAY and UNTIL.
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Until    *time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, ErrInvalidRule
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" {
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRule, value)
+			}
+			rule.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 52 {
+				return nil, fmt.Errorf("%w: bad INTERVAL %s", ErrInvalidRule, value)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := weekdays[d]
+				if !ok {
+					return nil, fmt.Errorf("%w: bad BYDAY %s", ErrInvalidRule, d)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad UNTIL %s", ErrInvalidRule, value)
+			}
+			rule.Until = &until
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// Next returns the first occurrence strictly after `after` for a series that
+// started at dtstart. Occurrences keep dtstart's wall-clock time of day.
+func (r *Rule) Next(dtstart, after time.Time) (time.Time, bool) {
+	var next time.Time
+	switch r.Freq {
+	case "DAILY":
+		next = dtstart
+		if !after.Before(dtstart) {
+			days := int(after.Sub(dtstart).Hours()/24) / r.Interval * r.Interval
+			next = dtstart.AddDate(0, 0, days)
+		}
+		for !next.After(after) {
+			next = next.AddDate(0, 0, r.Interval)
+		}
+	case "WEEKLY":
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{dtstart.Weekday()}
+		}
+		anchor := startOfWeek(dtstart)
+		day := dtstart
+		if after.After(dtstart) {
+			offset := int(after.Sub(dtstart).Hours() / 24)
+			day = dtstart.AddDate(0, 0, offset)
+		}
+		found := false
+		for i := 0; i <= 7*(r.Interval+1); i++ {
+			candidate := day.AddDate(0, 0, i)
+			if !candidate.After(after) || !containsWeekday(byDay, candidate.Weekday()) {
+				continue
+			}
+			weeks := int(startOfWeek(candidate).Sub(anchor).Hours()/24+0.5) / 7
+			if weeks%r.Interval != 0 {
+				continue
+			}
+			next = candidate
+			found = true
+			break
+		}
+		if !found {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func containsWeekday(days []time.Weekday, d time.Weekday) bool {
+	for _, wd := range days {
+		if wd == d {
+			return true
+		}
+	}
+	return false
+}
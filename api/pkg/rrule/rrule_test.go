@@ -0,0 +1,107 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		freq     string
+		interval int
+		byDay    []time.Weekday
+		until    string
+		wantErr  bool
+	}{
+		{in: "FREQ=DAILY", freq: "DAILY", interval: 1},
+		{in: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE", freq: "WEEKLY", interval: 1, byDay: []time.Weekday{time.Monday, time.Wednesday}},
+		{in: "freq=weekly;interval=2;byday=fr", freq: "WEEKLY", interval: 2, byDay: []time.Weekday{time.Friday}},
+		{in: "FREQ=DAILY;UNTIL=20261031T120000Z", freq: "DAILY", interval: 1, until: "2026-10-31T12:00:00Z"},
+		{in: "FREQ=DAILY;UNTIL=20261031", freq: "DAILY", interval: 1, until: "2026-10-31T23:59:59Z"},
+		{in: "", wantErr: true},
+		{in: "BYDAY=MO", wantErr: true},
+		{in: "FREQ=MONTHLY", wantErr: true},
+		{in: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{in: "FREQ=DAILY;INTERVAL=53", wantErr: true},
+		{in: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		{in: "FREQ=DAILY;UNTIL=tomorrow", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=3", wantErr: true},
+		{in: "FREQ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			rule, err := Parse(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Fatalf("Parse(%q) error = %v, want ErrInvalidRule", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.in, err)
+			}
+			if rule.Freq != tt.freq || rule.Interval != tt.interval {
+				t.Errorf("Parse(%q) = %s every %d, want %s every %d", tt.in, rule.Freq, rule.Interval, tt.freq, tt.interval)
+			}
+			if len(rule.ByDay) != len(tt.byDay) {
+				t.Fatalf("Parse(%q) BYDAY = %v, want %v", tt.in, rule.ByDay, tt.byDay)
+			}
+			for i, d := range tt.byDay {
+				if rule.ByDay[i] != d {
+					t.Errorf("Parse(%q) BYDAY = %v, want %v", tt.in, rule.ByDay, tt.byDay)
+				}
+			}
+			switch {
+			case tt.until == "" && rule.Until != nil:
+				t.Errorf("Parse(%q) UNTIL = %v, want none", tt.in, rule.Until)
+			case tt.until != "" && (rule.Until == nil || rule.Until.Format(time.RFC3339) != tt.until):
+				t.Errorf("Parse(%q) UNTIL = %v, want %s", tt.in, rule.Until, tt.until)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2026-10-05 is a Monday.
+	dtstart := time.Date(2026, 10, 5, 20, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		rule   string
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "daily before start", rule: "FREQ=DAILY", after: at(1, 0), want: dtstart, wantOK: true},
+		{name: "daily at start", rule: "FREQ=DAILY", after: dtstart, want: at(6, 20), wantOK: true},
+		{name: "daily later the same day", rule: "FREQ=DAILY", after: at(8, 21), want: at(9, 20), wantOK: true},
+		{name: "daily earlier the same day", rule: "FREQ=DAILY", after: at(8, 19), want: at(8, 20), wantOK: true},
+		{name: "every third day", rule: "FREQ=DAILY;INTERVAL=3", after: at(6, 0), want: at(8, 20), wantOK: true},
+		{name: "weekly defaults to start weekday", rule: "FREQ=WEEKLY", after: dtstart, want: at(12, 20), wantOK: true},
+		{name: "weekly by day", rule: "FREQ=WEEKLY;BYDAY=MO,WE", after: dtstart, want: at(7, 20), wantOK: true},
+		{name: "weekly by day wraps the week", rule: "FREQ=WEEKLY;BYDAY=MO,WE", after: at(7, 21), want: at(12, 20), wantOK: true},
+		{name: "fortnightly skips odd weeks", rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", after: dtstart, want: at(19, 20), wantOK: true},
+		{name: "until ends the series", rule: "FREQ=DAILY;UNTIL=20261007", after: at(7, 20), wantOK: false},
+		{name: "until is inclusive", rule: "FREQ=DAILY;UNTIL=20261007", after: at(6, 20), want: at(7, 20), wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got, ok := rule.Next(dtstart, tt.after)
+			if ok != tt.wantOK {
+				t.Fatalf("Next(%v) ok = %v, want %v (got %v)", tt.after, ok, tt.wantOK, got)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
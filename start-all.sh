@@ -63,6 +63,7 @@ pkill -f "huya.*api\|./server" 2>/dev/null || true
 sleep 1
 cd /Users/hawkwu/Desktop/huya_live/api
 nohup env DB_HOST=localhost DB_PASSWORD=huya_live_secret REDIS_ADDR=localhost:6379 \
+    CENTRIFUGO_API_KEY=api_key CENTRIFUGO_SECRET=secret CENTRIFUGO_PROXY_SECRET=proxy_secret \
     ./server > /tmp/huya-api.log 2>&1 &
 sleep 3
 
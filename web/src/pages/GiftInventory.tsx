@@ -35,7 +35,7 @@ function GiftInventory() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setGifts(response.data.data)
+				setGifts(response.data.data.items)
 			}
 		} catch (error) {
 			message.error('获取礼物背包失败')
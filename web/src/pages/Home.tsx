@@ -39,7 +39,7 @@ function Home() {
     try {
       const response = await axios.get('/api/v1/live/rooms')
       if (response.data.code === 0) {
-        setRooms(response.data.data)
+        setRooms(response.data.data.items)
       }
     } catch (error) {
       console.error('Failed to fetch live rooms:', error)
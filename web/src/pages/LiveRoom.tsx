@@ -124,7 +124,7 @@ function LiveRoom() {
 		try {
 			const response = await axios.get(`/api/v1/social/followers/${room.streamer_id}`)
 			if (response.data.code === 0) {
-				const followers = response.data.data || []
+				const followers = response.data.data.items || []
 				const mockViewers: Viewer[] = followers.map((f: any) => ({
 					id: f.user_id,
 					nickname: f.nickname || '观众',
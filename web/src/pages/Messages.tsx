@@ -64,9 +64,10 @@ function Messages() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setConversations(response.data.data)
-				if (!selectedUser && response.data.data.length > 0) {
-					setSelectedUser(response.data.data[0].user_id)
+				const items = response.data.data.items
+				setConversations(items)
+				if (!selectedUser && items.length > 0) {
+					setSelectedUser(items[0].user_id)
 				}
 			}
 		} catch (error) {
@@ -82,7 +83,7 @@ function Messages() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setMessages(response.data.data.reverse())
+				setMessages(response.data.data.items.reverse())
 			}
 		} catch (error) {
 			message.error('获取消息失败')
@@ -39,7 +39,7 @@ function Notifications() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setNotifications(response.data.data)
+				setNotifications(response.data.data.items)
 			}
 		} catch (error) {
 			message.error('获取通知失败')
@@ -49,10 +49,10 @@ function Schedules() {
 			])
 
 			if (upcomingRes.data.code === 0) {
-				setUpcomingSchedules(upcomingRes.data.data)
+				setUpcomingSchedules(upcomingRes.data.data.items)
 			}
 			if (myRes.data.code === 0) {
-				setMySchedules(myRes.data.data)
+				setMySchedules(myRes.data.data.items)
 			}
 		} catch (error) {
 			message.error('获取直播预告失败')
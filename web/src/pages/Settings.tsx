@@ -76,7 +76,7 @@ function Settings() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setTransactions(response.data.data)
+				setTransactions(response.data.data.items)
 			}
 		} catch (error) {
 			message.error('获取交易记录失败')
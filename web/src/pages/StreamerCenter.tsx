@@ -76,13 +76,12 @@ function StreamerCenter() {
 
   const fetchLiveRoom = async () => {
     try {
+      if (!userId) return
       const response = await axios.get('/api/v1/live/rooms', {
-        params: { status: 'live' }
+        params: { status: 'live', streamer_id: userId, limit: 1 }
       })
       if (response.data.code === 0) {
-        const rooms = response.data.data
-        const myRoom = rooms.find((r: any) => r.streamer_id === userId)
-        setLiveRoom(myRoom || null)
+        setLiveRoom(response.data.data.items[0] || null)
       }
     } catch (error) {
       console.error('获取直播间失败')
@@ -97,7 +96,7 @@ function StreamerCenter() {
         headers: { Authorization: `Bearer ${accessToken}` }
       })
       if (response.data.code === 0) {
-        setTransactions(response.data.data)
+        setTransactions(response.data.data.items)
       }
     } catch (error) {
       console.error('获取交易记录失败')
@@ -38,7 +38,7 @@ function WatchHistoryPage() {
 				headers: { Authorization: `Bearer ${accessToken}` }
 			})
 			if (response.data.code === 0) {
-				setHistory(response.data.data)
+				setHistory(response.data.data.items)
 			}
 		} catch (error) {
 			message.error('获取观看历史失败')